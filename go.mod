@@ -12,6 +12,7 @@ require (
 	github.com/onsi/gomega v1.39.0
 	github.com/toejough/go-reorder v0.0.0-20260123033158-812dc6e76018
 	github.com/toejough/testredundancy v0.0.0-20260129180558-09d0fdc0bb61
+	golang.org/x/sys v0.39.0
 	pgregory.net/rapid v1.2.0
 )
 
@@ -37,7 +38,6 @@ require (
 	golang.org/x/mod v0.32.0 // indirect
 	golang.org/x/net v0.48.0 // indirect
 	golang.org/x/sync v0.19.0 // indirect
-	golang.org/x/sys v0.39.0 // indirect
 	golang.org/x/text v0.32.0 // indirect
 	golang.org/x/tools v0.40.0 // indirect
 )
@@ -0,0 +1,436 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	internalfile "github.com/toejough/targ/internal/file"
+)
+
+// unexported constants.
+const (
+	artifactsDirName    = "artifacts"
+	cacheEntryExt       = ".sum"
+	cacheHashEnvVar     = "TARG_CACHE_HASH"
+	cacheMaxSizeEnvVar  = "TARG_CACHE_MAX_SIZE"
+	defaultCacheDir     = ".targ-cache"
+	defaultCacheMaxSize = 1 << 30 // 1 GiB
+	remoteCacheEnvVar   = "TARG_REMOTE_CACHE"
+	statCacheFileName   = "stat-cache.json"
+)
+
+// unexported variables.
+var (
+	errInvalidCacheSize = errors.New("invalid cache size")
+)
+
+// CacheBackend stores artifact cache data outside the local cache directory,
+// so results can be shared between machines (e.g. CI and developers).
+type CacheBackend = internalfile.CacheBackend
+
+type cacheBackendKey struct{}
+
+type explainKey struct{}
+
+// cacheRun is one cache-checked execution: the digest of its inputs and key,
+// and where to record them once the run succeeds.
+type cacheRun struct {
+	digest  string
+	files   map[string]string // input path to content hash
+	dir     string
+	key     cacheKey
+	outputs []string
+	stats   *internalfile.StatCache
+}
+
+// commit records a successful run: its cache entry and, when outputs are
+// declared, the output files themselves in the artifact cache.
+func (c *cacheRun) commit(ctx context.Context) error {
+	err := c.record()
+	if err != nil {
+		return err
+	}
+
+	if len(c.outputs) == 0 {
+		return nil
+	}
+
+	store, err := artifactStore(ctx, c.dir)
+	if err != nil {
+		return err
+	}
+
+	files, err := internalfile.Match(c.outputs...)
+	if err != nil {
+		return fmt.Errorf("matching outputs: %w", err)
+	}
+
+	return store.Save(ctx, c.digest, files)
+}
+
+// explain prints why the run missed the cache, for --explain: which inputs were added,
+// removed or modified since the target's last successful run, and what else changed.
+func (c *cacheRun) explain(ctx context.Context) error {
+	prev, found, err := findCacheEntry(c.dir, c.key)
+	if err != nil {
+		return err
+	}
+
+	var reasons []string
+
+	switch {
+	case !found:
+		reasons = []string{"never run"}
+	case prev.Digest == c.digest:
+		reasons = []string{"outputs missing or stale"}
+	default:
+		reasons = prev.missReasons(c.key, c.files)
+		if len(reasons) == 0 {
+			reasons = []string{"cache key changed"}
+		}
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s: cache miss\n", c.key.Path)
+
+	for _, reason := range reasons {
+		fmt.Fprintf(&b, "  %s\n", reason)
+	}
+
+	Print(ctx, b.String())
+
+	return nil
+}
+
+// lookup reports whether the run can be skipped. It can when the stored checksum
+// matches and declared outputs are up to date, or when the artifact cache (local or
+// remote) holds outputs for this digest, e.g. after switching branches, and they were restored.
+func (c *cacheRun) lookup(ctx context.Context) (bool, error) {
+	prev, _, err := readCacheEntry(c.sumPath())
+	if err != nil {
+		return false, err
+	}
+
+	if prev.Digest == c.digest {
+		upToDate, err := outputsUpToDate(excludeCacheDir(c.key.Patterns, c.dir), c.key.matchOptions(), c.outputs)
+		if err != nil || upToDate {
+			return upToDate, err
+		}
+	}
+
+	if len(c.outputs) > 0 {
+		store, err := artifactStore(ctx, c.dir)
+		if err != nil {
+			return false, err
+		}
+
+		restored, err := store.Restore(ctx, c.digest, c.outputs)
+		if err != nil {
+			return false, err
+		}
+
+		if restored {
+			return true, c.record()
+		}
+	}
+
+	if explaining(ctx) {
+		return false, c.explain(ctx)
+	}
+
+	return false, nil
+}
+
+// peek reports what lookup would find, without restoring outputs or recording a checksum.
+func (c *cacheRun) peek(ctx context.Context) (string, error) {
+	prev, _, err := readCacheEntry(c.sumPath())
+	if err != nil {
+		return "", err
+	}
+
+	if prev.Digest == c.digest {
+		upToDate, err := outputsUpToDate(excludeCacheDir(c.key.Patterns, c.dir), c.key.matchOptions(), c.outputs)
+		if err != nil {
+			return "", err
+		}
+
+		if upToDate {
+			return "cache hit", nil
+		}
+	}
+
+	if len(c.outputs) == 0 {
+		return "cache miss", nil
+	}
+
+	store, err := artifactStore(ctx, c.dir)
+	if err != nil {
+		return "", err
+	}
+
+	stored, err := store.Has(ctx, c.digest)
+	if err != nil || !stored {
+		return "cache miss", err
+	}
+
+	return "cache hit, restores outputs", nil
+}
+
+// record writes the cache entry for this run.
+func (c *cacheRun) record() error {
+	return writeCacheEntry(c.sumPath(), cacheEntry{
+		Target:  c.key.Path,
+		Key:     c.key,
+		Outputs: c.outputs,
+		Digest:  c.digest,
+		Files:   c.files,
+	})
+}
+
+func (c *cacheRun) sumPath() string {
+	return c.dir + "/" + c.key.fileName()
+}
+
+// artifactStore returns the artifact cache under dir, bounded by TARG_CACHE_MAX_SIZE.
+// Its remote is the backend carried by ctx, or else the one named by TARG_REMOTE_CACHE,
+// and remote failures are printed as warnings.
+func artifactStore(ctx context.Context, dir string) (*internalfile.ArtifactStore, error) {
+	maxBytes := int64(defaultCacheMaxSize)
+
+	if value := os.Getenv(cacheMaxSizeEnvVar); value != "" {
+		size, err := parseByteSize(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cacheMaxSizeEnvVar, err)
+		}
+
+		maxBytes = size
+	}
+
+	remote, ok := cacheBackendFromContext(ctx)
+	if !ok {
+		var err error
+
+		remote, err = remoteCacheFromEnv(os.Getenv)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &internalfile.ArtifactStore{
+		Dir:      filepath.Join(dir, artifactsDirName),
+		MaxBytes: maxBytes,
+		Remote:   remote,
+		Warn: func(err error) {
+			Printf(ctx, "warning: remote cache: %v\n", err)
+		},
+	}, nil
+}
+
+// cacheBackendFromContext returns the remote cache backend carried by ctx, if any.
+func cacheBackendFromContext(ctx context.Context) (CacheBackend, bool) {
+	backend, ok := ctx.Value(cacheBackendKey{}).(CacheBackend)
+	return backend, ok
+}
+
+// cacheHashing returns how to hash inputs for the cache under dir: with the algorithm
+// named by TARG_CACHE_HASH (sha256 by default), reusing hashes from the stat cache
+// under dir for files that haven't changed.
+func cacheHashing(dir string) (*internalfile.HashOptions, error) {
+	hashing := &internalfile.HashOptions{
+		StatCache: internalfile.LoadStatCache(filepath.Join(dir, statCacheFileName)),
+	}
+
+	if value := os.Getenv(cacheHashEnvVar); value != "" {
+		alg, err := internalfile.ParseHashAlgorithm(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cacheHashEnvVar, err)
+		}
+
+		hashing.Algorithm = alg
+	}
+
+	return hashing, nil
+}
+
+// lookupCache checks the cache for a run of key.
+// On a miss, the returned cacheRun must be committed after the run succeeds.
+func lookupCache(
+	ctx context.Context,
+	key cacheKey,
+	outputs []string,
+	dir string,
+) (*cacheRun, bool, error) {
+	run, err := newCacheRun(key, outputs, dir)
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = run.stats.Save()
+	if err != nil {
+		return nil, false, err
+	}
+
+	hit, err := run.lookup(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return run, hit, nil
+}
+
+// newCacheRun digests the current inputs of key, with the cache stored under dir.
+func newCacheRun(key cacheKey, outputs []string, dir string) (*cacheRun, error) {
+	if dir == "" {
+		dir = defaultCacheDir
+	}
+
+	hashing, err := cacheHashing(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := hashCacheInputs(key, dir, hashing)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cacheRun{
+		digest:  internalfile.CombineHashes(key.String(), files),
+		files:   files,
+		dir:     dir,
+		key:     key,
+		outputs: outputs,
+		stats:   hashing.StatCache,
+	}, nil
+}
+
+// excludeCacheDir adds an exclusion for the cache directory to patterns, so the
+// cache's own writes never count as input changes (e.g. for .Cache("**")).
+// Relative directories only affect relative patterns.
+func excludeCacheDir(patterns []string, dir string) []string {
+	if dir == "" {
+		dir = defaultCacheDir
+	}
+
+	return append(slices.Clone(patterns), "!"+filepath.ToSlash(filepath.Clean(dir)))
+}
+
+// explaining reports whether cache misses should say why (--explain).
+func explaining(ctx context.Context) bool {
+	on, _ := ctx.Value(explainKey{}).(bool)
+	return on
+}
+
+// hashCacheInputs returns the content hash of each file matching key's patterns,
+// leaving out the cache directory dir.
+func hashCacheInputs(
+	key cacheKey,
+	dir string,
+	hashing *internalfile.HashOptions,
+) (map[string]string, error) {
+	files, err := internalfile.HashFiles(
+		excludeCacheDir(key.Patterns, dir),
+		func(p []string) ([]string, error) { return internalfile.MatchWithOptions(p, key.matchOptions()) },
+		nil,
+		hashing,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("computing checksum: %w", err)
+	}
+
+	return files, nil
+}
+
+// outputsUpToDate reports whether declared outputs exist and are newer than inputs,
+// matched with opts. With no declared outputs, there is nothing to check.
+func outputsUpToDate(inputs []string, opts internalfile.MatchOptions, outputs []string) (bool, error) {
+	if len(outputs) == 0 {
+		return true, nil
+	}
+
+	stale, err := internalfile.Newer(inputs, outputs, opts, internalfile.MatchWithOptions, nil)
+	if err != nil {
+		return false, fmt.Errorf("checking outputs: %w", err)
+	}
+
+	return !stale, nil
+}
+
+// parseByteSize parses a size like "500MB", "2G", or "1048576" (bytes).
+// Units are powers of 1024.
+func parseByteSize(value string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(value))
+	upper = strings.TrimSuffix(upper, "B")
+
+	multiplier := int64(1)
+
+	units := []struct {
+		suffix string
+		scale  int64
+	}{
+		{"K", 1 << 10},
+		{"M", 1 << 20},
+		{"G", 1 << 30},
+		{"T", 1 << 40},
+	}
+
+	for _, unit := range units {
+		if strings.HasSuffix(upper, unit.suffix) {
+			upper = strings.TrimSuffix(upper, unit.suffix)
+			multiplier = unit.scale
+
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(upper), 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("%w: %q", errInvalidCacheSize, value)
+	}
+
+	return n * multiplier, nil
+}
+
+// peekCache reports whether a run of key would hit the cache ("cache hit", "cache hit,
+// restores outputs" or "cache miss") without changing the cache or any outputs.
+func peekCache(ctx context.Context, key cacheKey, outputs []string, dir string) (string, error) {
+	run, err := newCacheRun(key, outputs, dir)
+	if err != nil {
+		return "", err
+	}
+
+	return run.peek(ctx)
+}
+
+// remoteCacheFromEnv returns the backend named by TARG_REMOTE_CACHE, or nil if it is unset.
+func remoteCacheFromEnv(getenv func(string) string) (CacheBackend, error) {
+	spec := getenv(remoteCacheEnvVar)
+	if spec == "" {
+		return nil, nil
+	}
+
+	backend, err := internalfile.ParseCacheBackend(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", remoteCacheEnvVar, err)
+	}
+
+	return backend, nil
+}
+
+// withCacheBackend returns a context whose cached targets share results through backend.
+func withCacheBackend(ctx context.Context, backend CacheBackend) context.Context {
+	return context.WithValue(ctx, cacheBackendKey{}, backend)
+}
+
+// withExplain returns a context in which cache misses say why they missed.
+func withExplain(ctx context.Context) context.Context {
+	return context.WithValue(ctx, explainKey{}, true)
+}
@@ -0,0 +1,405 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	internalfile "github.com/toejough/targ/internal/file"
+)
+
+// unexported variables.
+var (
+	errCacheCleanUsage    = errors.New("usage: --cache-clean <target> | --all")
+	errUnknownCacheTarget = errors.New("unknown cached target")
+)
+
+// cachedTarget is a target whose runs are cached: the key its entry is stored
+// under, and the directories it may be stored in.
+type cachedTarget struct {
+	key     cacheKey
+	outputs []string
+	dirs    []string
+}
+
+// entry returns the entry of t's last successful run and the directory it was
+// found in, or false if t has never completed a cached run.
+func (t cachedTarget) entry() (cacheEntry, string, bool, error) {
+	for _, dir := range t.dirs {
+		entry, ok, err := findCacheEntry(dir, t.key)
+		if err != nil || ok {
+			return entry, dir, ok, err
+		}
+	}
+
+	return cacheEntry{}, "", false, nil
+}
+
+// status reports whether t's next run would hit the cache ("hit" or "miss") and,
+// for a miss, what changed since its last run. Args aren't known here, so a run
+// is assumed to use the same args as the last one.
+func (t cachedTarget) status() (string, []string, error) {
+	prev, dir, ok, err := t.entry()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !ok {
+		return "miss", []string{"never run"}, nil
+	}
+
+	hashing, err := cacheHashing(dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	files, err := hashCacheInputs(t.key, dir, hashing)
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := t.key
+	key.Args = prev.Key.Args
+	reasons := prev.missReasons(key, files)
+
+	if len(reasons) == 0 {
+		upToDate, err := outputsUpToDate(excludeCacheDir(t.key.Patterns, dir), t.key.matchOptions(), t.outputs)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if !upToDate {
+			reasons = append(reasons, "outputs missing or stale")
+		}
+	}
+
+	if len(reasons) > 0 {
+		return "miss", reasons, nil
+	}
+
+	return "hit", nil, nil
+}
+
+// cacheDirs returns every directory the targets may store cache entries in.
+func cacheDirs(targets []cachedTarget, overrideDir string) []string {
+	dirs := []string{cacheDirOrDefault(overrideDir)}
+
+	for _, t := range targets {
+		dirs = append(dirs, t.dirs...)
+	}
+
+	slices.Sort(dirs)
+
+	return slices.Compact(dirs)
+}
+
+func cacheDirOrDefault(dir string) string {
+	if dir == "" {
+		return defaultCacheDir
+	}
+
+	return dir
+}
+
+// cachedTargets returns the cached targets under roots, including dependencies
+// that aren't registered themselves, sorted by path.
+func cachedTargets(roots []*commandNode, opts RunOptions) []cachedTarget {
+	var targets []cachedTarget
+
+	seen := make(map[string]bool)
+
+	add := func(key cacheKey, outputs []string, dirs ...string) {
+		if !seen[key.fileName()] {
+			seen[key.fileName()] = true
+			targets = append(targets, cachedTarget{key: key, outputs: outputs, dirs: slices.Compact(dirs)})
+		}
+	}
+
+	var walk func(node *commandNode)
+
+	walk = func(node *commandNode) {
+		if node.Target != nil {
+			if len(node.CachePatterns) > 0 {
+				// Run from the command line, a target caches where --cache-dir says,
+				// if it is given; otherwise, and as a dependency, where its own CacheDir says.
+				key := nodeCacheKey(node, opts)
+				key.Patterns = node.CachePatterns
+				dir := opts.Overrides.CacheDir
+				if dir == "" {
+					dir = node.Target.cacheDir
+				}
+
+				dirs := []string{cacheDirOrDefault(dir)}
+
+				if node.Target.cacheDir != "" {
+					dirs = append(dirs, node.Target.cacheDir)
+				}
+
+				add(key, node.OutputPatterns, dirs...)
+			}
+
+			walkDeps(node.Target, func(dep *Target) {
+				if len(dep.cache) > 0 {
+					add(dep.buildCacheKey(nil), dep.outputs, cacheDirOrDefault(dep.cacheDir))
+				}
+			})
+		}
+
+		for _, name := range sortedKeys(node.Subcommands) {
+			walk(node.Subcommands[name])
+		}
+	}
+
+	for _, root := range roots {
+		walk(root)
+	}
+
+	sort.SliceStable(targets, func(i, j int) bool { return targets[i].key.Path < targets[j].key.Path })
+
+	return targets
+}
+
+// cleanCache removes cache entries as requested by the arguments after --cache-clean:
+// a target's entry and the artifacts stored for it, or with --all, everything targ
+// stored in every cache directory. A directory is only removed once nothing else is
+// left in it, as it may be one the user shares with other files.
+func cleanCache(
+	ctx context.Context,
+	w io.Writer,
+	roots []*commandNode,
+	opts RunOptions,
+	args []string,
+) error {
+	if len(args) == 0 {
+		return errCacheCleanUsage
+	}
+
+	targets := cachedTargets(roots, opts)
+	dirs := cacheDirs(targets, opts.Overrides.CacheDir)
+
+	if slices.Equal(args, []string{"--all"}) {
+		for _, dir := range dirs {
+			_, err := os.Stat(dir)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			removed, err := internalfile.RemoveOwned(dir, ownedCacheFile)
+			if err != nil {
+				return err
+			}
+
+			if removed {
+				_, _ = fmt.Fprintf(w, "removed %s\n", dir)
+			} else {
+				_, _ = fmt.Fprintf(w, "cleaned %s, keeping files targ didn't create\n", dir)
+			}
+		}
+
+		return nil
+	}
+
+	target := strings.Join(args, " ")
+	known := slices.ContainsFunc(targets, func(t cachedTarget) bool {
+		return strings.EqualFold(t.key.Path, target)
+	})
+	removed := 0
+
+	for _, dir := range dirs {
+		entries, err := listCacheEntries(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, stored := range entries {
+			if !strings.EqualFold(stored.entry.Target, target) {
+				continue
+			}
+
+			known = true
+
+			err := removeCacheEntry(ctx, dir, stored)
+			if err != nil {
+				return err
+			}
+
+			removed++
+
+			_, _ = fmt.Fprintf(w, "removed %s\n", stored.path)
+		}
+	}
+
+	if !known {
+		return fmt.Errorf("%w: %s", errUnknownCacheTarget, target)
+	}
+
+	if removed == 0 {
+		_, _ = fmt.Fprintf(w, "%s: nothing cached\n", target)
+	}
+
+	return nil
+}
+
+// countNoun renders n with noun, pluralized by appending "s" unless n is 1.
+func countNoun(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// dirSize returns the total size of the files under dir.
+func dirSize(dir string) (int64, error) {
+	var total int64
+
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		total += info.Size()
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("measuring %s: %w", dir, err)
+	}
+
+	return total, nil
+}
+
+// ownedCacheFile reports whether name, in a cache directory, is one targ stores there.
+func ownedCacheFile(name string) bool {
+	return strings.HasSuffix(name, cacheEntryExt) || name == statCacheFileName ||
+		name == artifactsDirName
+}
+
+// removeCacheEntry deletes stored, found in dir, along with its artifacts.
+func removeCacheEntry(ctx context.Context, dir string, stored storedCacheEntry) error {
+	store, err := artifactStore(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	_, err = store.Remove(stored.entry.Digest)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(stored.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing cache entry: %w", err)
+	}
+
+	return nil
+}
+
+// writeCacheInfo writes the size and contents of each cache directory of the
+// targets under roots.
+func writeCacheInfo(ctx context.Context, w io.Writer, roots []*commandNode, opts RunOptions) error {
+	shown := false
+
+	for _, dir := range cacheDirs(cachedTargets(roots, opts), opts.Overrides.CacheDir) {
+		_, err := os.Stat(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		entries, err := listCacheEntries(dir)
+		if err != nil {
+			return err
+		}
+
+		store, err := artifactStore(ctx, dir)
+		if err != nil {
+			return err
+		}
+
+		artifacts, artifactBytes, err := store.Usage()
+		if err != nil {
+			return err
+		}
+
+		total, err := dirSize(dir)
+		if err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintf(w, "%s: %s, %s (%s), %s total\n",
+			dir,
+			countNoun(len(entries), "entry"),
+			countNoun(artifacts, "artifact set"),
+			internalfile.FormatSize(artifactBytes),
+			internalfile.FormatSize(total),
+		)
+
+		for _, stored := range entries {
+			line := fmt.Sprintf("  %s: %s", stored.entry.Target, countNoun(len(stored.entry.Files), "input"))
+
+			if info, err := os.Stat(stored.path); err == nil {
+				line += ", last run " + info.ModTime().Format(time.DateTime)
+			}
+
+			_, _ = fmt.Fprintln(w, line)
+		}
+
+		shown = true
+	}
+
+	if !shown {
+		_, _ = fmt.Fprintln(w, "No target caches.")
+	}
+
+	return nil
+}
+
+// writeCacheStatus writes whether each cached target under roots, or only the one
+// named by args, would hit the cache, and what changed since the last run of those
+// that wouldn't.
+func writeCacheStatus(w io.Writer, roots []*commandNode, opts RunOptions, args []string) error {
+	target := strings.Join(args, " ")
+	found := false
+
+	for _, t := range cachedTargets(roots, opts) {
+		if target != "" && !strings.EqualFold(t.key.Path, target) {
+			continue
+		}
+
+		found = true
+
+		status, reasons, err := t.status()
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.key.Path, err)
+		}
+
+		_, _ = fmt.Fprintf(w, "%s: %s\n", t.key.Path, status)
+
+		for _, reason := range reasons {
+			_, _ = fmt.Fprintf(w, "  %s\n", reason)
+		}
+	}
+
+	if target != "" && !found {
+		return fmt.Errorf("%w: %s", errUnknownCacheTarget, target)
+	}
+
+	if !found {
+		_, _ = fmt.Fprintln(w, "No cached targets.")
+	}
+
+	return nil
+}
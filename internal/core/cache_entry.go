@@ -0,0 +1,213 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	internalfile "github.com/toejough/targ/internal/file"
+)
+
+// cacheEntry is what a cached target records after a successful run: the target and
+// key it ran with, and the hash of each input file alongside their combined digest,
+// so later runs can tell which inputs changed.
+type cacheEntry struct {
+	Target  string            `json:"target"`
+	Key     cacheKey          `json:"key"`
+	Outputs []string          `json:"outputs,omitempty"`
+	Digest  string            `json:"digest"`
+	Files   map[string]string `json:"files"`
+}
+
+// inputChanges compares the recorded inputs with files, the current input hashes.
+func (e cacheEntry) inputChanges(files map[string]string) internalfile.ChangeSet {
+	var changes internalfile.ChangeSet
+
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		prev, ok := e.Files[path]
+
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, path)
+		case prev != files[path]:
+			changes.Modified = append(changes.Modified, path)
+		}
+	}
+
+	for _, path := range slices.Sorted(maps.Keys(e.Files)) {
+		if _, ok := files[path]; !ok {
+			changes.Removed = append(changes.Removed, path)
+		}
+	}
+
+	return changes
+}
+
+// missReasons explains why a run of key, with files as its input hashes, doesn't match
+// e: which inputs were added, removed or modified, and which parts of the key changed.
+func (e cacheEntry) missReasons(key cacheKey, files map[string]string) []string {
+	changes := e.inputChanges(files)
+
+	var reasons []string
+
+	for _, group := range []struct {
+		label string
+		paths []string
+	}{
+		{"added", changes.Added},
+		{"removed", changes.Removed},
+		{"modified", changes.Modified},
+	} {
+		for _, path := range group.paths {
+			reasons = append(reasons, group.label+": "+path)
+		}
+	}
+
+	return append(reasons, keyChanges(e.Key, key)...)
+}
+
+// storedCacheEntry is a cache entry along with the file it was read from.
+type storedCacheEntry struct {
+	path  string
+	entry cacheEntry
+}
+
+// findCacheEntry returns the entry of the last successful run of key's target in dir:
+// the one stored for key or, failing that, one stored for the target under other
+// patterns. It returns false if the target hasn't run.
+func findCacheEntry(dir string, key cacheKey) (cacheEntry, bool, error) {
+	entry, ok, err := readCacheEntry(filepath.Join(dir, key.fileName()))
+	if err != nil || ok {
+		return entry, ok, err
+	}
+
+	entries, err := listCacheEntries(dir)
+	if err != nil {
+		return cacheEntry{}, false, err
+	}
+
+	for _, stored := range entries {
+		if stored.entry.Target == key.Path {
+			return stored.entry, true, nil
+		}
+	}
+
+	return cacheEntry{}, false, nil
+}
+
+// keyChanges describes how cur differs from prev, the key of the last run.
+func keyChanges(prev, cur cacheKey) []string {
+	var changes []string
+
+	prevPatterns := slices.Sorted(slices.Values(prev.Patterns))
+	curPatterns := slices.Sorted(slices.Values(cur.Patterns))
+
+	if !slices.Equal(prevPatterns, curPatterns) {
+		changes = append(changes, fmt.Sprintf("patterns changed: %s -> %s",
+			strings.Join(prevPatterns, " "), strings.Join(curPatterns, " ")))
+	}
+
+	if !slices.Equal(prev.Args, cur.Args) {
+		changes = append(changes, fmt.Sprintf("args changed: %s -> %s",
+			strings.Join(prev.Args, " "), strings.Join(cur.Args, " ")))
+	}
+
+	if prev.Command != cur.Command {
+		changes = append(changes, "command changed")
+	}
+
+	names := slices.Sorted(maps.Keys(prev.Env))
+	for _, name := range slices.Sorted(maps.Keys(cur.Env)) {
+		if _, ok := prev.Env[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		prevValue, prevOK := prev.Env[name]
+		curValue, curOK := cur.Env[name]
+
+		if prevOK != curOK || prevValue != curValue {
+			changes = append(changes, "environment changed: "+name)
+		}
+	}
+
+	return changes
+}
+
+// listCacheEntries returns the entries stored in dir, sorted by target.
+// Files that aren't entries (e.g. checksums written by older versions) are skipped.
+func listCacheEntries(dir string) ([]storedCacheEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+cacheEntryExt))
+	if err != nil {
+		return nil, fmt.Errorf("listing cache entries: %w", err)
+	}
+
+	var entries []storedCacheEntry
+
+	for _, path := range paths {
+		entry, ok, err := readCacheEntry(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			entries = append(entries, storedCacheEntry{path: path, entry: entry})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].entry.Target < entries[j].entry.Target
+	})
+
+	return entries, nil
+}
+
+// readCacheEntry returns the entry stored at path, or false if there is none.
+// A file that doesn't hold an entry, such as a checksum written by an older
+// version, counts as none: the target simply runs again.
+func readCacheEntry(path string) (cacheEntry, bool, error) {
+	//nolint:gosec // G304: Reading from the configured cache directory.
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cacheEntry{}, false, nil
+	}
+
+	if err != nil {
+		return cacheEntry{}, false, fmt.Errorf("reading cache entry: %w", err)
+	}
+
+	var entry cacheEntry
+
+	err = json.Unmarshal(data, &entry)
+	if err != nil || entry.Digest == "" {
+		return cacheEntry{}, false, nil
+	}
+
+	return entry, true, nil
+}
+
+// writeCacheEntry stores entry at path, creating parent directories as needed. The
+// entry is replaced atomically, so a concurrent or interrupted run never leaves a
+// partial one behind.
+func writeCacheEntry(path string, entry cacheEntry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding cache entry: %w", err)
+	}
+
+	//nolint:mnd // standard cache file permissions
+	err = internalfile.WriteFileAtomic(path, data, 0o644)
+	if err != nil {
+		return fmt.Errorf("writing cache entry: %w", err)
+	}
+
+	return nil
+}
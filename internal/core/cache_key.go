@@ -0,0 +1,109 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	internalfile "github.com/toejough/targ/internal/file"
+)
+
+// cacheKey holds everything besides input file contents that a cached run depends on.
+// Path and Patterns identify the cache entry; RespectIgnoreFiles, Command, Args, and
+// Env invalidate it.
+type cacheKey struct {
+	Path               string            `json:"path"`
+	Patterns           []string          `json:"patterns"`
+	RespectIgnoreFiles bool              `json:"respect_ignore_files,omitempty"`
+	Command            string            `json:"command,omitempty"`
+	Args               []string          `json:"args,omitempty"`
+	Env                map[string]string `json:"env,omitempty"`
+}
+
+// fileName returns the cache entry file name for this key's path and patterns.
+func (k cacheKey) fileName() string {
+	hash := sha256.New()
+
+	if k.Path != "" {
+		hash.Write([]byte(k.Path))
+		hash.Write([]byte{0})
+	}
+
+	// Sort patterns for deterministic hashing
+	patterns := make([]string, len(k.Patterns))
+	copy(patterns, k.Patterns)
+	sort.Strings(patterns)
+
+	for _, p := range patterns {
+		hash.Write([]byte(p))
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))[:16] + cacheEntryExt
+}
+
+// matchOptions returns the options the key's patterns are matched with.
+func (k cacheKey) matchOptions() internalfile.MatchOptions {
+	return internalfile.MatchOptions{RespectIgnoreFiles: k.RespectIgnoreFiles}
+}
+
+// String returns a deterministic encoding of the key for mixing into the checksum.
+func (k cacheKey) String() string {
+	patterns := make([]string, len(k.Patterns))
+	copy(patterns, k.Patterns)
+	sort.Strings(patterns)
+
+	k.Patterns = patterns
+
+	// Marshaling a struct of strings and a string map cannot fail.
+	data, _ := json.Marshal(k)
+
+	return string(data)
+}
+
+// withArgs returns a copy of the key that includes the given target arguments.
+func (k cacheKey) withArgs(args ...any) cacheKey {
+	k.Args = nil
+
+	for _, arg := range args {
+		k.Args = append(k.Args, encodeCacheArg(arg))
+	}
+
+	return k
+}
+
+// withEnv returns a copy of the key that includes the named environment variables.
+func (k cacheKey) withEnv(names []string, getenv func(string) string) cacheKey {
+	if len(names) == 0 {
+		k.Env = nil
+		return k
+	}
+
+	k.Env = make(map[string]string, len(names))
+	for _, name := range names {
+		k.Env[name] = getenv(name)
+	}
+
+	return k
+}
+
+// newCacheKey returns the key for running the target called name with args, command
+// being its shell command, if it is one, and env the variables it caches on. Root and
+// dependency runs both build their keys here, so they share cache entries.
+func newCacheKey(name, command string, env []string, getenv func(string) string, args ...any) cacheKey {
+	return cacheKey{Path: name, Command: command}.withArgs(args...).withEnv(env, getenv)
+}
+
+// encodeCacheArg renders an argument value deterministically.
+// JSON covers parsed args structs (including maps, which it sorts); values it
+// can't encode fall back to their Go syntax representation.
+func encodeCacheArg(arg any) string {
+	data, err := json.Marshal(arg)
+	if err != nil {
+		return fmt.Sprintf("%#v", arg)
+	}
+
+	return string(data)
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+	"strings"
+
+	internalfile "github.com/toejough/targ/internal/file"
+	internalsh "github.com/toejough/targ/internal/sh"
+)
+
+// unexported constants.
+const (
+	changedFilesEnvVar = "TARG_CHANGED_FILES"
+)
+
+// Changes returns the file changes that triggered the current run of a watched target.
+// It is empty on a watch's first run and outside watch mode.
+func Changes(ctx context.Context) internalfile.ChangeSet {
+	changes, _ := ctx.Value(changesKey{}).(internalfile.ChangeSet)
+	return changes
+}
+
+type changesKey struct{}
+
+// changesShellEnv returns env with TARG_CHANGED_FILES set to the changed files in ctx,
+// one per line. Without changes, env is returned as is.
+func changesShellEnv(ctx context.Context, env *internalsh.ShellEnv) *internalsh.ShellEnv {
+	files := Changes(ctx).Files()
+	if len(files) == 0 {
+		return env
+	}
+
+	if env == nil {
+		env = internalsh.DefaultShellEnv()
+	}
+
+	withChanges := *env
+	withChanges.Env = append(
+		append([]string(nil), env.Env...),
+		changedFilesEnvVar+"="+strings.Join(files, "\n"),
+	)
+
+	return &withChanges
+}
+
+// inWatch reports whether ctx belongs to a run started by a watch, whose
+// targets don't start watches of their own.
+func inWatch(ctx context.Context) bool {
+	_, ok := ctx.Value(changesKey{}).(internalfile.ChangeSet)
+	return ok
+}
+
+// withChanges returns ctx carrying the changes that triggered a watch run.
+func withChanges(ctx context.Context, changes internalfile.ChangeSet) context.Context {
+	return context.WithValue(ctx, changesKey{}, changes)
+}
@@ -0,0 +1,366 @@
+package core
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	internalsh "github.com/toejough/targ/internal/sh"
+)
+
+// Command is an external command built up with Cmd. Its setters return the
+// command, so calls chain:
+//
+//	out, err := core.Cmd("go", "list", "./...").Dir("sub").Env("CGO_ENABLED=0").Output(ctx)
+//
+// Like RunContext, it runs in its own process group, which is killed when ctx is
+// cancelled, and in parallel mode its output goes through the parallel printer.
+type Command struct {
+	name         string
+	args         []string
+	dir          string
+	env          []string
+	stdin        io.Reader
+	stdinFile    string
+	stdout       io.Writer
+	stdoutFile   string
+	stdoutAppend bool
+	stderr       io.Writer
+	stderrFile   string
+	verbose      bool
+}
+
+// AppendStdout appends the command's stdout to the file at path, creating it if
+// needed, as >> does in a shell.
+func (c *Command) AppendStdout(path string) *Command {
+	c.StdoutFile(path)
+	c.stdoutAppend = true
+
+	return c
+}
+
+// CombinedOutput runs the command and returns its stdout and stderr interleaved,
+// as Output does.
+func (c *Command) CombinedOutput(ctx context.Context) (string, error) {
+	var buf internalsh.SafeBuffer
+
+	err := c.run(ctx, &buf, &buf)
+
+	return buf.String(), err
+}
+
+// Dir sets the directory the command runs in. By default, it is the current directory.
+func (c *Command) Dir(dir string) *Command {
+	c.dir = dir
+	return c
+}
+
+// Env adds KEY=value environment variables on top of the current environment.
+// Later values override earlier ones for the same key.
+func (c *Command) Env(vars ...string) *Command {
+	c.env = append(c.env, vars...)
+	return c
+}
+
+// Lines runs the command and returns its stdout split into lines, without line endings.
+// Stderr is shown as for Run.
+func (c *Command) Lines(ctx context.Context) ([]string, error) {
+	out, err := c.Output(ctx)
+
+	return splitLines(out), err
+}
+
+// Output runs the command and returns its stdout. Stderr is shown as for Run,
+// unless redirected with Stderr.
+func (c *Command) Output(ctx context.Context) (string, error) {
+	var buf internalsh.SafeBuffer
+
+	err := c.run(ctx, &buf, c.stderr)
+
+	return buf.String(), err
+}
+
+// OutputFunc returns a function that runs the command with the args it's called
+// with appended, returning its stdout as Output does. The command's args, directory,
+// environment and IO are bound when OutputFunc is called:
+//
+//	git := core.Cmd("git").Dir("sub").OutputFunc()
+//	head, err := git(ctx, "rev-parse", "HEAD")
+func (c *Command) OutputFunc() func(ctx context.Context, args ...string) (string, error) {
+	bound := c.withArgs(nil)
+
+	return func(ctx context.Context, args ...string) (string, error) {
+		return bound.withArgs(args).Output(ctx)
+	}
+}
+
+// Run runs the command, streaming its stdout and stderr to the terminal, or to
+// the writers set with Stdout and Stderr.
+func (c *Command) Run(ctx context.Context) error {
+	return c.run(ctx, c.stdout, c.stderr)
+}
+
+// RunFunc returns a function that runs the command with the args it's called with
+// appended, as Run does. The command's args, directory, environment and IO are
+// bound when RunFunc is called:
+//
+//	compose := core.Cmd("docker", "compose", "-f", composeFile).Env("COMPOSE_PROJECT_NAME=dev").RunFunc()
+//	err := compose(ctx, "up", "-d")
+func (c *Command) RunFunc() func(ctx context.Context, args ...string) error {
+	bound := c.withArgs(nil)
+
+	return func(ctx context.Context, args ...string) error {
+		return bound.withArgs(args).Run(ctx)
+	}
+}
+
+// Stderr sends the command's stderr to w instead of the terminal.
+func (c *Command) Stderr(w io.Writer) *Command {
+	c.stderr, c.stderrFile = w, ""
+	return c
+}
+
+// StderrFile writes the command's stderr to the file at path, replacing its contents,
+// as 2> does in a shell.
+func (c *Command) StderrFile(path string) *Command {
+	c.stderr, c.stderrFile = nil, path
+	return c
+}
+
+// Stdin sets what the command reads from. By default, it reads the terminal's stdin.
+func (c *Command) Stdin(r io.Reader) *Command {
+	c.stdin, c.stdinFile = r, ""
+	return c
+}
+
+// StdinFile has the command read the file at path, as < does in a shell.
+func (c *Command) StdinFile(path string) *Command {
+	c.stdin, c.stdinFile = nil, path
+	return c
+}
+
+// Stdout sends the command's stdout to w instead of the terminal.
+func (c *Command) Stdout(w io.Writer) *Command {
+	c.stdout, c.stdoutFile, c.stdoutAppend = w, "", false
+	return c
+}
+
+// StdoutFile writes the command's stdout to the file at path, replacing its contents,
+// as > does in a shell.
+func (c *Command) StdoutFile(path string) *Command {
+	c.stdout, c.stdoutFile, c.stdoutAppend = nil, path, false
+	return c
+}
+
+// String returns the command line, quoted for display.
+func (c *Command) String() string {
+	return internalsh.FormatCommand(c.name, c.args)
+}
+
+// Verbose prints the command line, prefixed with "+", before running it.
+func (c *Command) Verbose() *Command {
+	c.verbose = true
+	return c
+}
+
+// run runs the command with stdout and stderr going to the given writers, or,
+// where they are nil, to where the command sends them.
+func (c *Command) run(ctx context.Context, stdout, stderr io.Writer) error {
+	env, done := commandEnv(ctx)
+	defer done()
+
+	cmd, files, err := c.shellCommand(env, stdout, stderr)
+	defer closeFiles(files)
+
+	if err != nil {
+		return err
+	}
+
+	if c.verbose {
+		display := env.Stdout
+		if c.stdout != nil {
+			display = c.stdout
+		}
+
+		_, _ = fmt.Fprintln(display, "+", c.String())
+	}
+
+	return cmd.Run(ctx)
+}
+
+// shellCommand returns the command to run in env, with stdout and stderr going to the
+// given writers, or, where they are nil, to the command's own writers or files and
+// then env's. It also returns the files it opened, to close once the command has run.
+func (c *Command) shellCommand(
+	env *internalsh.ShellEnv,
+	stdout, stderr io.Writer,
+) (*internalsh.Command, []*os.File, error) {
+	cmd := &internalsh.Command{
+		Name:    c.name,
+		Args:    c.args,
+		Dir:     c.dir,
+		Env:     c.env,
+		Stdin:   cmp.Or(c.stdin, env.Stdin),
+		Stdout:  cmp.Or(stdout, c.stdout, env.Stdout),
+		Stderr:  cmp.Or(stderr, c.stderr, env.Stderr),
+		Cleanup: env.Cleanup,
+	}
+
+	var files []*os.File
+
+	open := func(path string, flag int) (*os.File, error) {
+		//nolint:gosec // G304: Redirecting to a file the caller named.
+		f, err := os.OpenFile(path, flag, redirectFileMode)
+		if err != nil {
+			return nil, fmt.Errorf("%s: redirecting: %w", c, err)
+		}
+
+		files = append(files, f)
+
+		return f, nil
+	}
+
+	if c.stdinFile != "" {
+		f, err := open(c.stdinFile, os.O_RDONLY)
+		if err != nil {
+			return nil, files, err
+		}
+
+		cmd.Stdin = f
+	}
+
+	// Explicit writers, such as Output's buffer, take the place of files.
+	if stdout == nil && c.stdoutFile != "" {
+		f, err := open(c.stdoutFile, c.stdoutFlag())
+		if err != nil {
+			return nil, files, err
+		}
+
+		cmd.Stdout = f
+	}
+
+	if stderr == nil && c.stderrFile != "" {
+		f, err := open(c.stderrFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			return nil, files, err
+		}
+
+		cmd.Stderr = f
+	}
+
+	return cmd, files, nil
+}
+
+// stdoutFlag returns the flags to open the command's stdout file with.
+func (c *Command) stdoutFlag() int {
+	if c.stdoutAppend {
+		return os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	return os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+}
+
+// withArgs returns a copy of the command with args appended to its own.
+func (c *Command) withArgs(args []string) *Command {
+	cmd := *c
+	cmd.args = append(slices.Clone(c.args), args...)
+	cmd.env = slices.Clone(c.env)
+
+	return &cmd
+}
+
+// CommandError is the error returned when a command can't be started, exits
+// non-zero, is stopped by a signal, or is cancelled.
+type CommandError = internalsh.CommandError
+
+// Cmd returns a command that runs name with args.
+func Cmd(name string, args ...string) *Command {
+	return &Command{name: name, args: args}
+}
+
+// CmdRan reports whether the command that returned err ran, even if it then failed.
+// It is false only when the command couldn't be started, e.g. because it wasn't found.
+func CmdRan(err error) bool {
+	return internalsh.CmdRan(err)
+}
+
+// ExitStatus returns the exit code for err: 0 if err is nil, the exit code of the
+// failed command or run it carries, and 1 otherwise. For a MultiError, it is the
+// exit status of the first failed target.
+func ExitStatus(err error) int {
+	var exitErr ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+
+	var multiErr *MultiError
+	if errors.As(err, &multiErr) {
+		for _, result := range multiErr.Results() {
+			if result.Err != nil && !errors.Is(result.Err, context.Canceled) {
+				return ExitStatus(result.Err)
+			}
+		}
+	}
+
+	return internalsh.ExitStatus(err)
+}
+
+// OutCmd returns a function that runs name with args, followed by the args it's
+// called with, and returns its stdout. See Command.OutputFunc.
+func OutCmd(name string, args ...string) func(ctx context.Context, args ...string) (string, error) {
+	return Cmd(name, args...).OutputFunc()
+}
+
+// RunCmd returns a function that runs name with args, followed by the args it's
+// called with. See Command.RunFunc.
+func RunCmd(name string, args ...string) func(ctx context.Context, args ...string) error {
+	return Cmd(name, args...).RunFunc()
+}
+
+// unexported constants.
+const (
+	redirectFileMode = 0o666 // before the umask, as a shell creates files
+)
+
+// closeFiles closes files, ignoring errors.
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
+
+// commandEnv returns the environment commands run in: the parallel printer's in
+// parallel mode, or else the terminal's. done flushes the parallel printer, once
+// the commands have run.
+func commandEnv(ctx context.Context) (*internalsh.ShellEnv, func()) {
+	env, pw := parallelShellEnv(ctx)
+	if pw != nil {
+		return env, pw.Flush
+	}
+
+	if env == nil {
+		env = internalsh.DefaultShellEnv()
+	}
+
+	return env, func() {}
+}
+
+// splitLines splits out into lines, without line endings or a trailing empty line.
+func splitLines(out string) []string {
+	out = strings.TrimRight(out, "\r\n")
+	if out == "" {
+		return nil
+	}
+
+	lines := strings.Split(out, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+
+	return lines
+}
@@ -17,6 +17,7 @@ import (
 	"time"
 	"unicode"
 
+	internalfile "github.com/toejough/targ/internal/file"
 	"github.com/toejough/targ/internal/help"
 	internalsh "github.com/toejough/targ/internal/sh"
 )
@@ -123,10 +124,15 @@ type commandNode struct {
 	ShellVars    []string // Variable names extracted from ShellCommand (lowercase)
 
 	// Target configuration for conflict detection with CLI overrides
-	WatchPatterns []string
-	CachePatterns []string
-	WatchDisabled bool
-	CacheDisabled bool
+	WatchPatterns  []string
+	CachePatterns  []string
+	OutputPatterns []string
+	CacheEnv       []string
+	Locks          []string
+	WatchOptions   internalfile.WatchOptions
+	WatchDisabled  bool
+	CacheDisabled  bool
+	RespectIgnore  bool // leave out what ignore files ignore from cache and watch patterns
 
 	// Execution configuration for help display
 	DepGroups       []DepGroupDisplay // Dependency groups with modes
@@ -419,6 +425,8 @@ func buildExecInfo(lines []string) *help.ExecutionInfo {
 			execInfo.Deps = strings.TrimPrefix(line, "Deps: ")
 		case strings.HasPrefix(line, "Cache:"):
 			execInfo.CachePatterns = strings.TrimPrefix(line, "Cache: ")
+		case strings.HasPrefix(line, "Outputs:"):
+			execInfo.OutputPatterns = strings.TrimPrefix(line, "Outputs: ")
 		case strings.HasPrefix(line, "Watch:"):
 			execInfo.WatchPatterns = strings.TrimPrefix(line, "Watch: ")
 		case strings.HasPrefix(line, "Timeout:"):
@@ -898,12 +906,27 @@ func executeDepsOnlyTarget(
 		return args, nil
 	}
 
-	// Run dependencies
-	if len(node.Target.depGroups) > 0 {
-		err := node.Target.runDeps(ctx)
-		if err != nil {
-			return nil, err
+	if plan, ok := dryRunFromContext(ctx); ok {
+		return args, plan.planNode(ctx, node, RuntimeOverrides{}, TargetConfig{}, "")
+	}
+
+	if watchesDeps(node, opts.Overrides) {
+		config := TargetConfig{
+			WatchPatterns:      node.WatchPatterns,
+			WatchOptions:       node.WatchOptions,
+			WatchDisabled:      node.WatchDisabled,
+			RespectIgnoreFiles: node.RespectIgnore,
+			cacheDir:           nodeCacheDir(node),
 		}
+
+		return args, runWatchingDeps(ctx, node, opts, config, nil)
+	}
+
+	err := runRootOnce(ctx, node.Target, nil, func() error {
+		return node.Target.runDeps(ctx)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return args, nil
@@ -1047,15 +1070,35 @@ func executeShellCommand(
 		return nil, err
 	}
 
-	config := TargetConfig{
-		WatchPatterns: node.WatchPatterns,
-		CachePatterns: node.CachePatterns,
-		WatchDisabled: node.WatchDisabled,
-		CacheDisabled: node.CacheDisabled,
+	// Run without vars, a shell target is the same run as when it is a dependency.
+	var runArgs []any
+	if len(parsed.varValues) > 0 {
+		runArgs = []any{parsed.varValues}
 	}
 
-	err = ExecuteWithOverrides(ctx, opts.Overrides, config, func() error {
-		return runShellWithVars(ctx, node.ShellCommand, parsed.varValues, opts.ShellRunner)
+	config := TargetConfig{
+		WatchPatterns:      node.WatchPatterns,
+		CachePatterns:      node.CachePatterns,
+		OutputPatterns:     node.OutputPatterns,
+		WatchOptions:       node.WatchOptions,
+		WatchDisabled:      node.WatchDisabled,
+		CacheDisabled:      node.CacheDisabled,
+		RespectIgnoreFiles: node.RespectIgnore,
+		cacheKey:           nodeCacheKey(node, opts, runArgs...),
+		cacheDir:           nodeCacheDir(node),
+	}
+
+	if plan, ok := dryRunFromContext(ctx); ok {
+		command := expandShellVars(node.ShellCommand, parsed.varValues)
+		return parsed.remaining, plan.planNode(ctx, node, opts.Overrides, config, command)
+	}
+
+	err = runRootOnce(ctx, node.Target, runArgs, func() error {
+		return ExecuteWithOverrides(ctx, opts.Overrides, config, func(ctx context.Context) error {
+			return runExclusive(ctx, node.Name, node.Locks, func(ctx context.Context) error {
+				return runShellWithVars(ctx, node.ShellCommand, parsed.varValues, opts.ShellRunner)
+			})
+		})
 	})
 	if err != nil {
 		return nil, err
@@ -1070,6 +1113,7 @@ func executionInfoLines(node *commandNode) []string {
 
 	lines = appendDepsLine(lines, node)
 	lines = appendPatternsLine(lines, "Cache", node.CachePatterns)
+	lines = appendPatternsLine(lines, "Outputs", node.OutputPatterns)
 	lines = appendPatternsLine(lines, "Watch", node.WatchPatterns)
 	lines = appendTimeoutLine(lines, node)
 	lines = appendTimesLine(lines, node)
@@ -1113,6 +1157,24 @@ func expandFlagGroup(arg, group string, shortInfo map[string]bool) ([]string, er
 	return expanded, nil
 }
 
+// expandShellVars substitutes $var and ${var} patterns with their values.
+// Variables without a value are left as written.
+func expandShellVars(cmd string, vars map[string]string) string {
+	return shellVarPattern.ReplaceAllStringFunc(cmd, func(match string) string {
+		submatch := shellVarPattern.FindStringSubmatch(match)
+		if len(submatch) < 2 { //nolint:mnd // regex submatch: [full, capture]
+			return match
+		}
+
+		varName := strings.ToLower(submatch[1])
+		if val, ok := vars[varName]; ok {
+			return val
+		}
+
+		return match
+	})
+}
+
 func expandShortFlagGroups(args []string, specs []*flagSpec) ([]string, error) {
 	if len(args) == 0 {
 		return args, nil
@@ -1158,6 +1220,11 @@ func extractShellVars(cmd string) []string {
 			continue
 		}
 
+		// $TARG_CHANGED_FILES is set by watch mode, not passed as a flag.
+		if match[1] == changedFilesEnvVar {
+			continue
+		}
+
 		varName := strings.ToLower(match[1])
 		if !seen[varName] {
 			seen[varName] = true
@@ -1393,6 +1460,31 @@ func nodeChain(node *commandNode) []*commandNode {
 	return chain
 }
 
+// nodeCacheDir returns the cache directory the target behind node declares, if any.
+func nodeCacheDir(node *commandNode) string {
+	if node.Target == nil {
+		return ""
+	}
+
+	return node.Target.cacheDir
+}
+
+// nodeCacheKey returns the cache key for running node with args. It is the key the
+// target has when it runs as a dependency, so either run can hit the other's entry.
+func nodeCacheKey(node *commandNode, opts RunOptions, args ...any) cacheKey {
+	getenv := opts.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+
+	name := node.Name
+	if node.Target != nil {
+		name = node.Target.GetName()
+	}
+
+	return newCacheKey(name, node.ShellCommand, node.CacheEnv, getenv, args...)
+}
+
 func nodeHasAddressableValue(node *commandNode) bool {
 	return node != nil && node.Value.IsValid() &&
 		node.Value.Kind() == reflect.Struct && node.Value.CanAddr()
@@ -1691,6 +1783,11 @@ func parseTargetLike(target TargetLike) (*commandNode, error) {
 	// Store Target reference for dep execution and resolve source file
 	if t, ok := target.(*Target); ok {
 		node.Target = t
+		node.OutputPatterns = t.GetOutputs()
+		node.CacheEnv = t.GetCacheEnv()
+		node.Locks = t.GetLocks()
+		node.WatchOptions = t.GetWatchOptions()
+		node.RespectIgnore = t.GetRespectIgnoreFiles()
 		resolveTargetSource(node, t)
 	}
 
@@ -1937,6 +2034,18 @@ func resolveTargetSource(node *commandNode, t *Target) {
 	}
 }
 
+// runRootOnce runs fn, the run of target from the command line with args, unless the
+// invocation has already run it. Roots, like dependencies, run at most once per
+// invocation, so one that another root depends on doesn't run again as its dependency.
+func runRootOnce(ctx context.Context, target *Target, args []any, fn func() error) error {
+	memo, ok := execMemoFromContext(ctx)
+	if target == nil || !ok {
+		return fn()
+	}
+
+	return memo.do(ctx, target, args, fn)
+}
+
 // runShellWithVars substitutes variables and executes a shell command.
 // If runner is nil, uses the default sh -c execution.
 func runShellWithVars(
@@ -1945,27 +2054,14 @@ func runShellWithVars(
 	vars map[string]string,
 	runner func(ctx context.Context, cmd string) error,
 ) error {
-	// Substitute $var and ${var} patterns
-	substituted := shellVarPattern.ReplaceAllStringFunc(cmd, func(match string) string {
-		submatch := shellVarPattern.FindStringSubmatch(match)
-		if len(submatch) < 2 { //nolint:mnd // regex submatch: [full, capture]
-			return match
-		}
-
-		varName := strings.ToLower(submatch[1])
-		if val, ok := vars[varName]; ok {
-			return val
-		}
-
-		return match
-	})
+	substituted := expandShellVars(cmd, vars)
 
 	// Execute via injected runner or default sh -c
 	var err error
 	if runner != nil {
 		err = runner(ctx, substituted)
 	} else {
-		err = internalsh.RunContextWithIO(ctx, nil, "sh", []string{"-c", substituted})
+		err = internalsh.RunContextWithIO(ctx, changesShellEnv(ctx, nil), "sh", []string{"-c", substituted})
 	}
 
 	if err != nil {
@@ -1984,45 +2080,74 @@ func runTargetWithOverrides(
 	inst reflect.Value,
 	opts RunOptions,
 ) error {
-	// Run dependencies first (if Target with deps is available)
-	if node.Target != nil && len(node.Target.depGroups) > 0 {
-		target := node.Target
-
-		// Apply --dep-mode override: flatten all groups into one
-		if opts.Overrides.DepMode != "" {
-			var mode DepMode
-			if opts.Overrides.DepMode == depModeParallelStr {
-				mode = DepModeParallel
-			}
+	// Apply --dep-mode override: flatten all groups into one
+	if node.Target != nil && opts.Overrides.DepMode != "" {
+		var mode DepMode
+		if opts.Overrides.DepMode == depModeParallelStr {
+			mode = DepModeParallel
+		}
+
+		allDeps := node.Target.GetDeps()
+		if len(allDeps) > 0 {
+			node.Target.depGroups = []depGroup{{targets: allDeps, mode: mode}}
+		}
+	}
+
+	// Execute with runtime overrides (times, retry, watch, cache, etc.)
+	config := TargetConfig{
+		WatchPatterns:      node.WatchPatterns,
+		CachePatterns:      node.CachePatterns,
+		OutputPatterns:     node.OutputPatterns,
+		WatchOptions:       node.WatchOptions,
+		WatchDisabled:      node.WatchDisabled,
+		CacheDisabled:      node.CacheDisabled,
+		RespectIgnoreFiles: node.RespectIgnore,
+		cacheDir:           nodeCacheDir(node),
+	}
+
+	if inst.IsValid() {
+		config.cacheKey = nodeCacheKey(node, opts, inst.Interface())
+	} else {
+		config.cacheKey = nodeCacheKey(node, opts)
+	}
+
+	if plan, ok := dryRunFromContext(ctx); ok {
+		return plan.planNode(ctx, node, opts.Overrides, config, "")
+	}
+
+	run := func(ctx context.Context) error {
+		return runExclusive(ctx, node.Name, node.Locks, func(ctx context.Context) error {
+			return callFunctionWithArgs(ctx, node.Func, inst)
+		})
+	}
+
+	if watchesDeps(node, opts.Overrides) {
+		return runWatchingDeps(ctx, node, opts, config, run)
+	}
 
-			allDeps := target.GetDeps()
-			if len(allDeps) > 0 {
-				target.depGroups = []depGroup{{targets: allDeps, mode: mode}}
+	runWithDeps := func() error {
+		// Run dependencies first (if Target with deps is available)
+		if node.Target != nil && len(node.Target.depGroups) > 0 {
+			err := node.Target.runDeps(ctx)
+			if err != nil {
+				return err
 			}
 		}
 
-		err := target.runDeps(ctx)
-		if err != nil {
-			return err
+		// Deps-only targets have no function to execute
+		if !node.Func.IsValid() {
+			return nil
 		}
-	}
 
-	// Deps-only targets have no function to execute
-	if !node.Func.IsValid() {
-		return nil
+		return ExecuteWithOverrides(ctx, opts.Overrides, config, run)
 	}
 
-	// Execute with runtime overrides (times, retry, watch, cache, etc.)
-	config := TargetConfig{
-		WatchPatterns: node.WatchPatterns,
-		CachePatterns: node.CachePatterns,
-		WatchDisabled: node.WatchDisabled,
-		CacheDisabled: node.CacheDisabled,
+	var args []any
+	if inst.IsValid() {
+		args = []any{inst.Interface()}
 	}
 
-	return ExecuteWithOverrides(ctx, opts.Overrides, config, func() error {
-		return callFunctionWithArgs(ctx, node.Func, inst)
-	})
+	return runRootOnce(ctx, node.Target, args, runWithDeps)
 }
 
 // shellVarFlagHelp generates synthetic flag help for shell command variables.
@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"os"
+	"sync"
 )
 
 // ExecInfo carries execution metadata through context.
@@ -13,6 +14,7 @@ type ExecInfo struct {
 	MaxNameLen int       // longest target name in the parallel group, for prefix padding
 	Printer    *Printer  // the printer for this parallel group (avoids global state races)
 	Output     io.Writer // default output writer (nil means os.Stdout)
+	Input      io.Reader // keyboard input for watch mode's status display (nil means os.Stdin)
 }
 
 // GetExecInfo retrieves ExecInfo from context.
@@ -29,6 +31,31 @@ func WithExecInfo(ctx context.Context, info ExecInfo) context.Context {
 
 type execInfoKey struct{}
 
+// syncWriter serializes writes to w, so targets running concurrently, such as
+// parallel groups started by separate Run calls, can share an invocation's output.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.w.Write(p)
+}
+
+// inputFromContext returns the input reader from the context's ExecInfo,
+// falling back to os.Stdin if not set.
+func inputFromContext(ctx context.Context) io.Reader {
+	info, ok := ctx.Value(execInfoKey{}).(ExecInfo)
+	if ok && info.Input != nil {
+		return info.Input
+	}
+
+	return os.Stdin
+}
+
 // outputFromContext returns the output writer from the context's ExecInfo,
 // falling back to os.Stdout if not set.
 func outputFromContext(ctx context.Context) io.Writer {
@@ -39,3 +66,27 @@ func outputFromContext(ctx context.Context) io.Writer {
 
 	return os.Stdout
 }
+
+// outputFile returns the file w writes to, seeing through a syncWriter, if it is one.
+func outputFile(w io.Writer) (*os.File, bool) {
+	if s, ok := w.(*syncWriter); ok {
+		w = s.w
+	}
+
+	f, ok := w.(*os.File)
+
+	return f, ok
+}
+
+// withSyncOutput returns a context whose output writer is safe for concurrent use,
+// wrapping the context's writer unless it already is.
+func withSyncOutput(ctx context.Context) context.Context {
+	info, _ := GetExecInfo(ctx)
+	if _, ok := info.Output.(*syncWriter); ok {
+		return ctx
+	}
+
+	info.Output = &syncWriter{w: outputFromContext(ctx)}
+
+	return WithExecInfo(ctx, info)
+}
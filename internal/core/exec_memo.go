@@ -0,0 +1,188 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// unexported variables.
+var (
+	errTargetExited   = errors.New("target exited via runtime.Goexit (e.g. t.FailNow) without returning")
+	errTargetPanicked = errors.New("target panicked")
+)
+
+// execMemo records which targets have run during a single invocation so each
+// target runs at most once with the same args, no matter how many dependents
+// reference it.
+type execMemo struct {
+	mu      sync.Mutex
+	entries map[execMemoRun]*execMemoEntry
+	acyclic map[*Target]bool // targets whose dependencies are known not to form a cycle
+}
+
+// checkCycles returns a *CycleError if target's dependencies form a cycle. Once a
+// target is checked, it and everything it depends on are known to be free of cycles
+// for the rest of the invocation, so nested runs of them aren't checked again.
+func (m *execMemo) checkCycles(target *Target) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.acyclic[target] {
+		return nil
+	}
+
+	err := findDepCycle([]*Target{target})
+	if err != nil {
+		return err
+	}
+
+	m.acyclic[target] = true
+
+	walkDeps(target, func(dep *Target) { m.acyclic[dep] = true })
+
+	return nil
+}
+
+// do runs fn for target with args unless it has already run (or is running) with the
+// same args in this memo. Concurrent callers wait for the in-flight run and share its
+// error. If fn panics, waiters get the panic as an error and the panic continues;
+// if it calls runtime.Goexit, they get an error saying so.
+func (m *execMemo) do(ctx context.Context, target *Target, args []any, fn func() error) error {
+	key := newExecMemoRun(target, args)
+
+	m.mu.Lock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		entry = &execMemoEntry{done: make(chan struct{})}
+		m.entries[key] = entry
+	}
+
+	m.mu.Unlock()
+
+	if ok {
+		select {
+		case <-entry.done:
+			return entry.err
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %s: %w", target.GetName(), ctx.Err())
+		}
+	}
+
+	finished := false
+
+	defer func() {
+		if finished {
+			return
+		}
+
+		// fn panicked, or called runtime.Goexit (e.g. t.FailNow in a test).
+		r := recover()
+		if r == nil {
+			entry.err = fmt.Errorf("%w: %s", errTargetExited, target.GetName())
+		} else {
+			entry.err = fmt.Errorf("%w: %s: %v", errTargetPanicked, target.GetName(), r)
+		}
+
+		close(entry.done)
+
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	entry.err = fn()
+	finished = true
+
+	close(entry.done)
+
+	return entry.err
+}
+
+// skip records target, run without args, as having run successfully, so it won't
+// run in this memo.
+func (m *execMemo) skip(target *Target) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := newExecMemoRun(target, nil)
+	if _, ok := m.entries[key]; ok {
+		return
+	}
+
+	entry := &execMemoEntry{done: make(chan struct{})}
+	close(entry.done)
+	m.entries[key] = entry
+}
+
+type execMemoEntry struct {
+	done chan struct{}
+	err  error
+}
+
+type execMemoKey struct{}
+
+// execMemoRun identifies a run of a target: the target and its args, as the target's
+// function receives them.
+type execMemoRun struct {
+	target *Target
+	args   string
+}
+
+// execMemoFromContext retrieves the invocation's execMemo from context.
+func execMemoFromContext(ctx context.Context) (*execMemo, bool) {
+	memo, ok := ctx.Value(execMemoKey{}).(*execMemo)
+	return memo, ok
+}
+
+// newExecMemoRun returns the run of target with args. Args are resolved as callFunc
+// resolves them, with zero values for those not given, so running a target without
+// args and with its zero-valued args are the same run. Other targets, such as shell
+// commands given $var values, are told apart by args as given.
+func newExecMemoRun(target *Target, args []any) execMemoRun {
+	var encoded []string
+
+	fnType := reflect.TypeOf(target.fn)
+	if fnType == nil || fnType.Kind() != reflect.Func {
+		for _, arg := range args {
+			encoded = append(encoded, encodeCacheArg(arg))
+		}
+
+		return execMemoRun{target: target, args: strings.Join(encoded, "\x00")}
+	}
+
+	argIdx := 0
+
+	for i := range fnType.NumIn() {
+		paramType := fnType.In(i)
+		if paramType.Implements(reflect.TypeFor[context.Context]()) {
+			continue
+		}
+
+		arg := reflect.Zero(paramType).Interface()
+		if argIdx < len(args) {
+			arg = args[argIdx]
+			argIdx++
+		}
+
+		encoded = append(encoded, encodeCacheArg(arg))
+	}
+
+	return execMemoRun{target: target, args: strings.Join(encoded, "\x00")}
+}
+
+// withExecMemo returns a context carrying a fresh execMemo.
+// Any memo already on ctx is shadowed, which starts a new invocation. The
+// invocation's output is made safe to share between the targets it runs at once.
+func withExecMemo(ctx context.Context) (context.Context, *execMemo) {
+	memo := &execMemo{
+		entries: make(map[execMemoRun]*execMemoEntry),
+		acyclic: make(map[*Target]bool),
+	}
+
+	return context.WithValue(withSyncOutput(ctx), execMemoKey{}, memo), memo
+}
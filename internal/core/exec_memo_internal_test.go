@@ -0,0 +1,55 @@
+// TEST-038: Execution memo internals - validates that dependency cycles are checked once per invocation
+// traces: ARCH-002
+
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestProperty_ExecMemoChecksCyclesOnce(t *testing.T) {
+	t.Parallel()
+
+	t.Run("DepsOfACheckedTargetAreNotCheckedAgain", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		c := Targ(func() {}).Name("c")
+		b := Targ(func() {}).Name("b").Deps(c)
+		a := Targ(func() {}).Name("a").Deps(b)
+
+		_, memo := withExecMemo(context.Background())
+		g.Expect(memo.checkCycles(a)).To(Succeed())
+
+		// A cycle added afterwards isn't walked again for b, which a's check covered...
+		c.Deps(b)
+		g.Expect(memo.checkCycles(b)).To(Succeed())
+
+		// ...but a new invocation finds it.
+		_, memo = withExecMemo(context.Background())
+
+		var cycleErr *CycleError
+
+		g.Expect(errors.As(memo.checkCycles(b), &cycleErr)).To(BeTrue())
+	})
+
+	t.Run("NewTargetsAreChecked", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		ok := Targ(func() {}).Name("ok")
+		a := Targ(func() {}).Name("a")
+		a.Deps(Targ(func() {}).Name("b").Deps(a))
+
+		_, memo := withExecMemo(context.Background())
+		g.Expect(memo.checkCycles(ok)).To(Succeed())
+
+		var cycleErr *CycleError
+
+		g.Expect(errors.As(memo.checkCycles(a), &cycleErr)).To(BeTrue())
+	})
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"io/fs"
+
+	internalfile "github.com/toejough/targ/internal/file"
+)
+
+// Copy copies src to dst, replacing files already at dst. dst is the path of the copy,
+// not a directory to copy into. Directories are copied recursively and symlinks are
+// recreated; modes are preserved and each file is written atomically.
+func Copy(dst, src string) error {
+	return internalfile.Copy(dst, src)
+}
+
+// CopyGlob copies the files matching patterns into dir, each at its path relative to
+// the part of its pattern before the first wildcard. It fails if nothing matches.
+func CopyGlob(dir string, patterns ...string) error {
+	copies, err := internalfile.GlobCopies(dir, patterns)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range copies {
+		err := internalfile.Copy(c.Dst, c.Src)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Mkdir creates path and any missing parents. It is not an error for path to exist.
+func Mkdir(path string) error {
+	return internalfile.Mkdir(path)
+}
+
+// Move moves src to dst, copying and removing it when they are on different file systems.
+func Move(dst, src string) error {
+	return internalfile.Move(dst, src)
+}
+
+// Rm removes path and everything under it, including read-only files. It is not
+// an error for path not to exist.
+func Rm(path string) error {
+	return internalfile.Remove(path)
+}
+
+// WriteFile writes data to path with perm atomically, via a temporary file renamed
+// into place, creating missing parent directories.
+func WriteFile(path string, data []byte, perm fs.FileMode) error {
+	return internalfile.WriteFileAtomic(path, data, perm)
+}
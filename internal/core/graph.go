@@ -0,0 +1,387 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// unexported constants.
+const (
+	graphFormatDot     = "dot"
+	graphFormatJSON    = "json"
+	graphFormatMermaid = "mermaid"
+)
+
+// unexported variables.
+var (
+	errGraphFormatInvalid  = errors.New("--format must be 'dot', 'mermaid' or 'json'")
+	errGraphFormatRequired = errors.New("--format requires a value")
+	errGraphUnknownTarget  = errors.New("unknown target")
+)
+
+// depGraph is the dependency graph of the registered targets, for --graph.
+// Edges point from a target to the dependencies it runs first.
+type depGraph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+
+	byTarget map[*Target]string
+	ids      map[string]bool
+}
+
+// addDeps adds edges for t's dependency groups, adding dependencies that aren't
+// registered commands as nodes outside any group.
+func (g *depGraph) addDeps(from string, t *Target) {
+	for i, group := range t.depGroups {
+		mode := group.mode.String()
+		if group.mode == DepModeParallel && group.collectAll {
+			mode = "collect-all"
+		}
+
+		for _, dep := range group.targets {
+			to, ok := g.byTarget[dep]
+			if !ok {
+				to = g.addNode(dep.GetName(), dep.GetName(), nil, dep)
+				g.addDeps(to, dep)
+			}
+
+			g.Edges = append(g.Edges, graphEdge{From: from, To: to, Mode: mode, Group: i + 1})
+		}
+	}
+}
+
+// addNode adds a node, making its ID unique if another target already uses it.
+func (g *depGraph) addNode(id, name string, groups []string, t *Target) string {
+	unique := id
+	for n := 2; g.ids[unique]; n++ {
+		unique = id + "#" + strconv.Itoa(n)
+	}
+
+	g.ids[unique] = true
+	g.byTarget[t] = unique
+	g.Nodes = append(g.Nodes, graphNode{ID: unique, Name: name, Groups: groups, target: t})
+
+	return unique
+}
+
+// addTree adds the command tree under node as nodes, with groups as clusters.
+func (g *depGraph) addTree(node *commandNode, path []string) {
+	if isGroupNode(node) {
+		groups := append(append([]string(nil), path...), node.Name)
+		for _, name := range sortedKeys(node.Subcommands) {
+			g.addTree(node.Subcommands[name], groups)
+		}
+
+		return
+	}
+
+	if node.Target == nil {
+		return
+	}
+
+	id := strings.Join(append(append([]string(nil), path...), node.Name), " ")
+	g.addNode(id, node.Name, path, node.Target)
+}
+
+// reachable returns the graph restricted to root and everything it depends on.
+func (g *depGraph) reachable(root string) *depGraph {
+	keep := map[string]bool{root: true}
+	queue := []string{root}
+
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+
+		for _, e := range g.Edges {
+			if e.From == from && !keep[e.To] {
+				keep[e.To] = true
+				queue = append(queue, e.To)
+			}
+		}
+	}
+
+	sub := &depGraph{Nodes: []graphNode{}, Edges: []graphEdge{}}
+
+	for _, n := range g.Nodes {
+		if keep[n.ID] {
+			sub.Nodes = append(sub.Nodes, n)
+		}
+	}
+
+	for _, e := range g.Edges {
+		if keep[e.From] {
+			sub.Edges = append(sub.Edges, e)
+		}
+	}
+
+	return sub
+}
+
+func (g *depGraph) writeDot(w io.Writer) {
+	var b strings.Builder
+
+	b.WriteString("digraph targets {\n")
+	b.WriteString("  rankdir=LR;\n")
+
+	clusters := 0
+
+	writeClusters(&b, g.Nodes, 0, "  ", func(b *strings.Builder, indent string, n graphNode) {
+		fmt.Fprintf(b, "%s%s [label=%s];\n", indent, strconv.Quote(n.ID), strconv.Quote(n.Name))
+	}, func(b *strings.Builder, indent, group string, index int) string {
+		fmt.Fprintf(b, "%ssubgraph %s {\n", indent, strconv.Quote("cluster_"+strconv.Itoa(index)))
+		fmt.Fprintf(b, "%s  label=%s;\n", indent, strconv.Quote(group))
+
+		return indent + "}\n"
+	}, &clusters)
+
+	for _, e := range g.Edges {
+		style := ""
+		if e.Mode != depModeSerialStr {
+			style = ", style=dashed"
+		}
+
+		fmt.Fprintf(&b, "  %s -> %s [label=%s%s];\n",
+			strconv.Quote(e.From), strconv.Quote(e.To), strconv.Quote(e.Mode), style)
+	}
+
+	b.WriteString("}\n")
+
+	_, _ = io.WriteString(w, b.String())
+}
+
+func (g *depGraph) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	err := enc.Encode(g)
+	if err != nil {
+		return fmt.Errorf("encoding graph: %w", err)
+	}
+
+	return nil
+}
+
+func (g *depGraph) writeMermaid(w io.Writer) {
+	ids := make(map[string]string, len(g.Nodes))
+	for i, n := range g.Nodes {
+		ids[n.ID] = "n" + strconv.Itoa(i)
+	}
+
+	var b strings.Builder
+
+	b.WriteString("flowchart LR\n")
+
+	clusters := 0
+
+	writeClusters(&b, g.Nodes, 0, "  ", func(b *strings.Builder, indent string, n graphNode) {
+		fmt.Fprintf(b, "%s%s[%s]\n", indent, ids[n.ID], mermaidLabel(n.Name))
+	}, func(b *strings.Builder, indent, group string, index int) string {
+		fmt.Fprintf(b, "%ssubgraph g%d [%s]\n", indent, index, mermaidLabel(group))
+		return indent + "end\n"
+	}, &clusters)
+
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if e.Mode != depModeSerialStr {
+			arrow = "-.->"
+		}
+
+		fmt.Fprintf(&b, "  %s %s|%s| %s\n", ids[e.From], arrow, e.Mode, ids[e.To])
+	}
+
+	_, _ = io.WriteString(w, b.String())
+}
+
+// graphEdge is a dependency: From runs To first. Group is the 1-based index of
+// the dependency group the edge belongs to; groups run in order.
+type graphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Mode  string `json:"mode"`
+	Group int    `json:"group"`
+}
+
+// graphNode is a target. Groups is the path of targ.Groups it is registered under.
+type graphNode struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Groups []string `json:"groups,omitempty"`
+
+	target *Target
+}
+
+// buildDepGraph returns the dependency graph of the command trees under roots.
+// If target is not empty, the graph is limited to that target and its dependencies.
+func buildDepGraph(roots []*commandNode, target string) (*depGraph, error) {
+	g := &depGraph{
+		Nodes:    []graphNode{},
+		Edges:    []graphEdge{},
+		byTarget: make(map[*Target]string),
+		ids:      make(map[string]bool),
+	}
+
+	for _, root := range roots {
+		g.addTree(root, nil)
+	}
+
+	err := checkDepCycles(roots)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dependencies that aren't registered commands are appended as they're found.
+	registered := len(g.Nodes)
+	for i := range registered {
+		g.addDeps(g.Nodes[i].ID, g.Nodes[i].target)
+	}
+
+	if target == "" {
+		return g, nil
+	}
+
+	for _, n := range g.Nodes[:registered] {
+		if strings.EqualFold(n.ID, target) {
+			return g.reachable(n.ID), nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", errGraphUnknownTarget, target)
+}
+
+// checkDepCycles returns *CycleError for the first dependency cycle reachable
+// from the targets under roots.
+func checkDepCycles(roots []*commandNode) error {
+	var targets []*Target
+
+	var collect func(node *commandNode)
+
+	collect = func(node *commandNode) {
+		if node.Target != nil && !isGroupNode(node) {
+			targets = append(targets, node.Target)
+		}
+
+		for _, name := range sortedKeys(node.Subcommands) {
+			collect(node.Subcommands[name])
+		}
+	}
+
+	for _, root := range roots {
+		collect(root)
+	}
+
+	return findDepCycle(targets)
+}
+
+// isGroupNode reports whether node is a targ.Group rather than a runnable target.
+func isGroupNode(node *commandNode) bool {
+	return !node.Func.IsValid() && node.ShellCommand == "" && len(node.Subcommands) > 0
+}
+
+func mermaidLabel(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, "#quot;") + `"`
+}
+
+// parseGraphArgs parses the arguments after --graph: an optional target path and
+// --format (default dot).
+func parseGraphArgs(args []string) (string, string, error) {
+	format := graphFormatDot
+
+	var target []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--format":
+			if i+1 >= len(args) {
+				return "", "", errGraphFormatRequired
+			}
+
+			i++
+			format = args[i]
+		case strings.HasPrefix(arg, "--format="):
+			format = strings.TrimPrefix(arg, "--format=")
+		default:
+			target = append(target, arg)
+		}
+	}
+
+	switch format {
+	case graphFormatDot, graphFormatJSON, graphFormatMermaid:
+	default:
+		return "", "", fmt.Errorf("%w, got %q", errGraphFormatInvalid, format)
+	}
+
+	return strings.Join(target, " "), format, nil
+}
+
+// writeClusters writes nodes, nesting those registered under groups in clusters.
+// depth is how many groups deep nodes are; open writes a cluster's header and
+// returns the line that closes it. counter numbers clusters uniquely.
+func writeClusters(
+	b *strings.Builder,
+	nodes []graphNode,
+	depth int,
+	indent string,
+	node func(b *strings.Builder, indent string, n graphNode),
+	open func(b *strings.Builder, indent, group string, index int) string,
+	counter *int,
+) {
+	var groups []string
+
+	for _, n := range nodes {
+		if len(n.Groups) == depth {
+			node(b, indent, n)
+			continue
+		}
+
+		if !slices.Contains(groups, n.Groups[depth]) {
+			groups = append(groups, n.Groups[depth])
+		}
+	}
+
+	for _, group := range groups {
+		var members []graphNode
+
+		for _, n := range nodes {
+			if len(n.Groups) > depth && n.Groups[depth] == group {
+				members = append(members, n)
+			}
+		}
+
+		*counter++
+		closing := open(b, indent, group, *counter)
+		writeClusters(b, members, depth+1, indent+"  ", node, open, counter)
+		b.WriteString(closing)
+	}
+}
+
+// writeGraph writes the dependency graph of roots as requested by the
+// arguments after --graph.
+func writeGraph(w io.Writer, roots []*commandNode, args []string) error {
+	target, format, err := parseGraphArgs(args)
+	if err != nil {
+		return err
+	}
+
+	graph, err := buildDepGraph(roots, target)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case graphFormatJSON:
+		return graph.writeJSON(w)
+	case graphFormatMermaid:
+		graph.writeMermaid(w)
+	default:
+		graph.writeDot(w)
+	}
+
+	return nil
+}
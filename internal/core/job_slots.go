@@ -0,0 +1,152 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// jobSlots bounds how many targets do their own work at once across an invocation
+// (--jobs, RunOptions.MaxParallel). Only a target's own function or shell command holds
+// a slot; waiting on dependencies does not, so a parallel dep with parallel deps of its
+// own can never deadlock on the shared limit.
+type jobSlots chan struct{}
+
+// enter waits for a free slot and returns a func to release it.
+// A nil jobSlots has no limit and admits immediately.
+func (s jobSlots) enter(ctx context.Context) (func(), error) {
+	if s == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case s <- struct{}{}:
+		return func() { <-s }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for a job slot: %w", ctx.Err())
+	}
+}
+
+// heldJobSlot is the slot held by a running target's own work. Targets started from
+// that work (e.g. a target function that runs other targets) borrow it: while any of
+// them runs, the holder is only waiting on them, so its slot is handed back to the
+// limit, and it is taken again once they have all finished.
+type heldJobSlot struct {
+	slots jobSlots
+	mu    sync.Mutex // held while taking the slot back, so lending waits for that
+	lent  int        // targets started from this slot's work that are running
+	held  bool
+}
+
+// lend hands the slot back to the limit for a target started from the holder's work.
+func (h *heldJobSlot) lend() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lent++
+	if h.lent == 1 && h.held {
+		<-h.slots
+		h.held = false
+	}
+}
+
+// reclaim takes the slot back once the last target it was lent to has finished.
+// If ctx is cancelled first, the holder carries on without it.
+func (h *heldJobSlot) reclaim(ctx context.Context) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lent--
+	if h.lent > 0 || h.held {
+		return
+	}
+
+	select {
+	case h.slots <- struct{}{}:
+		h.held = true
+	case <-ctx.Done():
+	}
+}
+
+// release gives the slot up for good.
+func (h *heldJobSlot) release() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.held {
+		<-h.slots
+		h.held = false
+	}
+}
+
+type heldJobSlotKey struct{}
+
+type jobSlotsKey struct{}
+
+// acquireJobSlot waits for a free slot in ctx's limit and returns a context marked as
+// holding it, plus a func to release it. If ctx is already in a slot holder's work,
+// the holder lends its slot back to the limit until the returned func is called: it
+// is only waiting on this work, and keeping it would deadlock once every slot is held
+// by a waiting parent.
+func acquireJobSlot(ctx context.Context) (context.Context, func(), error) {
+	slots, ok := ctx.Value(jobSlotsKey{}).(jobSlots)
+	if !ok {
+		return ctx, func() {}, nil
+	}
+
+	parent, nested := ctx.Value(heldJobSlotKey{}).(*heldJobSlot)
+	if nested {
+		parent.lend()
+	}
+
+	_, err := slots.enter(ctx) // the heldJobSlot gives the slot back, as it may be lent
+	if err != nil {
+		if nested {
+			parent.reclaim(ctx)
+		}
+
+		return ctx, nil, err
+	}
+
+	held := &heldJobSlot{slots: slots, held: true}
+
+	return context.WithValue(ctx, heldJobSlotKey{}, held), func() {
+		held.release()
+
+		if nested {
+			parent.reclaim(ctx)
+		}
+	}, nil
+}
+
+// newJobSlots returns slots admitting at most n holders, or nil (no limit) if n <= 0.
+func newJobSlots(n int) jobSlots {
+	if n <= 0 {
+		return nil
+	}
+
+	return make(jobSlots, n)
+}
+
+// runWithJobSlot runs fn while holding a job slot from ctx's limit.
+func runWithJobSlot(ctx context.Context, fn func(context.Context) error) error {
+	ctx, release, err := acquireJobSlot(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer release()
+
+	return fn(ctx)
+}
+
+// withJobSlots returns a context that allows at most n targets to run at once.
+// n <= 0 means no limit.
+func withJobSlots(ctx context.Context, n int) context.Context {
+	slots := newJobSlots(n)
+	if slots == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, jobSlotsKey{}, slots)
+}
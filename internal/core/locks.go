@@ -0,0 +1,225 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Exported variables.
+var (
+	ErrLockDeadlock = errors.New("lock deadlock")
+)
+
+// unexported variables.
+var (
+	//nolint:gochecknoglobals // named locks guard resources shared by the whole process
+	namedLocks = newLockTable()
+)
+
+// lockHolder is one target execution holding named locks. A target run from inside
+// another target's function is a child of it, and takes a lock the parent holds from
+// the parent, giving it back when done, so children needing it still run one at a time.
+type lockHolder struct {
+	name   string
+	parent *lockHolder
+}
+
+// within reports whether h is other or runs inside it.
+func (h *lockHolder) within(other *lockHolder) bool {
+	for cur := h; cur != nil; cur = cur.parent {
+		if cur == other {
+			return true
+		}
+	}
+
+	return false
+}
+
+type lockHolderKey struct{}
+
+// lockTable tracks which holder owns each named lock and which lock each blocked
+// holder waits for, so it can refuse a wait that would close a cycle.
+type lockTable struct {
+	mu      sync.Mutex
+	owners  map[string]*lockHolder
+	waiting map[*lockHolder]string
+	freed   map[string]chan struct{}
+}
+
+// acquire takes the named lock for h, waiting while another holder owns it. If the
+// owner is a target h runs inside, h takes the lock from it, and release gives it back.
+// Returns release, or ErrLockDeadlock if waiting would deadlock.
+func (t *lockTable) acquire(ctx context.Context, h *lockHolder, name string) (func(), error) {
+	announced := false
+
+	for {
+		t.mu.Lock()
+
+		owner, held := t.owners[name]
+		if !held || h.within(owner) {
+			t.owners[name] = h
+			delete(t.waiting, h)
+			t.mu.Unlock()
+
+			return func() { t.release(name, owner) }, nil
+		}
+
+		if cycle := t.findCycle(h, name); cycle != nil {
+			delete(t.waiting, h)
+			t.mu.Unlock()
+
+			return nil, fmt.Errorf("%w: %s", ErrLockDeadlock, strings.Join(cycle, ", "))
+		}
+
+		t.waiting[h] = name
+
+		freed, ok := t.freed[name]
+		if !ok {
+			freed = make(chan struct{})
+			t.freed[name] = freed
+		}
+
+		t.mu.Unlock()
+
+		if !announced {
+			Printf(ctx, "waiting for lock %s...\n", name)
+
+			announced = true
+		}
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			t.mu.Lock()
+			delete(t.waiting, h)
+			t.mu.Unlock()
+
+			return nil, fmt.Errorf("waiting for lock %s: %w", name, ctx.Err())
+		}
+	}
+}
+
+// findCycle reports the wait chain if h waiting for name would deadlock: following
+// lock owners, and whatever runs inside them that is itself waiting, leads back to h
+// or a target h runs inside. Must be called with t.mu held.
+func (t *lockTable) findCycle(h *lockHolder, name string) []string {
+	seen := make(map[*lockHolder]bool)
+
+	var visit func(lock string, path []string) []string
+
+	visit = func(lock string, path []string) []string {
+		owner := t.owners[lock]
+		if owner == nil || seen[owner] {
+			return nil
+		}
+
+		seen[owner] = true
+		path = append(path, owner.name+" holds "+lock)
+
+		if h.within(owner) {
+			return path
+		}
+
+		for waiter, next := range t.waiting {
+			if !waiter.within(owner) {
+				continue
+			}
+
+			cycle := visit(next, append(slices.Clip(path), waiter.name+" waits for "+next))
+			if cycle != nil {
+				return cycle
+			}
+		}
+
+		return nil
+	}
+
+	return visit(name, []string{h.name + " waits for " + name})
+}
+
+// release hands the named lock back to prev, the holder it was taken from, or frees
+// it if prev is nil.
+func (t *lockTable) release(name string, prev *lockHolder) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if prev != nil {
+		t.owners[name] = prev
+	} else {
+		delete(t.owners, name)
+	}
+
+	if freed, ok := t.freed[name]; ok {
+		close(freed)
+		delete(t.freed, name)
+	}
+}
+
+// acquireLocks takes the named locks for the target called name, in sorted order so
+// targets sharing several locks can't deadlock against each other. It returns a context
+// marking them as held, so targets run from inside can take them over, and a func
+// releasing them.
+func acquireLocks(ctx context.Context, name string, locks []string) (context.Context, func(), error) {
+	if len(locks) == 0 {
+		return ctx, func() {}, nil
+	}
+
+	parent, _ := ctx.Value(lockHolderKey{}).(*lockHolder)
+	holder := &lockHolder{name: name, parent: parent}
+
+	sorted := slices.Clone(locks)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	releases := make([]func(), 0, len(sorted))
+	releaseAll := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+
+	for _, lock := range sorted {
+		release, err := namedLocks.acquire(ctx, holder, lock)
+		if err != nil {
+			releaseAll()
+			return ctx, nil, err
+		}
+
+		releases = append(releases, release)
+	}
+
+	return context.WithValue(ctx, lockHolderKey{}, holder), releaseAll, nil
+}
+
+func newLockTable() *lockTable {
+	return &lockTable{
+		owners:  make(map[string]*lockHolder),
+		waiting: make(map[*lockHolder]string),
+		freed:   make(map[string]chan struct{}),
+	}
+}
+
+// runExclusive runs fn for the target called name while holding a job slot and
+// the target's named locks. The slot is taken first, so a lock holder never waits
+// for a slot and the only waits that can cycle are between locks, which acquire detects.
+func runExclusive(
+	ctx context.Context,
+	name string,
+	locks []string,
+	fn func(context.Context) error,
+) error {
+	return runWithJobSlot(ctx, func(ctx context.Context) error {
+		ctx, release, err := acquireLocks(ctx, name, locks)
+		if err != nil {
+			return err
+		}
+
+		defer release()
+
+		return fn(ctx)
+	})
+}
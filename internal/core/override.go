@@ -5,11 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	internalfile "github.com/toejough/targ/internal/file"
+	internalsh "github.com/toejough/targ/internal/sh"
 )
 
 // RuntimeOverrides holds CLI flags that override Target compile-time settings.
@@ -17,6 +19,12 @@ type RuntimeOverrides struct {
 	Times             int           // Number of times to run (--times N)
 	Retry             bool          // Continue on failure (--retry)
 	Watch             []string      // File patterns to watch (--watch "pattern")
+	WatchDebounce     time.Duration // Wait for changes to settle (--watch-debounce D)
+	WatchMaxWait      time.Duration // Longest a run waits for changes to settle (--watch-max-wait D)
+	WatchOnBusy       string        // Changes during a run: queue, drop or restart (--watch-on-busy)
+	WatchGrace        time.Duration // Time between SIGTERM and SIGKILL on restart (--watch-grace D)
+	WatchStatus       bool          // Clear the screen and sum up each run, with r/q keys (--watch-status)
+	WatchDeps         bool          // Also watch what dependencies cache and watch (--watch-deps)
 	Cache             []string      // File patterns for caching (--cache "pattern")
 	CacheDir          string        // Directory for cache files (--cache-dir "path")
 	BackoffInitial    time.Duration // Initial backoff delay (--backoff D,M)
@@ -25,6 +33,10 @@ type RuntimeOverrides struct {
 	While             string        // Shell command to check (--while "cmd")
 	Deps              []string      // Dependency target paths (--deps target1 target2)
 	Parallel          bool          // Run multiple targets concurrently (--parallel or -p)
+	Jobs              int           // Max targets running at once (--jobs N or -j N)
+	DryRun            bool          // Print the plan without running anything (--dry-run)
+	Explain           bool          // Say why cached targets missed the cache (--explain)
+	RespectIgnore     bool          // Leave out what ignore files ignore from cache and watch patterns (--respect-ignore)
 }
 
 // hasAny returns true if any override is set.
@@ -38,23 +50,58 @@ func (o RuntimeOverrides) hasAny() bool {
 		len(o.Deps) > 0
 }
 
+// watchOptions returns base with the --watch-* option flags applied.
+func (o RuntimeOverrides) watchOptions(base internalfile.WatchOptions) internalfile.WatchOptions {
+	if o.WatchDebounce > 0 {
+		base.Debounce = o.WatchDebounce
+	}
+
+	if o.WatchMaxWait > 0 {
+		base.MaxWait = o.WatchMaxWait
+	}
+
+	if o.WatchGrace > 0 {
+		base.GracePeriod = o.WatchGrace
+	}
+
+	if policy, ok := watchBusyPolicies[o.WatchOnBusy]; ok {
+		base.OnBusy = policy
+	}
+
+	return base
+}
+
 // TargetConfig holds compile-time configuration from a Target definition.
 type TargetConfig struct {
-	WatchPatterns []string
-	CachePatterns []string
-	WatchDisabled bool // True if target explicitly allows CLI --watch
-	CacheDisabled bool // True if target explicitly allows CLI --cache
-	HasDeps       bool // True if target has .Deps() configured
+	WatchPatterns    []string
+	DepWatchPatterns []string // What dependencies cache and watch, with FromDeps or --watch-deps
+	WatchOptions     internalfile.WatchOptions
+	CachePatterns    []string
+	OutputPatterns   []string // Declared outputs that must be up to date for a cache hit
+	WatchDisabled    bool     // True if target explicitly allows CLI --watch
+	CacheDisabled    bool     // True if target explicitly allows CLI --cache
+	HasDeps          bool     // True if target has .Deps() configured
+	// Leave out what ignore files ignore from cache and watch patterns (.RespectIgnoreFiles())
+	RespectIgnoreFiles bool
+
+	// cacheKey identifies the target and carries args, command text and env for cache invalidation.
+	cacheKey cacheKey
+	// cacheDir is the target's own cache directory (.CacheDir()), used unless --cache-dir is given.
+	cacheDir string
+	// beforeRun, if set, starts each run, ahead of the cache check. It returns the
+	// context for the rest of the run, and whether the run should go on.
+	beforeRun func(context.Context) (context.Context, bool, error)
 }
 
 // ExecuteWithOverrides runs a function with runtime overrides applied.
-// The fn argument should be a function that executes the command once.
+// The fn argument should be a function that executes the command once with the
+// context it is given, which watch mode cancels to restart a run.
 // The config parameter holds compile-time Target configuration for conflict detection.
 func ExecuteWithOverrides(
 	ctx context.Context,
 	overrides RuntimeOverrides,
 	config TargetConfig,
-	fn func() error,
+	fn func(context.Context) error,
 ) error {
 	// Check for conflicts between CLI overrides and Target config
 	err := checkConflicts(overrides, config)
@@ -62,35 +109,61 @@ func ExecuteWithOverrides(
 		return err
 	}
 
+	if overrides.CacheDir == "" {
+		overrides.CacheDir = config.cacheDir
+	}
+
 	// If no overrides are active and no compile-time config, just run the function
-	if !overrides.hasAny() && len(config.CachePatterns) == 0 {
-		return fn()
+	if !overrides.hasAny() && len(config.CachePatterns) == 0 &&
+		len(config.WatchPatterns) == 0 && len(config.DepWatchPatterns) == 0 && config.beforeRun == nil {
+		return fn(ctx)
 	}
 
 	// Merge cache patterns: CLI overrides take precedence if Target allows (disabled)
 	// Otherwise use Target's patterns (conflict was checked above)
-	allCachePatterns := overrides.Cache
-	if len(config.CachePatterns) > 0 && len(overrides.Cache) == 0 {
-		allCachePatterns = config.CachePatterns
-	}
+	allCachePatterns := resolvePatterns(overrides.Cache, config.CachePatterns)
 
-	// Merge watch patterns similarly
-	allWatchPatterns := overrides.Watch
-	if len(config.WatchPatterns) > 0 && len(overrides.Watch) == 0 {
-		allWatchPatterns = config.WatchPatterns
-	}
+	// Merge watch patterns similarly, adding what dependencies cache and watch
+	allWatchPatterns := slices.Concat(
+		resolvePatterns(overrides.Watch, config.WatchPatterns),
+		config.DepWatchPatterns,
+	)
+
+	respectIgnore := overrides.RespectIgnore || config.RespectIgnoreFiles
 
 	// Create the execution function that handles cache, times, retry, etc.
-	execFn := func() error {
-		return executeOnce(ctx, overrides, allCachePatterns, fn)
+	key := config.cacheKey
+	key.Patterns = allCachePatterns
+	key.RespectIgnoreFiles = respectIgnore
+
+	execFn := func(ctx context.Context) error {
+		if config.beforeRun != nil {
+			var (
+				proceed bool
+				err     error
+			)
+
+			ctx, proceed, err = config.beforeRun(ctx)
+			if err != nil || !proceed {
+				return err
+			}
+		}
+
+		return executeOnce(ctx, overrides, key, config.OutputPatterns, func() error {
+			return fn(ctx)
+		})
 	}
 
 	// If watch mode is enabled (from CLI or Target), wrap in watch loop
 	if len(allWatchPatterns) > 0 {
-		return executeWithWatch(ctx, allWatchPatterns, execFn)
+		opts := overrides.watchOptions(config.WatchOptions)
+		opts.RespectIgnoreFiles = opts.RespectIgnoreFiles || respectIgnore
+		patterns := excludeCacheDir(allWatchPatterns, overrides.CacheDir)
+
+		return executeWithWatch(ctx, patterns, opts, overrides.WatchStatus, execFn)
 	}
 
-	return execFn()
+	return execFn(ctx)
 }
 
 // ExtractOverrides parses runtime override flags from args.
@@ -127,6 +200,17 @@ func ExtractOverrides(args []string) (RuntimeOverrides, []string, error) {
 			continue
 		}
 
+		if !seenTarget {
+			handled, err := handleJobsFlag(arg, args, i, &overrides, &skip)
+			if err != nil {
+				return RuntimeOverrides{}, nil, err
+			}
+
+			if handled {
+				continue
+			}
+		}
+
 		// Track when we see a target name (non-flag after first arg)
 		if !strings.HasPrefix(arg, "-") && i > 0 {
 			seenTarget = true
@@ -162,28 +246,37 @@ var (
 		"--deps conflicts with target's dependency configuration; dependencies must be defined in one place (code or CLI)",
 	)
 	errDepsRequiresTarget = errors.New("--deps requires at least one target")
+	errJobsInvalid        = errors.New("--jobs requires a positive number")
 	errTimesRequiresValue = errors.New("--times requires a numeric value")
 	errWatchConflict      = errors.New(
 		"--watch conflicts with target's watch configuration; use .Watch(targ.Disabled) to allow CLI override",
 	)
-	errWatchRequiresPattern = errors.New("--watch requires a pattern")
-	errWhileRequiresCommand = errors.New("--while requires a command")
+	errWatchOnBusyInvalid       = errors.New("--watch-on-busy must be 'queue', 'drop' or 'restart'")
+	errWatchOptionRequiresValue = errors.New("watch option requires a value")
+	errWatchRequiresPattern     = errors.New("--watch requires a pattern")
+	errWhileRequiresCommand     = errors.New("--while requires a command")
 	//nolint:gochecknoglobals // DI injection point for testing
 	fileWatch = func(
 		ctx context.Context,
 		patterns []string,
 		opts internalfile.WatchOptions,
-		callback func(internalfile.ChangeSet) error,
+		callback func(context.Context, internalfile.ChangeSet) error,
 	) error {
-		return internalfile.Watch(
+		return internalfile.WatchContext(
 			ctx,
 			patterns,
 			opts,
 			callback,
-			func(p []string) ([]string, error) { return internalfile.Match(p...) },
+			internalfile.MatchWithOptions,
 			nil,
 		)
 	}
+	//nolint:gochecknoglobals // lookup table for --watch-on-busy
+	watchBusyPolicies = map[string]internalfile.WatchBusyPolicy{
+		"queue":   internalfile.WatchQueue,
+		"drop":    internalfile.WatchDrop,
+		"restart": internalfile.WatchRestart,
+	}
 )
 
 // overrideFlagHandler is a function that handles an override flag.
@@ -214,29 +307,6 @@ func applyBackoffSleep(
 	return time.Duration(float64(delay) * multiplier), nil
 }
 
-// checkCacheHit returns true if cache is valid (skip execution).
-func checkCacheHit(patterns []string, cacheDir string) (bool, error) {
-	if len(patterns) == 0 {
-		return false, nil
-	}
-
-	if cacheDir == "" {
-		cacheDir = ".targ-cache"
-	}
-
-	changed, err := internalfile.Checksum(
-		patterns,
-		cacheDir+"/override.sum",
-		func(p []string) ([]string, error) { return internalfile.Match(p...) },
-		nil,
-	)
-	if err != nil {
-		return false, fmt.Errorf("cache check failed: %w", err)
-	}
-
-	return !changed, nil
-}
-
 // checkConflicts verifies CLI overrides don't conflict with Target config.
 func checkConflicts(overrides RuntimeOverrides, config TargetConfig) error {
 	// Check watch conflict: CLI --watch vs Target.Watch()
@@ -319,16 +389,23 @@ func executeIteration(
 func executeOnce(
 	ctx context.Context,
 	overrides RuntimeOverrides,
-	cachePatterns []string,
+	key cacheKey,
+	outputPatterns []string,
 	fn func() error,
 ) error {
-	cacheHit, err := checkCacheHit(cachePatterns, overrides.CacheDir)
-	if err != nil {
-		return err
-	}
+	var cached *cacheRun
 
-	if cacheHit {
-		return nil
+	if len(key.Patterns) > 0 {
+		run, hit, err := lookupCache(ctx, key, outputPatterns, overrides.CacheDir)
+		if err != nil {
+			return fmt.Errorf("cache check failed: %w", err)
+		}
+
+		if hit {
+			return nil
+		}
+
+		cached = run
 	}
 
 	iterations := 1
@@ -352,28 +429,60 @@ func executeOnce(
 		}
 	}
 
-	return lastErr
+	if lastErr != nil || cached == nil {
+		return lastErr
+	}
+
+	return cached.commit(ctx)
 }
 
-// executeWithWatch runs the function and re-runs on file changes.
+// executeWithWatch runs the function and re-runs on file changes. The first run is
+// part of the watch, so a run that never returns (a dev server) can be restarted.
+// Runs are given opts' grace period to exit when cancelled.
 func executeWithWatch(
 	ctx context.Context,
 	patterns []string,
-	fn func() error,
+	opts internalfile.WatchOptions,
+	status bool,
+	fn func(context.Context) error,
 ) error {
-	// Run once initially
-	err := fn()
-	if err != nil {
-		return err
+	opts.RunFirst = true
+
+	run := func(ctx context.Context, changes internalfile.ChangeSet) error {
+		return fn(withChanges(internalsh.WithGracePeriod(ctx, opts.Grace()), changes))
 	}
 
-	// Watch for changes and re-run. Watch runs until error (including context cancel).
-	return fmt.Errorf(
-		"watching files: %w",
-		fileWatch(ctx, patterns, internalfile.WatchOptions{}, func(_ internalfile.ChangeSet) error {
-			return fn()
-		}),
-	)
+	if !status {
+		// Watch runs until error (including context cancel or a failed run).
+		return fmt.Errorf("watching files: %w", fileWatch(ctx, patterns, opts, run))
+	}
+
+	// With the status display, failed runs are reported and watching continues
+	// until q is pressed.
+	display, end := newWatchStatus(ctx)
+	defer end()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-display.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	opts.Rerun = display.rerun
+
+	err := fileWatch(ctx, patterns, opts, display.wrap(run))
+
+	select {
+	case <-display.quit:
+		return nil
+	default:
+		return fmt.Errorf("watching files: %w", err)
+	}
 }
 
 // extractDepsVariadic extracts --deps and its variadic arguments.
@@ -553,6 +662,82 @@ func handleDepModeFlag(
 	return false, nil
 }
 
+func handleDryRunFlag(
+	arg string,
+	_ []string,
+	_ int,
+	overrides *RuntimeOverrides,
+	_ *bool,
+) (bool, error) {
+	if arg == "--dry-run" {
+		overrides.DryRun = true
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func handleExplainFlag(
+	arg string,
+	_ []string,
+	_ int,
+	overrides *RuntimeOverrides,
+	_ *bool,
+) (bool, error) {
+	if arg == "--explain" {
+		overrides.Explain = true
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func handleJobsFlag(
+	arg string,
+	args []string,
+	index int,
+	overrides *RuntimeOverrides,
+	skip *bool,
+) (bool, error) {
+	value, ok := strings.CutPrefix(arg, "--jobs=")
+	if !ok {
+		if arg != "--jobs" && arg != "-j" {
+			return false, nil
+		}
+
+		if index+1 >= len(args) {
+			return true, errJobsInvalid
+		}
+
+		value = args[index+1]
+		*skip = true
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return true, fmt.Errorf("%w, got %q", errJobsInvalid, value)
+	}
+
+	overrides.Jobs = n
+
+	return true, nil
+}
+
+func handleRespectIgnoreFlag(
+	arg string,
+	_ []string,
+	_ int,
+	overrides *RuntimeOverrides,
+	_ *bool,
+) (bool, error) {
+	if arg == "--respect-ignore" {
+		overrides.RespectIgnore = true
+		return true, nil
+	}
+
+	return false, nil
+}
+
 func handleRetryFlag(
 	arg string,
 	_ []string,
@@ -631,6 +816,79 @@ func handleWatchFlag(
 	return false, nil
 }
 
+// handleWatchOptionFlags handles --watch-debounce, --watch-max-wait, --watch-grace
+// and --watch-on-busy.
+func handleWatchOptionFlags(
+	arg string,
+	args []string,
+	index int,
+	overrides *RuntimeOverrides,
+	skip *bool,
+) (bool, error) {
+	for _, name := range []string{"--watch-debounce", "--watch-max-wait", "--watch-grace", "--watch-on-busy"} {
+		value, ok := strings.CutPrefix(arg, name+"=")
+		if !ok {
+			if arg != name {
+				continue
+			}
+
+			if index+1 >= len(args) {
+				return true, fmt.Errorf("%s: %w", name, errWatchOptionRequiresValue)
+			}
+
+			value = args[index+1]
+			*skip = true
+		}
+
+		if name == "--watch-on-busy" {
+			if _, ok := watchBusyPolicies[value]; !ok {
+				return true, fmt.Errorf("%w, got %q", errWatchOnBusyInvalid, value)
+			}
+
+			overrides.WatchOnBusy = value
+
+			return true, nil
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return true, fmt.Errorf("invalid %s value %q: %w", name, value, err)
+		}
+
+		switch name {
+		case "--watch-debounce":
+			overrides.WatchDebounce = d
+		case "--watch-max-wait":
+			overrides.WatchMaxWait = d
+		default:
+			overrides.WatchGrace = d
+		}
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func handleWatchSwitchFlags(
+	arg string,
+	_ []string,
+	_ int,
+	overrides *RuntimeOverrides,
+	_ *bool,
+) (bool, error) {
+	switch arg {
+	case "--watch-status":
+		overrides.WatchStatus = true
+	case "--watch-deps":
+		overrides.WatchDeps = true
+	default:
+		return false, nil
+	}
+
+	return true, nil
+}
+
 func handleWhileFlag(
 	arg string,
 	args []string,
@@ -666,12 +924,17 @@ func overrideFlagHandlers() []overrideFlagHandler {
 	return []overrideFlagHandler{
 		handleTimesFlag,
 		handleWatchFlag,
+		handleWatchOptionFlags,
+		handleWatchSwitchFlags,
 		handleCacheFlag,
 		handleCacheDirFlag,
+		handleRespectIgnoreFlag,
 		handleRetryFlag,
 		handleBackoffFlag,
 		handleDepModeFlag,
 		handleWhileFlag,
+		handleDryRunFlag,
+		handleExplainFlag,
 	}
 }
 
@@ -711,3 +974,12 @@ func processOverrideFlag(
 
 	return false, nil
 }
+
+// resolvePatterns returns the CLI patterns if any were given, otherwise the target's own.
+func resolvePatterns(override, configured []string) []string {
+	if len(override) > 0 {
+		return override
+	}
+
+	return configured
+}
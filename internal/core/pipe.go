@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	internalsh "github.com/toejough/targ/internal/sh"
+)
+
+// Pipeline is a series of commands built with Pipe, each reading the previous one's
+// stdout, as with | in a shell, but without running one:
+//
+//	out, err := core.Pipe(core.Cmd("go", "list", "./..."), core.Cmd("grep", "-v", "vendor")).Output(ctx)
+//
+// Every command runs in its own process group, and all of them are killed when ctx
+// is cancelled.
+type Pipeline struct {
+	commands []*Command
+	verbose  bool
+}
+
+// Lines runs the pipeline and returns the last command's stdout split into lines,
+// without line endings.
+func (p *Pipeline) Lines(ctx context.Context) ([]string, error) {
+	out, err := p.Output(ctx)
+	return splitLines(out), err
+}
+
+// Output runs the pipeline and returns the last command's stdout.
+func (p *Pipeline) Output(ctx context.Context) (string, error) {
+	var buf internalsh.SafeBuffer
+
+	err := p.run(ctx, &buf)
+
+	return buf.String(), err
+}
+
+// Run runs the pipeline and waits for every command to exit. The first command's
+// stdin and the last one's stdout are set as for a single command, e.g. with StdinFile
+// and StdoutFile; each command's stderr is its own. Like a shell with pipefail set,
+// it returns the error of the last command to fail, so a failure anywhere fails the
+// pipeline.
+func (p *Pipeline) Run(ctx context.Context) error {
+	return p.run(ctx, nil)
+}
+
+// String returns the pipeline's command lines, quoted for display and joined with |.
+func (p *Pipeline) String() string {
+	lines := make([]string, len(p.commands))
+	for i, c := range p.commands {
+		lines[i] = c.String()
+	}
+
+	return strings.Join(lines, " | ")
+}
+
+// Verbose prints the pipeline, prefixed with "+", before running it.
+func (p *Pipeline) Verbose() *Pipeline {
+	p.verbose = true
+	return p
+}
+
+// run runs the pipeline with the last command's stdout going to stdout or, if it
+// is nil, to where that command sends it.
+func (p *Pipeline) run(ctx context.Context, stdout io.Writer) error {
+	env, done := commandEnv(ctx)
+	defer done()
+
+	var files []*os.File
+
+	defer func() { closeFiles(files) }()
+
+	stages := make(internalsh.Pipeline, len(p.commands))
+
+	for i, c := range p.commands {
+		// Between commands, the pipes take the place of stdin and stdout.
+		stage := *c
+
+		var out io.Writer
+
+		if i > 0 {
+			stage.stdin, stage.stdinFile = nil, ""
+		}
+
+		if i == len(p.commands)-1 {
+			out = stdout
+		} else {
+			stage.stdout, stage.stdoutFile = nil, ""
+		}
+
+		cmd, opened, err := stage.shellCommand(env, out, nil)
+		files = append(files, opened...)
+
+		if err != nil {
+			return err
+		}
+
+		stages[i] = cmd
+	}
+
+	if p.verbose {
+		_, _ = fmt.Fprintln(env.Stdout, "+", p.String())
+	}
+
+	return stages.Run(ctx)
+}
+
+// Pipe returns a pipeline that runs cmds together, each reading the previous one's stdout.
+func Pipe(cmds ...*Command) *Pipeline {
+	return &Pipeline{commands: cmds}
+}
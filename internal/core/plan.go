@@ -0,0 +1,329 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dryRun carries --dry-run state through an invocation. Where a target would run,
+// the execution paths print its plan instead: dependencies first, in order, with
+// parallel groups indented, then the target with its resolved settings.
+type dryRun struct {
+	timeout time.Duration // invocation-wide --timeout, shown in the header
+
+	mu     sync.Mutex
+	header bool
+	indent int
+	seen   map[*Target]bool
+}
+
+// planNode prints the plan for a target invoked from the command line: its
+// dependencies, then the target with the runtime overrides applied to it.
+// command is the shell command after $var substitution, if the target is one.
+func (d *dryRun) planNode(
+	ctx context.Context,
+	node *commandNode,
+	overrides RuntimeOverrides,
+	config TargetConfig,
+	command string,
+) error {
+	err := checkConflicts(overrides, config)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	plan := &planBuilder{ctx: ctx, seen: d.seen}
+
+	if node.Target != nil {
+		d.seen[node.Target] = true
+
+		err := plan.depGroups(node.Target.depGroups, node.Target.concurrency, d.indent)
+		if err != nil {
+			return err
+		}
+	}
+
+	key := config.cacheKey
+	key.Patterns = resolvePatterns(overrides.Cache, config.CachePatterns)
+
+	dir := overrides.CacheDir
+	if dir == "" {
+		dir = config.cacheDir
+	}
+
+	cacheDetail, err := planCacheDetail(ctx, key, config.OutputPatterns, dir)
+	if err != nil {
+		return err
+	}
+
+	details := planDetails(
+		cacheDetail,
+		0,
+		overrides.Times,
+		overrides.Retry,
+		overrides.BackoffInitial,
+		overrides.BackoffMultiplier,
+	)
+
+	if overrides.While != "" {
+		details = append(details, fmt.Sprintf("while %q", overrides.While))
+	}
+
+	details = appendListDetail(details, "watch", resolvePatterns(overrides.Watch, config.WatchPatterns))
+	details = appendListDetail(details, "lock", node.Locks)
+
+	name := node.Name
+	if !node.Func.IsValid() && node.ShellCommand == "" {
+		name += " (deps only)"
+	}
+
+	plan.line(d.indent, name, details)
+
+	if command != "" {
+		plan.line(d.indent+1, "$ "+command, nil)
+	}
+
+	d.print(ctx, plan.String())
+
+	return nil
+}
+
+// planParallel prints the line that the plans of roots run with --parallel are
+// indented under.
+func (d *dryRun) planParallel(ctx context.Context) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.print(ctx, "parallel:\n")
+	d.indent = 1
+}
+
+// print writes text, preceded by the dry-run header the first time.
+// Must be called with d.mu held.
+func (d *dryRun) print(ctx context.Context, text string) {
+	if !d.header {
+		header := "Dry run, nothing will be executed"
+		if d.timeout > 0 {
+			header += fmt.Sprintf(" (timeout %s)", d.timeout)
+		}
+
+		text = header + ":\n" + text
+		d.header = true
+	}
+
+	Print(ctx, text)
+}
+
+type dryRunKey struct{}
+
+// planBuilder renders the plan for one invoked target.
+type planBuilder struct {
+	ctx  context.Context //nolint:containedctx // used for cache lookups while rendering
+	b    strings.Builder
+	seen map[*Target]bool
+}
+
+func (p *planBuilder) String() string {
+	return p.b.String()
+}
+
+// depGroups renders dependency groups in the order runDeps runs them.
+func (p *planBuilder) depGroups(groups []depGroup, concurrency, depth int) error {
+	for _, group := range groups {
+		if group.mode != DepModeParallel {
+			for _, dep := range group.targets {
+				err := p.target(dep, depth)
+				if err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		label := "parallel"
+
+		switch {
+		case group.collectAll && concurrency > 0:
+			label += fmt.Sprintf(" (collect all errors, %d at once)", concurrency)
+		case group.collectAll:
+			label += " (collect all errors)"
+		case concurrency > 0:
+			label += fmt.Sprintf(" (%d at once)", concurrency)
+		}
+
+		p.line(depth, label+":", nil)
+
+		for _, dep := range group.targets {
+			err := p.member(dep, depth+1)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *planBuilder) line(depth int, text string, details []string) {
+	p.b.WriteString(strings.Repeat("  ", depth))
+	p.b.WriteString(text)
+
+	if len(details) > 0 {
+		p.b.WriteString(" [" + strings.Join(details, ", ") + "]")
+	}
+
+	p.b.WriteString("\n")
+}
+
+// member renders a member of a parallel group: the target, then its own
+// dependencies indented under it, so they aren't mistaken for its siblings.
+func (p *planBuilder) member(t *Target, depth int) error {
+	if p.seen[t] {
+		p.line(depth, t.GetName()+" (already run above)", nil)
+		return nil
+	}
+
+	p.seen[t] = true
+
+	err := p.targetLine(t, depth)
+	if err != nil {
+		return err
+	}
+
+	return p.depGroups(t.depGroups, t.concurrency, depth+1)
+}
+
+// target renders a dependency: its own dependencies, then the target with the
+// settings Target.Run applies. A target already planned runs only once, so later
+// references are marked rather than expanded again.
+func (p *planBuilder) target(t *Target, depth int) error {
+	if p.seen[t] {
+		p.line(depth, t.GetName()+" (already run above)", nil)
+		return nil
+	}
+
+	p.seen[t] = true
+
+	err := p.depGroups(t.depGroups, t.concurrency, depth)
+	if err != nil {
+		return err
+	}
+
+	return p.targetLine(t, depth)
+}
+
+// targetLine renders t with the settings Target.Run applies, and its shell command.
+func (p *planBuilder) targetLine(t *Target, depth int) error {
+	name := t.GetName()
+
+	var (
+		cacheDetail string
+		err         error
+	)
+
+	if len(t.cache) > 0 {
+		cacheDetail, err = planCacheDetail(p.ctx, t.buildCacheKey(nil), t.outputs, t.cacheDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	details := planDetails(
+		cacheDetail,
+		t.timeout,
+		t.times,
+		t.retry,
+		t.backoffInitial,
+		t.backoffMultiply,
+	)
+	details = appendListDetail(details, "watch", t.watch)
+	details = appendListDetail(details, "lock", t.locks)
+
+	if t.fn == nil {
+		name += " (deps only)"
+	}
+
+	p.line(depth, name, details)
+
+	if cmd, ok := t.fn.(string); ok {
+		p.line(depth+1, "$ "+cmd, nil)
+	}
+
+	return nil
+}
+
+func appendListDetail(details []string, label string, values []string) []string {
+	if len(values) == 0 {
+		return details
+	}
+
+	return append(details, label+" "+strings.Join(values, " "))
+}
+
+// dryRunFromContext returns the dry-run state carried by ctx, if the invocation is a dry run.
+func dryRunFromContext(ctx context.Context) (*dryRun, bool) {
+	d, ok := ctx.Value(dryRunKey{}).(*dryRun)
+	return d, ok
+}
+
+// planCacheDetail describes what the cache would do for key, or "" if key has no patterns.
+func planCacheDetail(ctx context.Context, key cacheKey, outputs []string, dir string) (string, error) {
+	if len(key.Patterns) == 0 {
+		return "", nil
+	}
+
+	status, err := peekCache(ctx, key, outputs, dir)
+	if err != nil {
+		return "", fmt.Errorf("cache check failed: %w", err)
+	}
+
+	return status, nil
+}
+
+func planDetails(
+	cacheDetail string,
+	timeout time.Duration,
+	times int,
+	retry bool,
+	backoffInitial time.Duration,
+	backoffMultiply float64,
+) []string {
+	var details []string
+
+	if cacheDetail != "" {
+		details = append(details, cacheDetail)
+	}
+
+	if timeout > 0 {
+		details = append(details, fmt.Sprintf("timeout %s", timeout))
+	}
+
+	if times > 0 {
+		details = append(details, fmt.Sprintf("times %d", times))
+	}
+
+	if retry {
+		details = append(details, "retry")
+	}
+
+	if backoffInitial > 0 {
+		details = append(details, fmt.Sprintf("backoff %s x%g", backoffInitial, backoffMultiply))
+	}
+
+	return details
+}
+
+// withDryRun returns a context in which targets print their plan instead of running.
+func withDryRun(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, &dryRun{
+		timeout: timeout,
+		seen:    make(map[*Target]bool),
+	})
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 )
 
@@ -37,6 +38,37 @@ func (e *ConflictError) Error() string {
 	return builder.String()
 }
 
+// CycleError represents a dependency cycle: a target that depends on itself,
+// directly or through other targets.
+type CycleError struct {
+	Path []CycleStep // Targets in dependency order; the first is repeated at the end
+}
+
+func (e *CycleError) Error() string {
+	var builder strings.Builder
+
+	builder.WriteString(fmt.Sprintf("targ: dependency cycle: %q depends on itself:", e.Path[0].Name))
+
+	for _, step := range e.Path {
+		builder.WriteString("\n  - ")
+		builder.WriteString(step.Name)
+
+		if step.Source != "" {
+			builder.WriteString(" (" + step.Source + ")")
+		}
+	}
+
+	builder.WriteString("\nRemove one of these dependencies to resolve.")
+
+	return builder.String()
+}
+
+// CycleStep is one target in a dependency cycle.
+type CycleStep struct {
+	Name   string // Target name
+	Source string // File and line of the Targ() call that created the target
+}
+
 // DeregistrationError represents an error when deregistering a package with no targets.
 type DeregistrationError struct {
 	PackagePath string
@@ -75,6 +107,11 @@ func (s *RegistryState) resolveRegistry() ([]any, []string, error) {
 		return nil, nil, err
 	}
 
+	err = detectCycles(filtered)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	clearLocalTargetSources(filtered, s.mainModuleProvider)
 
 	return filtered, deregisteredPkgs, nil
@@ -193,6 +230,30 @@ func detectConflicts(items []any) error {
 	return nil
 }
 
+// detectCycles checks the dependencies of registered targets, including those
+// inside groups, for cycles. Returns nil if there are none, or *CycleError for
+// the first cycle found.
+func detectCycles(items []any) error {
+	var targets []*Target
+
+	var collect func(items []any)
+
+	collect = func(items []any) {
+		for _, item := range items {
+			switch v := item.(type) {
+			case *Target:
+				targets = append(targets, v)
+			case *TargetGroup:
+				collect(v.members)
+			}
+		}
+	}
+
+	collect(items)
+
+	return findDepCycle(targets)
+}
+
 // filterItems processes each item and decides whether to keep or remove it.
 func filterItems(items []any, deregistrations []Deregistration, matchCounts map[string]int) []any {
 	result := make([]any, 0, len(items))
@@ -216,6 +277,64 @@ func filterItems(items []any, deregistrations []Deregistration, matchCounts map[
 	return result
 }
 
+// findDepCycle walks the dependencies of targets depth-first and returns
+// *CycleError for the first cycle found, or nil.
+func findDepCycle(targets []*Target) error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+
+	state := make(map[*Target]int)
+
+	var path []*Target
+
+	var visit func(t *Target) error
+
+	visit = func(t *Target) error {
+		switch state[t] {
+		case done:
+			return nil
+		case visiting:
+			start := slices.Index(path, t)
+			cycle := append(slices.Clone(path[start:]), t)
+
+			steps := make([]CycleStep, 0, len(cycle))
+			for _, c := range cycle {
+				steps = append(steps, CycleStep{Name: c.GetName(), Source: c.createdAt})
+			}
+
+			return &CycleError{Path: steps}
+		}
+
+		state[t] = visiting
+		path = append(path, t)
+
+		for _, group := range t.depGroups {
+			for _, dep := range group.targets {
+				err := visit(dep)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[t] = done
+
+		return nil
+	}
+
+	for _, t := range targets {
+		err := visit(t)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // getSourcePkg extracts the source package from a Target or TargetGroup.
 // Returns empty string for other types.
 func getSourcePkg(item any) string {
@@ -607,6 +607,91 @@ func TestProperty_DetectConflicts_CatchesGroupNameConflicts(t *testing.T) {
 	})
 }
 
+// TestProperty_DetectCycles_AllowsAcyclicDeps verifies that targets whose
+// dependencies only point forward (a DAG) pass resolution.
+func TestProperty_DetectCycles_AllowsAcyclicDeps(t *testing.T) {
+	t.Parallel()
+
+	rapid.Check(t, func(t *rapid.T) {
+		g := NewWithT(t)
+
+		n := rapid.IntRange(1, 8).Draw(t, "n")
+		targets := make([]*Target, n)
+		reg := make([]any, n)
+
+		for i := range n {
+			targets[i] = Targ(func() {}).Name(fmt.Sprintf("t%d", i))
+			reg[i] = targets[i]
+		}
+
+		// Each target may depend on any later target, which can never form a cycle.
+		for i := range n {
+			for j := i + 1; j < n; j++ {
+				if rapid.Bool().Draw(t, fmt.Sprintf("edge-%d-%d", i, j)) {
+					targets[i].Deps(targets[j])
+				}
+			}
+		}
+
+		state := NewRegistryState()
+		state.SetRegistry(reg)
+
+		_, _, err := state.resolveRegistry()
+		g.Expect(err).ToNot(HaveOccurred(), "acyclic deps should pass resolution")
+	})
+}
+
+// TestProperty_DetectCycles_ReportsPathWithSources verifies that a dependency
+// cycle fails resolution with the cycle's path and where each target was created.
+func TestProperty_DetectCycles_ReportsPathWithSources(t *testing.T) {
+	t.Parallel()
+
+	rapid.Check(t, func(t *rapid.T) {
+		g := NewWithT(t)
+
+		n := rapid.IntRange(1, 6).Draw(t, "n")
+		parallel := rapid.Bool().Draw(t, "parallel")
+
+		targets := make([]*Target, n)
+		want := make([]CycleStep, 0, n+1)
+
+		for i := range n {
+			targets[i] = Targ(func() {}).Name(fmt.Sprintf("t%d", i))
+			want = append(want, CycleStep{Name: targets[i].GetName(), Source: targets[i].createdAt})
+		}
+
+		want = append(want, want[0])
+
+		// t0 -> t1 -> ... -> t(n-1) -> t0
+		for i := range n {
+			next := targets[(i+1)%n]
+			if parallel {
+				targets[i].Deps(next, DepModeParallel)
+			} else {
+				targets[i].Deps(next)
+			}
+		}
+
+		// Register the cycle inside a group to check group members are walked.
+		state := NewRegistryState()
+		state.SetRegistry([]any{Group("grp", targets[0])})
+
+		_, _, err := state.resolveRegistry()
+		g.Expect(err).To(HaveOccurred(), "a dependency cycle should fail resolution")
+
+		var cycleErr *CycleError
+
+		g.Expect(errors.As(err, &cycleErr)).To(BeTrue(), "error should be *CycleError")
+		g.Expect(cycleErr.Path).To(Equal(want))
+		g.Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("%q depends on itself", "t0")))
+
+		for _, step := range want {
+			g.Expect(step.Source).To(MatchRegexp(`registry_test\.go:\d+$`))
+			g.Expect(err.Error()).To(ContainSubstring(step.Name + " (" + step.Source + ")"))
+		}
+	})
+}
+
 // TestProperty_DuplicateDeregistrationIsIdempotent verifies that calling DeregisterFrom
 // twice with the same package path is idempotent (second call is a no-op).
 func TestProperty_DuplicateDeregistrationIsIdempotent(t *testing.T) {
@@ -185,12 +185,7 @@ func RunWithEnv(env RunEnv, opts RunOptions, targets ...any) error {
 	err := runWithEnvInternal(exec, env, opts, targets...)
 	if err != nil {
 		// Call Exit so test environments can capture the exit code
-		var exitErr ExitError
-		if errors.As(err, &exitErr) {
-			env.Exit(exitErr.Code)
-		} else {
-			env.Exit(1)
-		}
+		env.Exit(ExitStatus(err))
 	}
 
 	return err
@@ -228,8 +223,9 @@ type runExecutor struct {
 	args       []string
 	rest       []string
 	hasDefault bool
-	listFn     listFunc     // injectable for testing, defaults to doList
-	completeFn completeFunc // injectable for testing, defaults to doCompletion
+	timeout    time.Duration // invocation-wide --timeout
+	listFn     listFunc      // injectable for testing, defaults to doList
+	completeFn completeFunc  // injectable for testing, defaults to doCompletion
 }
 
 // detectCompletionShell detects or extracts the shell for completion.
@@ -246,7 +242,12 @@ func (e *runExecutor) detectCompletionShell() string {
 func (e *runExecutor) executeDefault() error {
 	// If parallel mode, run targets concurrently
 	if e.opts.Overrides.Parallel {
-		return e.executeDefaultParallel()
+		plan, ok := dryRunFromContext(e.ctx)
+		if !ok {
+			return e.executeDefaultParallel()
+		}
+
+		plan.planParallel(e.ctx)
 	}
 
 	remaining := e.rest
@@ -266,7 +267,7 @@ func (e *runExecutor) executeDefault() error {
 				e.env.Printf("Error: %v\n", err)
 			}
 
-			return ExitError{Code: 1}
+			return ExitError{Code: ExitStatus(err)}
 		}
 
 		if len(next) == len(remaining) {
@@ -402,7 +403,7 @@ func (e *runExecutor) executeDefaultParallel() error {
 	}
 
 	if firstErr != nil {
-		return ExitError{Code: 1}
+		return ExitError{Code: ExitStatus(firstErr)}
 	}
 
 	return nil
@@ -427,7 +428,7 @@ func (e *runExecutor) executeGlobPattern(name string) error {
 		)
 		if err != nil {
 			e.env.Printf("Error: %v\n", err)
-			return ExitError{Code: 1}
+			return ExitError{Code: ExitStatus(err)}
 		}
 	}
 
@@ -437,7 +438,12 @@ func (e *runExecutor) executeGlobPattern(name string) error {
 // executeMultiRoot executes commands against multiple roots.
 func (e *runExecutor) executeMultiRoot() error {
 	if e.opts.Overrides.Parallel {
-		return e.executeMultiRootParallel()
+		plan, ok := dryRunFromContext(e.ctx)
+		if !ok {
+			return e.executeMultiRootParallel()
+		}
+
+		plan.planParallel(e.ctx)
 	}
 
 	remaining := e.rest
@@ -483,7 +489,7 @@ func (e *runExecutor) executeMultiRoot() error {
 				e.env.Printf("Error: %v\n", err)
 			}
 
-			return ExitError{Code: 1}
+			return ExitError{Code: ExitStatus(err)}
 		}
 
 		remaining = next
@@ -628,7 +634,7 @@ func (e *runExecutor) executeMultiRootParallel() error {
 	}
 
 	if firstErr != nil {
-		return ExitError{Code: 1}
+		return ExitError{Code: ExitStatus(firstErr)}
 	}
 
 	return nil
@@ -656,6 +662,22 @@ func (e *runExecutor) extractOverrides() error {
 	e.opts.Overrides = overrides
 	e.args = remaining
 
+	// One limit for the whole invocation, shared by every parallel group.
+	jobs := overrides.Jobs
+	if jobs == 0 {
+		jobs = e.opts.MaxParallel
+	}
+
+	e.ctx = withJobSlots(e.ctx, jobs)
+
+	if overrides.DryRun {
+		e.ctx = withDryRun(e.ctx, e.timeout)
+	}
+
+	if overrides.Explain {
+		e.ctx = withExplain(e.ctx)
+	}
+
 	return nil
 }
 
@@ -699,6 +721,29 @@ func (e *runExecutor) handleComplete() {
 	}
 }
 
+// handleCacheCommand handles --cache-status, --cache-clean and --cache-info.
+func (e *runExecutor) handleCacheCommand() (bool, error) {
+	var err error
+
+	switch e.rest[0] {
+	case "--cache-status":
+		err = writeCacheStatus(e.env.Stdout(), e.roots, e.opts, e.rest[1:])
+	case "--cache-clean":
+		err = cleanCache(e.ctx, e.env.Stdout(), e.roots, e.opts, e.rest[1:])
+	case "--cache-info":
+		err = writeCacheInfo(e.ctx, e.env.Stdout(), e.roots, e.opts)
+	default:
+		return false, nil
+	}
+
+	if err != nil {
+		e.env.Printf("Error: %v\n", err)
+		return true, ExitError{Code: 1}
+	}
+
+	return true, nil
+}
+
 // handleCompletionFlag handles --completion flag.
 func (e *runExecutor) handleCompletionFlag() (bool, error) {
 	if e.opts.DisableCompletion {
@@ -763,7 +808,7 @@ func (e *runExecutor) handleNoArgs() error {
 				e.env.Printf("Error: %v\n", err)
 			}
 
-			return ExitError{Code: 1}
+			return ExitError{Code: ExitStatus(err)}
 		}
 
 		return nil
@@ -774,7 +819,8 @@ func (e *runExecutor) handleNoArgs() error {
 	return nil
 }
 
-// handleSpecialCommands handles __complete, __list, help, and completion flags.
+// handleSpecialCommands handles __complete, __list, help, --graph, the cache commands,
+// and completion flags.
 func (e *runExecutor) handleSpecialCommands() (bool, error) {
 	if e.rest[0] == "__complete" {
 		e.handleComplete()
@@ -789,6 +835,14 @@ func (e *runExecutor) handleSpecialCommands() (bool, error) {
 		return true, nil
 	}
 
+	if e.rest[0] == "--graph" {
+		return true, e.printGraph(e.rest[1:])
+	}
+
+	if handled, err := e.handleCacheCommand(); handled {
+		return true, err
+	}
+
 	return e.handleCompletionFlag()
 }
 
@@ -815,6 +869,14 @@ func (e *runExecutor) parseTargets(targets []any) error {
 		e.roots = append(e.roots, node)
 	}
 
+	// Check the whole dependency graph before any target starts: a cycle would
+	// otherwise wait forever on its own run.
+	err := checkDepCycles(e.roots)
+	if err != nil {
+		e.env.Printf("Error: %v\n", err)
+		return err
+	}
+
 	return nil
 }
 
@@ -836,6 +898,17 @@ func (e *runExecutor) printCompletion(shell string) error {
 	return nil
 }
 
+// printGraph prints the dependency graph for --graph [target] [--format dot|mermaid|json].
+func (e *runExecutor) printGraph(args []string) error {
+	err := writeGraph(e.env.Stdout(), e.roots, args)
+	if err != nil {
+		e.env.Printf("Error: %v\n", err)
+		return ExitError{Code: 1}
+	}
+
+	return nil
+}
+
 // setupContext creates the execution context with optional signal handling and timeout.
 func (e *runExecutor) setupContext() error {
 	// Use provided context if available, otherwise background
@@ -847,7 +920,27 @@ func (e *runExecutor) setupContext() error {
 
 	// Thread the output writer through context so Print/Printf use it
 	// instead of a global variable (avoids races in parallel tests).
-	e.ctx = WithExecInfo(e.ctx, ExecInfo{Output: e.opts.Stdout})
+	e.ctx = WithExecInfo(e.ctx, ExecInfo{Output: e.opts.Stdout, Input: e.opts.Stdin})
+
+	// Share one execution memo across the invocation so deps run once. It also
+	// serializes the output, which parallel roots then print through.
+	e.ctx, _ = withExecMemo(e.ctx)
+	e.opts.Stdout = outputFromContext(e.ctx)
+
+	backend := e.opts.CacheBackend
+	if backend == nil {
+		var err error
+
+		backend, err = remoteCacheFromEnv(e.env.Getenv)
+		if err != nil {
+			e.env.Printf("Error: %v\n", err)
+			return ExitError{Code: 1}
+		}
+	}
+
+	if backend != nil {
+		e.ctx = withCacheBackend(e.ctx, backend)
+	}
 
 	if e.env.SupportsSignals() {
 		ctx, cancel := signal.NotifyContext(e.ctx, os.Interrupt, syscall.SIGTERM)
@@ -866,6 +959,7 @@ func (e *runExecutor) setupContext() error {
 	}
 
 	e.args = remaining
+	e.timeout = timeout
 
 	if timeout > 0 {
 		ctx, cancel := context.WithTimeout(e.ctx, timeout)
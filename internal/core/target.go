@@ -2,13 +2,13 @@ package core
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"reflect"
 	"runtime"
-	"sort"
+	"slices"
 	"time"
 
 	internalfile "github.com/toejough/targ/internal/file"
@@ -68,7 +68,14 @@ type Target struct {
 	timeout         time.Duration // execution timeout (0 = no timeout)
 	cache           []string      // file patterns for cache invalidation
 	cacheDir        string        // directory to store cache files
+	cacheEnv        []string      // environment variables that invalidate the cache
+	outputs         []string      // file patterns the target produces
+	respectIgnore   bool          // leave out what ignore files ignore from cache and watch patterns
+	locks           []string      // named locks held while the target runs
+	concurrency     int           // max parallel deps running at once (0 = no limit)
 	watch           []string      // file patterns for watch mode
+	watchDeps       bool          // also watch what dependencies cache and watch (FromDeps)
+	watchOpts       internalfile.WatchOptions
 	times           int           // number of times to run (0 = once)
 	whileFn         func() bool   // predicate to check before each run
 	retry           bool          // continue despite failures
@@ -86,6 +93,7 @@ type Target struct {
 	// Source attribution
 	sourcePkg      string // package that registered this target
 	sourceFile     string // file that called Targ() (for string and deps-only targets)
+	createdAt      string // file:line of the Targ() call that created the target
 	nameOverridden bool   // true if Name() was called
 }
 
@@ -123,6 +131,21 @@ func (t *Target) CacheDir(dir string) *Target {
 	return t
 }
 
+// CacheEnv names environment variables that are part of the cache key.
+// Changing any of them (e.g. GOOS, GOARCH) invalidates the cache even when files are unchanged.
+func (t *Target) CacheEnv(names ...string) *Target {
+	t.cacheEnv = names
+	return t
+}
+
+// Concurrency limits how many of this target's parallel dependencies run at once.
+// The limit applies within each parallel dep group, on top of the invocation-wide
+// --jobs limit.
+func (t *Target) Concurrency(n int) *Target {
+	t.concurrency = n
+	return t
+}
+
 // Deps sets dependencies that run before this target.
 // Each dependency runs exactly once even if referenced multiple times.
 // Pass targ.Parallel as the last argument to run dependencies concurrently.
@@ -185,6 +208,16 @@ func (t *Target) GetBackoff() (time.Duration, float64) {
 	return t.backoffInitial, t.backoffMultiply
 }
 
+// GetCacheEnv returns the environment variable names included in the cache key.
+func (t *Target) GetCacheEnv() []string {
+	return t.cacheEnv
+}
+
+// GetConcurrency returns the limit on parallel dependencies running at once (0 = no limit).
+func (t *Target) GetConcurrency() int {
+	return t.concurrency
+}
+
 // GetConfig returns the target's configuration for conflict detection.
 // Returns (watchPatterns, cachePatterns, watchDisabled, cacheDisabled).
 func (t *Target) GetConfig() ([]string, []string, bool, bool) {
@@ -237,6 +270,11 @@ func (t *Target) GetDescription() string {
 	return t.description
 }
 
+// GetLocks returns the names of the locks the target holds while it runs.
+func (t *Target) GetLocks() []string {
+	return t.locks
+}
+
 // GetName returns the configured name, or derives it from the function name.
 func (t *Target) GetName() string {
 	if t.name != "" {
@@ -267,11 +305,21 @@ func (t *Target) GetOnStop() func(ctx context.Context, name string, result Resul
 	return t.onStop
 }
 
+// GetOutputs returns the declared output file patterns.
+func (t *Target) GetOutputs() []string {
+	return t.outputs
+}
+
 // GetRetry returns whether retry is enabled.
 func (t *Target) GetRetry() bool {
 	return t.retry
 }
 
+// GetRespectIgnoreFiles reports whether RespectIgnoreFiles was called.
+func (t *Target) GetRespectIgnoreFiles() bool {
+	return t.respectIgnore
+}
+
 // GetSource returns the package path that registered this target.
 func (t *Target) GetSource() string {
 	return t.sourcePkg
@@ -292,11 +340,24 @@ func (t *Target) GetTimes() int {
 	return t.times
 }
 
+// GetWatchOptions returns the options set with Watch.
+func (t *Target) GetWatchOptions() internalfile.WatchOptions {
+	return t.watchOpts
+}
+
 // IsRenamed returns true if Name() was called to override the default name.
 func (t *Target) IsRenamed() bool {
 	return t.nameOverridden
 }
 
+// Lock names resources the target needs exclusively, e.g. Lock("postgres") for a target
+// that binds its port. Targets sharing a lock never run at the same time, even in a
+// parallel group; a target waiting for one reports "waiting for lock <name>...".
+func (t *Target) Lock(names ...string) *Target {
+	t.locks = append(t.locks, names...)
+	return t
+}
+
 // Name sets the CLI name for this target.
 // By default, the function name is used (converted to kebab-case).
 func (t *Target) Name(s string) *Target {
@@ -324,6 +385,22 @@ func (t *Target) OnStop(
 	return t
 }
 
+// Outputs declares the file patterns this target produces.
+// With Cache(), a cache hit is only honored when every output pattern matches
+// at least one file and the outputs are newer than the cached inputs.
+func (t *Target) Outputs(patterns ...string) *Target {
+	t.outputs = patterns
+	return t
+}
+
+// RespectIgnoreFiles leaves what .gitignore and .targignore files ignore out of the
+// target's Cache() and Watch() patterns. Outputs are matched as declared, since they
+// are often what ignore files ignore.
+func (t *Target) RespectIgnoreFiles() *Target {
+	t.respectIgnore = true
+	return t
+}
+
 // Retry makes the target continue to the next iteration even if execution fails.
 // Without Retry, the target stops on the first error.
 // Use with Times() or While() to retry multiple times.
@@ -334,31 +411,23 @@ func (t *Target) Retry() *Target {
 
 // Run executes the target with the full execution configuration.
 // If Watch() patterns are set, Run() will re-run on file changes until context is cancelled.
+// Within a single invocation each target runs at most once with the same args;
+// later and concurrent callers share the result of the first run.
 func (t *Target) Run(ctx context.Context, args ...any) error {
-	// Run once initially
-	err := t.runOnce(ctx, args)
-	if err != nil {
-		return err
+	memo, ok := execMemoFromContext(ctx)
+	if !ok {
+		ctx, memo = withExecMemo(ctx)
 	}
 
-	// If watch patterns set, watch for changes and re-run
-	if len(t.watch) > 0 {
-		err := internalfile.Watch(
-			ctx,
-			t.watch,
-			internalfile.WatchOptions{},
-			func(_ internalfile.ChangeSet) error {
-				return t.runOnce(ctx, args)
-			},
-			func(p []string) ([]string, error) { return internalfile.Match(p...) },
-			nil,
-		)
-		if err != nil {
-			return fmt.Errorf("watching files: %w", err)
-		}
+	// A cycle would wait forever on its own run, so it fails before anything starts.
+	err := memo.checkCycles(t)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return memo.do(ctx, t, args, func() error {
+		return t.run(ctx, args)
+	})
 }
 
 // SetSourceForTest sets the source package path (for testing only).
@@ -383,8 +452,31 @@ func (t *Target) Times(n int) *Target {
 
 // Watch sets file patterns to watch for changes.
 // When set, Run() will re-run the target when matching files change.
+// Pass a WatchOptions to debounce changes or choose what happens to changes
+// made while the target is running.
 // Pass core.Disabled to allow CLI --watch flag to control watching.
-func (t *Target) Watch(patterns ...string) *Target {
+// Pass FromDeps to also watch the .Cache() and .Watch() patterns of every target this
+// one depends on, directly or not; a change then re-runs only the dependencies it
+// affects, and the targets that depend on them.
+//
+//	targ.Targ(test).Watch("**/*.go", targ.WatchOptions{Debounce: 300 * time.Millisecond})
+func (t *Target) Watch(args ...any) *Target {
+	var patterns []string
+
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			if v == watchDepsSentinel {
+				t.watchDeps = true
+				continue
+			}
+
+			patterns = append(patterns, v)
+		case internalfile.WatchOptions:
+			t.watchOpts = v
+		}
+	}
+
 	if len(patterns) == 1 && patterns[0] == disabledSentinel {
 		t.watchDisabled = true
 		t.watch = nil
@@ -426,47 +518,15 @@ func (t *Target) applyBackoff(
 	return nil
 }
 
-// cacheFilePath returns the path to the cache checksum internalfile.
-func (t *Target) cacheFilePath() string {
-	dir := t.cacheDir
-	if dir == "" {
-		dir = ".targ-cache"
-	}
-
-	// Generate a deterministic filename from the patterns
-	hash := sha256.New()
-
-	// Sort patterns for deterministic hashing
-	patterns := make([]string, len(t.cache))
-	copy(patterns, t.cache)
-	sort.Strings(patterns)
-
-	for _, p := range patterns {
-		hash.Write([]byte(p))
-		hash.Write([]byte{0})
-	}
-
-	filename := hex.EncodeToString(hash.Sum(nil))[:16] + ".sum"
-
-	return dir + "/" + filename
-}
-
-// checkCache checks if cached files have changed.
-// Returns true if files changed (cache miss), false if unchanged (cache hit).
-func (t *Target) checkCache() (bool, error) {
-	cacheFile := t.cacheFilePath()
+// buildCacheKey returns the cache key for running this target with args.
+func (t *Target) buildCacheKey(args []any) cacheKey {
+	cmd, _ := t.fn.(string)
 
-	changed, err := internalfile.Checksum(
-		t.cache,
-		cacheFile,
-		func(p []string) ([]string, error) { return internalfile.Match(p...) },
-		nil,
-	)
-	if err != nil {
-		return false, fmt.Errorf("computing checksum: %w", err)
-	}
+	key := newCacheKey(t.GetName(), cmd, t.cacheEnv, os.Getenv, args...)
+	key.Patterns = t.cache
+	key.RespectIgnoreFiles = t.respectIgnore
 
-	return changed, nil
+	return key
 }
 
 // execute runs the target's function or shell command.
@@ -476,12 +536,14 @@ func (t *Target) execute(ctx context.Context, args []any) error {
 		return nil
 	}
 
-	switch fn := t.fn.(type) {
-	case string:
-		return runShellCommand(ctx, fn)
-	default:
-		return callFunc(ctx, fn, args)
-	}
+	return runExclusive(ctx, t.GetName(), t.locks, func(ctx context.Context) error {
+		switch fn := t.fn.(type) {
+		case string:
+			return runShellCommand(ctx, fn)
+		default:
+			return callFunc(ctx, fn, args)
+		}
+	})
 }
 
 // iterationCount returns the number of iterations to run.
@@ -493,6 +555,68 @@ func (t *Target) iterationCount() int {
 	return 1
 }
 
+// matchOptions returns the options the target's cache and watch patterns are matched with.
+func (t *Target) matchOptions() internalfile.MatchOptions {
+	return internalfile.MatchOptions{RespectIgnoreFiles: t.respectIgnore}
+}
+
+// run executes the target once, then re-runs it on file changes if watch patterns are set.
+// The first run is part of the watch, so with WatchRestart a target that never returns
+// (a dev server) is restarted by the next change.
+func (t *Target) run(ctx context.Context, args []any) error {
+	patterns := t.watch
+	if t.watchDeps {
+		patterns = append(slices.Clone(t.watch), depWatchPatterns(t)...)
+	}
+
+	// Targets run by a watch don't start watches of their own.
+	if len(patterns) == 0 || inWatch(ctx) {
+		return t.runOnce(ctx, args)
+	}
+
+	opts := t.watchOpts
+	opts.RunFirst = true
+	opts.RespectIgnoreFiles = opts.RespectIgnoreFiles || t.respectIgnore
+	first := true
+
+	err := internalfile.WatchContext(
+		ctx,
+		excludeCacheDir(patterns, t.cacheDir),
+		opts,
+		func(runCtx context.Context, changes internalfile.ChangeSet) error {
+			runCtx = withChanges(internalsh.WithGracePeriod(runCtx, opts.Grace()), changes)
+
+			// Each re-run is a new invocation, so dependencies run again; with
+			// FromDeps, only those the changes affect.
+			switch {
+			case first:
+				first = false
+			case t.watchDeps:
+				var (
+					affected bool
+					err      error
+				)
+
+				runCtx, affected, err = withAffectedDeps(runCtx, t, slices.Concat(t.watch, t.cache), changes)
+				if err != nil || !affected {
+					return err
+				}
+			default:
+				runCtx, _ = withExecMemo(runCtx)
+			}
+
+			return t.runOnce(runCtx, args)
+		},
+		internalfile.MatchWithOptions,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("watching files: %w", err)
+	}
+
+	return nil
+}
+
 // runDeps executes dependencies according to the configured mode.
 func (t *Target) runDeps(ctx context.Context) error {
 	for _, group := range t.depGroups {
@@ -500,9 +624,9 @@ func (t *Target) runDeps(ctx context.Context) error {
 
 		switch {
 		case group.mode == DepModeParallel && group.collectAll:
-			err = runGroupParallelAll(ctx, group.targets)
+			err = runGroupParallelAll(ctx, group.targets, t.concurrency)
 		case group.mode == DepModeParallel:
-			err = runGroupParallel(ctx, group.targets)
+			err = runGroupParallel(ctx, group.targets, t.concurrency)
 		default:
 			err = runGroupSerial(ctx, group.targets)
 		}
@@ -534,20 +658,30 @@ func (t *Target) runOnce(ctx context.Context, args []any) error {
 	}
 
 	// Check cache - if hit, skip execution
+	var cached *cacheRun
+
 	if len(t.cache) > 0 {
-		changed, err := t.checkCache()
+		run, hit, err := lookupCache(ctx, t.buildCacheKey(args), t.outputs, t.cacheDir)
 		if err != nil {
 			return fmt.Errorf("cache check failed: %w", err)
 		}
 
-		if !changed {
+		if hit {
 			// Cache hit - skip execution
 			return nil
 		}
+
+		cached = run
 	}
 
 	// Execute the target with repetition handling
-	return t.runWithRepetition(ctx, args)
+	err := t.runWithRepetition(ctx, args)
+	if err != nil || cached == nil {
+		return err
+	}
+
+	// Record the successful run so the next one can hit the cache
+	return cached.commit(ctx)
 }
 
 // runWithRepetition handles Times, While, Retry, and Backoff logic.
@@ -607,29 +741,13 @@ func (t *Target) shouldContinueLoop(ctx context.Context, state *repetitionState)
 // RunContext executes a command with context support, routing output through
 // the parallel printer when running in parallel mode.
 func RunContext(ctx context.Context, name string, args ...string) error {
-	env, pw := parallelShellEnv(ctx)
-
-	err := internalsh.RunContextWithIO(ctx, env, name, args)
-
-	if pw != nil {
-		pw.Flush()
-	}
-
-	return err
+	return Cmd(name, args...).Run(ctx)
 }
 
 // RunContextV executes a command, prints it first, with context support.
 // Routes output through the parallel printer when in parallel mode.
 func RunContextV(ctx context.Context, name string, args ...string) error {
-	env, pw := parallelShellEnv(ctx)
-
-	err := internalsh.RunContextV(ctx, env, name, args)
-
-	if pw != nil {
-		pw.Flush()
-	}
-
-	return err
+	return Cmd(name, args...).Verbose().Run(ctx)
 }
 
 // Targ creates a Target from a function or shell command string.
@@ -646,10 +764,12 @@ func RunContextV(ctx context.Context, name string, args ...string) error {
 //
 //	var all = core.Targ().Name("all").Deps(build, test, lint)
 func Targ(fn ...any) *Target {
+	file, line := targCaller()
+	createdAt := fmt.Sprintf("%s:%d", file, line)
+
 	if len(fn) == 0 {
 		// Deps-only target with no function
-		_, file, _, _ := runtime.Caller(1)
-		return &Target{sourceFile: file}
+		return &Target{sourceFile: file, createdAt: createdAt}
 	}
 
 	if len(fn) > 1 {
@@ -668,9 +788,7 @@ func Targ(fn ...any) *Target {
 			panic("targ.Targ: shell command cannot be empty")
 		}
 
-		_, file, _, _ := runtime.Caller(1)
-
-		return &Target{fn: f, sourceFile: file}
+		return &Target{fn: f, sourceFile: file, createdAt: createdAt}
 	default:
 		fnValue := reflect.ValueOf(f)
 		if fnValue.Kind() != reflect.Func {
@@ -678,7 +796,7 @@ func Targ(fn ...any) *Target {
 		}
 	}
 
-	return &Target{fn: f}
+	return &Target{fn: f, createdAt: createdAt}
 }
 
 // unexported constants.
@@ -686,6 +804,8 @@ const (
 	depModeMixedStr    = "mixed"
 	depModeParallelStr = "parallel"
 	depModeSerialStr   = "serial"
+	targCallerSkip     = 3 // runtime.Callers, targCaller and Targ
+	targWrapper        = "github.com/toejough/targ.Targ"
 )
 
 type depGroup struct {
@@ -779,12 +899,27 @@ func parallelShellEnv(ctx context.Context) (*internalsh.ShellEnv, *PrefixWriter)
 	return env, prefixWriter
 }
 
+// parallelGroupOutput returns the writer a parallel group prints through.
+// A group nested inside another parallel group writes through the parent's
+// printer, so its lines stay atomic and carry the parent's prefix.
+func parallelGroupOutput(ctx context.Context) (io.Writer, func()) {
+	info, ok := GetExecInfo(ctx)
+	if !ok || !info.Parallel || info.Printer == nil {
+		return outputFromContext(ctx), func() {}
+	}
+
+	writer := NewPrefixWriter(FormatPrefix(info.Name, info.MaxNameLen), info.Printer)
+
+	return writer, writer.Flush
+}
+
 //nolint:cyclop,funlen // sequential pipeline with error handling at each step
-func runGroupParallel(ctx context.Context, targets []*Target) error {
+func runGroupParallel(ctx context.Context, targets []*Target, limit int) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	out := outputFromContext(ctx)
+	out, flush := parallelGroupOutput(ctx)
+	defer flush()
 
 	// Compute max target name length for prefix alignment
 	maxNameLen := 0
@@ -807,12 +942,22 @@ func runGroupParallel(ctx context.Context, targets []*Target) error {
 
 	resultCh := make(chan targetResult, len(targets))
 	results := make([]TargetResult, len(targets))
+	groupSlots := newJobSlots(limit)
 
 	for i, dep := range targets {
 		name := dep.GetName()
 		results[i].Name = name
 
 		go func(idx int, d *Target, targetName string) {
+			// Wait for the group's concurrency limit before announcing the start
+			release, err := groupSlots.enter(ctx)
+			if err != nil {
+				resultCh <- targetResult{index: idx, err: err}
+				return
+			}
+
+			defer release()
+
 			tctx := WithExecInfo(ctx, ExecInfo{
 				Parallel:   true,
 				Name:       targetName,
@@ -829,7 +974,7 @@ func runGroupParallel(ctx context.Context, targets []*Target) error {
 			}
 
 			start := time.Now()
-			err := d.Run(tctx)
+			err = d.Run(tctx)
 			duration := time.Since(start)
 
 			resultCh <- targetResult{index: idx, err: err, duration: duration}
@@ -901,8 +1046,9 @@ func runGroupParallel(ctx context.Context, targets []*Target) error {
 }
 
 //nolint:cyclop,funlen // sequential pipeline with error handling at each step
-func runGroupParallelAll(ctx context.Context, targets []*Target) error {
-	out := outputFromContext(ctx)
+func runGroupParallelAll(ctx context.Context, targets []*Target, limit int) error {
+	out, flush := parallelGroupOutput(ctx)
+	defer flush()
 
 	// Compute max target name length for prefix alignment
 	maxNameLen := 0
@@ -925,12 +1071,22 @@ func runGroupParallelAll(ctx context.Context, targets []*Target) error {
 
 	resultCh := make(chan targetResult, len(targets))
 	results := make([]TargetResult, len(targets))
+	groupSlots := newJobSlots(limit)
 
 	for i, dep := range targets {
 		name := dep.GetName()
 		results[i].Name = name
 
 		go func(idx int, d *Target, targetName string) {
+			// Wait for the group's concurrency limit before announcing the start
+			release, err := groupSlots.enter(ctx)
+			if err != nil {
+				resultCh <- targetResult{index: idx, err: err}
+				return
+			}
+
+			defer release()
+
 			tctx := WithExecInfo(ctx, ExecInfo{
 				Parallel:   true,
 				Name:       targetName,
@@ -947,7 +1103,7 @@ func runGroupParallelAll(ctx context.Context, targets []*Target) error {
 			}
 
 			start := time.Now()
-			err := d.Run(tctx)
+			err = d.Run(tctx)
 			duration := time.Since(start)
 
 			resultCh <- targetResult{index: idx, err: err, duration: duration}
@@ -1030,7 +1186,7 @@ func runGroupSerial(ctx context.Context, targets []*Target) error {
 func runShellCommand(ctx context.Context, cmd string) error {
 	env, pw := parallelShellEnv(ctx)
 
-	err := internalsh.RunContextWithIO(ctx, env, "sh", []string{"-c", cmd})
+	err := internalsh.RunContextWithIO(ctx, changesShellEnv(ctx, env), "sh", []string{"-c", cmd})
 
 	if pw != nil {
 		pw.Flush()
@@ -1042,3 +1198,17 @@ func runShellCommand(ctx context.Context, cmd string) error {
 
 	return nil
 }
+
+// targCaller returns the file and line of the Targ call being made, from the
+// caller of the targ package's wrapper if it was called through that.
+func targCaller() (string, int) {
+	pcs := make([]uintptr, targCallerSkip)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(targCallerSkip, pcs)])
+
+	frame, more := frames.Next()
+	if frame.Function == targWrapper && more {
+		frame, _ = frames.Next()
+	}
+
+	return frame.File, frame.Line
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// pollReader reads from a file once poll says it is readable, so a read can be
+// interrupted by closing the write end of its stop pipe.
+type pollReader struct {
+	file     *os.File
+	stop     *os.File
+	stopOnce sync.Once
+}
+
+// Read waits until the file can be read or the reader is stopped, then reads from
+// the file or returns io.EOF.
+func (p *pollReader) Read(buf []byte) (int, error) {
+	fd, err := rawFd(p.file)
+	if err != nil {
+		return 0, err
+	}
+
+	stopFd, err := rawFd(p.stop)
+	if err != nil {
+		return 0, err
+	}
+
+	fds := []unix.PollFd{
+		{Fd: int32(fd), Events: unix.POLLIN},     //nolint:gosec // G115: file descriptors fit in int32
+		{Fd: int32(stopFd), Events: unix.POLLIN}, //nolint:gosec // G115: file descriptors fit in int32
+	}
+
+	for {
+		_, err := unix.Poll(fds, -1)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+
+		if err != nil {
+			return 0, fmt.Errorf("waiting for input: %w", err)
+		}
+
+		if fds[1].Revents != 0 {
+			p.stopOnce.Do(func() { _ = p.stop.Close() })
+			return 0, io.EOF
+		}
+
+		if fds[0].Revents != 0 {
+			return p.file.Read(buf) //nolint:wrapcheck // the reader stands in for the file
+		}
+	}
+}
+
+// cancelableReader returns a reader of f whose reads, unlike f's own, can be
+// interrupted, and a func that interrupts them: pending and later reads then return
+// io.EOF, and nothing more is read from f. f's file status flags are left alone, so
+// other processes sharing it are unaffected.
+func cancelableReader(f *os.File) (io.Reader, func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating pipe: %w", err)
+	}
+
+	var once sync.Once
+
+	return &pollReader{file: f, stop: r}, func() { once.Do(func() { _ = w.Close() }) }, nil
+}
+
+// rawFd returns f's file descriptor without putting f into blocking mode, as Fd does.
+func rawFd(f *os.File) (int, error) {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return 0, fmt.Errorf("getting file descriptor: %w", err)
+	}
+
+	var fd int
+
+	err = conn.Control(func(raw uintptr) { fd = int(raw) }) //nolint:gosec // G115: file descriptors fit in int
+	if err != nil {
+		return 0, fmt.Errorf("getting file descriptor: %w", err)
+	}
+
+	return fd, nil
+}
+
+// setCbreak turns off line buffering and echo on the terminal f, so single keys can
+// be read as they are pressed, and returns a func that restores its settings.
+func setCbreak(f *os.File) (func(), error) {
+	fd := int(f.Fd())
+
+	saved, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, fmt.Errorf("reading terminal settings: %w", err)
+	}
+
+	cbreak := *saved
+	cbreak.Lflag &^= unix.ICANON | unix.ECHO
+	cbreak.Cc[unix.VMIN] = 1
+	cbreak.Cc[unix.VTIME] = 0
+
+	err = unix.IoctlSetTermios(fd, unix.TCSETS, &cbreak)
+	if err != nil {
+		return nil, fmt.Errorf("setting terminal settings: %w", err)
+	}
+
+	return func() { _ = unix.IoctlSetTermios(fd, unix.TCSETS, saved) }, nil
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestCancelableReader_StopInterruptsAPendingRead(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	r, w, err := os.Pipe()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	defer r.Close()
+	defer w.Close()
+
+	reader, stop, err := cancelableReader(r)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	read := make(chan error, 1)
+
+	go func() {
+		_, err := reader.Read(make([]byte, 1))
+		read <- err
+	}()
+
+	g.Consistently(read).WithTimeout(50 * time.Millisecond).ShouldNot(Receive())
+
+	stop()
+	g.Eventually(read).WithTimeout(5 * time.Second).Should(Receive(MatchError(io.EOF)))
+
+	_, err = w.Write([]byte("k"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	buf := make([]byte, 1)
+	_, err = r.Read(buf)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(buf)).To(Equal("k"), "nothing is read from the file once stopped")
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package core
+
+import (
+	"io"
+	"os"
+)
+
+// cancelableReader returns f itself: reads can't be interrupted here, so a pending
+// read lasts until the next key press, whose key is then dropped.
+func cancelableReader(f *os.File) (io.Reader, func(), error) {
+	return f, func() {}, nil
+}
+
+// setCbreak leaves the terminal as it is: keys are read once Enter is pressed.
+func setCbreak(*os.File) (func(), error) {
+	return func() {}, nil
+}
@@ -70,6 +70,10 @@ type RunOptions struct {
 	// Internal: set by the executor to the env's stdout.
 	Stdout io.Writer
 
+	// Stdin is where --watch-status reads its r (re-run) and q (quit) keys.
+	// If nil, os.Stdin is used.
+	Stdin io.Reader
+
 	// BinaryName is the executable name for help/completion output.
 	// Internal: set by the executor from env.BinaryName().
 	BinaryName string
@@ -87,6 +91,16 @@ type RunOptions struct {
 	// Internal: set by runExecutor from env.Getwd. If nil, functions use os.Getwd.
 	Getwd func() (string, error)
 
+	// MaxParallel limits how many targets run at once across the invocation,
+	// including nested parallel dependency groups. The --jobs flag takes precedence.
+	// Zero means no limit.
+	MaxParallel int
+
+	// CacheBackend shares artifact cache entries with other machines (e.g. CI).
+	// If nil, the TARG_REMOTE_CACHE environment variable selects one: an http(s)://
+	// URL for an HTTP GET/PUT server, or a directory path such as an NFS mount.
+	CacheBackend CacheBackend
+
 	// ShellRunner executes shell commands. If nil, uses the default sh -c execution.
 	// For testing, inject a mock to verify command construction without executing.
 	ShellRunner func(ctx context.Context, cmd string) error
@@ -125,5 +139,6 @@ type TargetExecutionLike interface {
 
 // unexported constants.
 const (
-	disabledSentinel = "__targ_disabled__"
+	disabledSentinel  = "__targ_disabled__"
+	watchDepsSentinel = "__targ_watch_deps__"
 )
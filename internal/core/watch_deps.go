@@ -0,0 +1,182 @@
+package core
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	internalfile "github.com/toejough/targ/internal/file"
+)
+
+// affectedTargets returns which of t and the targets it depends on changes affect:
+// those with .Cache() or .Watch() patterns matching a changed file, and every target
+// that depends on one of them. own is t's own patterns.
+func affectedTargets(
+	t *Target,
+	own []string,
+	changes internalfile.ChangeSet,
+) (map[*Target]bool, error) {
+	files := changes.Files()
+	affected := make(map[*Target]bool)
+
+	var visit func(t *Target, patterns []string) (bool, error)
+
+	visit = func(t *Target, patterns []string) (bool, error) {
+		if hit, ok := affected[t]; ok {
+			return hit, nil
+		}
+
+		affected[t] = false // dependency cycles are rejected at registration
+
+		hit, err := matchesAnyFile(files, patterns, t.matchOptions())
+		if err != nil {
+			return false, err
+		}
+
+		for _, group := range t.depGroups {
+			for _, dep := range group.targets {
+				depHit, err := visit(dep, slices.Concat(dep.watch, dep.cache))
+				if err != nil {
+					return false, err
+				}
+
+				hit = hit || depHit
+			}
+		}
+
+		affected[t] = hit
+
+		return hit, nil
+	}
+
+	_, err := visit(t, own)
+
+	return affected, err
+}
+
+// depWatchPatterns returns the .Cache() and .Watch() patterns of every target t
+// depends on, directly or not, for FromDeps and --watch-deps. Exclusions are left
+// out: they only decide which of those targets a change affects.
+func depWatchPatterns(t *Target) []string {
+	var patterns []string
+
+	walkDeps(t, func(dep *Target) {
+		for _, pattern := range slices.Concat(dep.watch, dep.cache) {
+			if strings.HasPrefix(pattern, "!") || slices.Contains(patterns, pattern) {
+				continue
+			}
+
+			patterns = append(patterns, pattern)
+		}
+	})
+
+	return patterns
+}
+
+func matchesAnyFile(files, patterns []string, opts internalfile.MatchOptions) (bool, error) {
+	if len(patterns) == 0 {
+		return false, nil
+	}
+
+	for _, file := range files {
+		ok, err := internalfile.MatchesPath(file, patterns, opts)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+
+	return false, nil
+}
+
+// runWatchingDeps runs node's target for FromDeps or --watch-deps. Its dependencies
+// run inside the watch, so that a change re-runs only those it affects, and, as
+// outside a watch, before the target's cache check. fn is the target's own work,
+// nil for deps-only targets.
+func runWatchingDeps(
+	ctx context.Context,
+	node *commandNode,
+	opts RunOptions,
+	config TargetConfig,
+	fn func(context.Context) error,
+) error {
+	config.DepWatchPatterns = depWatchPatterns(node.Target)
+	own := slices.Concat(
+		resolvePatterns(opts.Overrides.Watch, config.WatchPatterns),
+		resolvePatterns(opts.Overrides.Cache, config.CachePatterns),
+	)
+
+	config.beforeRun = func(ctx context.Context) (context.Context, bool, error) {
+		ctx, affected, err := withAffectedDeps(ctx, node.Target, own, Changes(ctx))
+		if err != nil || !affected {
+			return ctx, false, err
+		}
+
+		err = node.Target.runDeps(ctx)
+
+		return ctx, err == nil, err
+	}
+
+	return ExecuteWithOverrides(ctx, opts.Overrides, config, func(ctx context.Context) error {
+		if fn == nil {
+			return nil
+		}
+
+		return fn(ctx)
+	})
+}
+
+// walkDeps calls fn once for each target t depends on, directly or not.
+func walkDeps(t *Target, fn func(dep *Target)) {
+	seen := make(map[*Target]bool)
+
+	var walk func(t *Target)
+
+	walk = func(t *Target) {
+		for _, group := range t.depGroups {
+			for _, dep := range group.targets {
+				if !seen[dep] {
+					seen[dep] = true
+					fn(dep)
+					walk(dep)
+				}
+			}
+		}
+	}
+
+	walk(t)
+}
+
+// watchesDeps reports whether node's target watches its dependencies' patterns.
+func watchesDeps(node *commandNode, overrides RuntimeOverrides) bool {
+	return node.Target != nil && (node.Target.watchDeps || overrides.WatchDeps)
+}
+
+// withAffectedDeps returns ctx with a fresh execution memo in which the targets t
+// depends on that changes don't affect count as already run, and reports whether
+// t is affected at all. Without changes (a first run or a manual re-run),
+// everything runs. own is t's own patterns.
+func withAffectedDeps(
+	ctx context.Context,
+	t *Target,
+	own []string,
+	changes internalfile.ChangeSet,
+) (context.Context, bool, error) {
+	ctx, memo := withExecMemo(ctx)
+
+	if len(changes.Files()) == 0 {
+		return ctx, true, nil
+	}
+
+	affected, err := affectedTargets(t, own, changes)
+	if err != nil {
+		return ctx, false, err
+	}
+
+	walkDeps(t, func(dep *Target) {
+		if !affected[dep] {
+			memo.skip(dep)
+		}
+	})
+
+	return ctx, affected[t], nil
+}
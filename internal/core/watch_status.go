@@ -0,0 +1,193 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	internalfile "github.com/toejough/targ/internal/file"
+)
+
+// unexported constants.
+const (
+	clearScreen   = "\033[H\033[2J"
+	dividerWidth  = 60
+	watchKeysHint = "watching for changes (r: re-run, q: quit)"
+)
+
+// watchStatus is the display for --watch-status. It clears the screen before each
+// run (or prints a divider when output isn't a terminal), says what triggered the
+// run, and sums it up with its Result and duration. It reads r (re-run) and
+// q (quit) from the keyboard.
+type watchStatus struct {
+	out      io.Writer
+	terminal bool
+	runs     int
+	rerun    chan struct{}
+	quit     chan struct{}
+	done     chan struct{} // closed when the watch ends, after which keys are ignored
+}
+
+// begin starts the display for the next run, triggered by changes.
+func (s *watchStatus) begin(changes internalfile.ChangeSet) {
+	s.runs++
+
+	if s.terminal {
+		_, _ = io.WriteString(s.out, clearScreen)
+	} else if s.runs > 1 {
+		_, _ = fmt.Fprintln(s.out, strings.Repeat("─", dividerWidth))
+	}
+
+	header := fmt.Sprintf("run #%d", s.runs)
+
+	switch files := changes.Files(); {
+	case len(files) > 1:
+		header += fmt.Sprintf(" triggered by %s (+%d more)", displayPath(files[0]), len(files)-1)
+	case len(files) == 1:
+		header += " triggered by " + displayPath(files[0])
+	case s.runs > 1:
+		header += " (re-run)"
+	}
+
+	_, _ = fmt.Fprintln(s.out, header)
+}
+
+// end sums up a run that returned err after d. cancelled reports whether the
+// watch cancelled the run.
+func (s *watchStatus) end(err error, d time.Duration, cancelled bool) {
+	summary := fmt.Sprintf("%s in %s", ClassifyResult(err, !cancelled), d.Round(time.Millisecond))
+	if err != nil && !cancelled {
+		summary += ": " + firstLine(err.Error())
+	}
+
+	_, _ = fmt.Fprintln(s.out, summary)
+	_, _ = fmt.Fprintln(s.out, watchKeysHint)
+}
+
+// readKeys handles keys from in until q is pressed, in is exhausted or the watch ends.
+func (s *watchStatus) readKeys(in io.Reader) {
+	buf := make([]byte, 1)
+
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			return
+		}
+
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		if n == 0 {
+			continue
+		}
+
+		switch buf[0] {
+		case 'r':
+			select {
+			case s.rerun <- struct{}{}:
+			default: // a re-run is already pending
+			}
+		case 'q':
+			close(s.quit)
+			return
+		}
+	}
+}
+
+// wrap returns run with the status display around it. A failed run is reported
+// rather than returned, so watching continues.
+func (s *watchStatus) wrap(
+	run func(context.Context, internalfile.ChangeSet) error,
+) func(context.Context, internalfile.ChangeSet) error {
+	return func(ctx context.Context, changes internalfile.ChangeSet) error {
+		s.begin(changes)
+
+		start := time.Now()
+		err := run(ctx, changes)
+		cancelled := ctx.Err() != nil
+
+		s.end(err, time.Since(start), cancelled)
+
+		if cancelled {
+			return err
+		}
+
+		return nil
+	}
+}
+
+// displayPath returns path relative to the working directory when it is inside it.
+func displayPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil || !filepath.IsAbs(path) {
+		return path
+	}
+
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+
+	return rel
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// newWatchStatus returns the status display for a watch run from ctx, and a func that
+// ends it. Keys are read until q is pressed or the display ends, from the context's
+// input, unless it is a file that isn't a terminal: then it is left to the commands run.
+// On a terminal, keys take effect without Enter where the platform allows it, until the
+// display ends and the terminal's settings are restored.
+func newWatchStatus(ctx context.Context) (*watchStatus, func()) {
+	out := outputFromContext(ctx)
+	outFile, ok := outputFile(out)
+
+	status := &watchStatus{
+		out:      out,
+		terminal: ok && isTerminal(outFile),
+		rerun:    make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	in := inputFromContext(ctx)
+	restore := func() {}
+	stop := func() {}
+
+	if inFile, ok := in.(*os.File); ok {
+		if !isTerminal(inFile) {
+			return status, func() { close(status.done) }
+		}
+
+		reader, stopReading, err := cancelableReader(inFile)
+		if err != nil {
+			return status, func() { close(status.done) }
+		}
+
+		in, stop = reader, stopReading
+
+		r, err := setCbreak(inFile)
+		if err == nil {
+			restore = r
+		}
+	}
+
+	go status.readKeys(in)
+
+	return status, func() {
+		close(status.done)
+		stop()
+		restore()
+	}
+}
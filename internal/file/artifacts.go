@@ -0,0 +1,899 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ArtifactEntry records the output files produced by one cached run, and the output
+// directories they were saved from, which hold nothing else once they are restored.
+type ArtifactEntry struct {
+	Files []ArtifactFile `json:"files"`
+	Dirs  []string       `json:"dirs,omitempty"`
+}
+
+// objects returns the files of e that have a stored object, leaving out symlinks.
+func (e ArtifactEntry) objects() []ArtifactFile {
+	files := make([]ArtifactFile, 0, len(e.Files))
+
+	for _, f := range e.Files {
+		if !f.isLink() {
+			files = append(files, f)
+		}
+	}
+
+	return files
+}
+
+// removeExtras removes whatever is in e's directories that isn't one of its files or
+// a directory holding them. What has the wrong type, such as a file where a directory
+// should be or a symlink in place of a file, is removed too, and symlinks are never
+// followed.
+func (e ArtifactEntry) removeExtras() error {
+	files := make(map[string]ArtifactFile, len(e.Files))
+	dirs := make(map[string]bool, len(e.Dirs))
+
+	for _, dir := range e.Dirs {
+		dirs[dir] = true
+	}
+
+	for _, f := range e.Files {
+		files[f.Path] = f
+
+		for dir := filepath.Dir(f.Path); !dirs[dir]; dir = filepath.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+
+	for _, dir := range e.Dirs {
+		err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) && p == dir {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+
+			f, isFile := files[p]
+			if (d.IsDir() && dirs[p]) || (isFile && f.isLink() == (d.Type()&fs.ModeSymlink != 0)) {
+				return nil
+			}
+
+			err = Remove(p)
+			if err != nil {
+				return err
+			}
+
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("removing extra outputs in %s: %w", dir, err)
+		}
+	}
+
+	return nil
+}
+
+// validate returns an error if a path in e isn't one of outputs (see restorable), or
+// lies under one of e's symlinks, which restoring would write through.
+func (e ArtifactEntry) validate(outputs []string) error {
+	links := make(map[string]bool)
+
+	for _, f := range e.Files {
+		if f.isLink() {
+			links[f.Path] = true
+		}
+	}
+
+	paths := make([]string, 0, len(e.Files)+len(e.Dirs))
+	for _, f := range e.Files {
+		paths = append(paths, f.Path)
+	}
+
+	for _, path := range slices.Concat(paths, e.Dirs) {
+		if !restorable(path, outputs) {
+			return fmt.Errorf("%s: %w", path, errNotAnOutput)
+		}
+
+		for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+			if links[dir] {
+				return fmt.Errorf("%s: %w %s", path, errUnderSymlink, dir)
+			}
+
+			if filepath.Dir(dir) == dir {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// ArtifactFile is one stored output file, or a symlink, which is stored as its Link
+// target rather than as content.
+type ArtifactFile struct {
+	Path string      `json:"path"`
+	Hash string      `json:"hash,omitempty"`
+	Link string      `json:"link,omitempty"`
+	Mode fs.FileMode `json:"mode"`
+	Size int64       `json:"size"`
+}
+
+// isLink reports whether f is a symlink, which has no stored object.
+func (f ArtifactFile) isLink() bool {
+	return f.Link != ""
+}
+
+// ArtifactStore is a content-addressed cache of output files.
+// File contents live under objects/, named by their SHA-256. Each entry under
+// entries/ maps an input digest to the files a successful run produced. An entry's
+// modification time records its last use, so eviction drops the least recently
+// used entries first.
+//
+// When Remote is set, local misses fall back to it and saved entries are uploaded
+// to it. The remote is best-effort: when it fails, reads are misses and uploads are
+// skipped, and the failure is passed to Warn. Objects are verified against their hash
+// whenever they are copied, so a corrupted object is never restored, and only paths
+// matching the declared outputs are restored, so an entry can't write anywhere else.
+type ArtifactStore struct {
+	Dir      string
+	MaxBytes int64 // total object size to keep locally; 0 means unbounded
+	Remote   CacheBackend
+	Warn     func(err error) // reports remote failures; nil ignores them
+}
+
+// Evict removes least recently used entries until the stored objects fit in MaxBytes,
+// then deletes objects no remaining entry references.
+func (s *ArtifactStore) Evict() error {
+	if s.MaxBytes <= 0 {
+		return nil
+	}
+
+	entries, err := s.listEntries()
+	if err != nil {
+		return err
+	}
+
+	refs := make(map[string]int)
+	sizes := make(map[string]int64)
+
+	var total int64
+
+	for _, e := range entries {
+		for _, f := range e.entry.objects() {
+			if refs[f.Hash] == 0 {
+				sizes[f.Hash] = f.Size
+				total += f.Size
+			}
+
+			refs[f.Hash]++
+		}
+	}
+
+	// Oldest first; the newest entry is always kept.
+	for i := 0; total > s.MaxBytes && i < len(entries)-1; i++ {
+		err := os.Remove(entries[i].path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("evicting cache entry: %w", err)
+		}
+
+		for _, f := range entries[i].entry.objects() {
+			refs[f.Hash]--
+			if refs[f.Hash] > 0 {
+				continue
+			}
+
+			err := os.Remove(s.objectPath(f.Hash))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("evicting cache object: %w", err)
+			}
+
+			total -= sizes[f.Hash]
+		}
+	}
+
+	return nil
+}
+
+// Has reports whether an entry for digest is stored locally or on the remote.
+// It doesn't check the entry's objects, so a later Restore can still miss.
+func (s *ArtifactStore) Has(ctx context.Context, digest string) (bool, error) {
+	_, err := os.Stat(s.entryPath(digest))
+	if err == nil {
+		return true, nil
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("checking cache entry: %w", err)
+	}
+
+	if s.Remote == nil {
+		return false, nil
+	}
+
+	body, err := s.remoteGet(ctx, entryKey(digest))
+	if isUnusable(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	_ = body.Close()
+
+	return true, nil
+}
+
+// Remove deletes the local entry for digest, along with the objects no other entry
+// references. It reports whether there was an entry to remove.
+func (s *ArtifactStore) Remove(digest string) (bool, error) {
+	entries, err := s.listEntries()
+	if err != nil {
+		return false, err
+	}
+
+	path := s.entryPath(digest)
+	refs := make(map[string]int)
+
+	var removed *ArtifactEntry
+
+	for i, e := range entries {
+		if e.path == path {
+			removed = &entries[i].entry
+			continue
+		}
+
+		for _, f := range e.entry.objects() {
+			refs[f.Hash]++
+		}
+	}
+
+	if removed == nil {
+		return false, nil
+	}
+
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("removing cache entry: %w", err)
+	}
+
+	for _, f := range removed.objects() {
+		if refs[f.Hash] > 0 {
+			continue
+		}
+
+		err := os.Remove(s.objectPath(f.Hash))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return false, fmt.Errorf("removing cache object: %w", err)
+		}
+	}
+
+	return true, nil
+}
+
+// Restore writes the files stored for digest back to their original paths, and
+// removes whatever else is in the output directories they were saved from, so those
+// hold what the run left in them. Files whose current content already matches, and
+// symlinks that already point where they should, are left untouched.
+// Returns false if neither the local store nor the remote has a valid entry for digest.
+// An entry with a path that doesn't match the output patterns, that climbs out of
+// a directory with .., or that lies under one of its symlinks is invalid, and nothing
+// is restored from it.
+func (s *ArtifactStore) Restore(ctx context.Context, digest string, outputs []string) (bool, error) {
+	entry, err := s.loadEntry(ctx, digest)
+	if isUnusable(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	err = entry.validate(outputs)
+	if err != nil {
+		s.warn(fmt.Errorf("%w: entry %s: %w", ErrCacheCorrupt, digest, err))
+		return false, nil
+	}
+
+	err = entry.removeExtras()
+	if err != nil {
+		return false, err
+	}
+
+	for _, f := range entry.Files {
+		if f.isLink() {
+			err := restoreLink(f)
+			if err != nil {
+				return false, fmt.Errorf("restoring %s: %w", f.Path, err)
+			}
+
+			continue
+		}
+
+		if isRegularFile(f.Path) {
+			current, _, err := hashFile(f.Path)
+			if err == nil && current == f.Hash {
+				continue
+			}
+		}
+
+		err = s.restoreFile(ctx, f)
+		if isUnusable(err) {
+			return false, nil
+		}
+
+		if err != nil {
+			return false, fmt.Errorf("restoring %s: %w", f.Path, err)
+		}
+	}
+
+	// Mark the entry as recently used for LRU eviction.
+	now := time.Now()
+
+	err = os.Chtimes(s.entryPath(digest), now, now)
+	if err != nil {
+		return false, fmt.Errorf("touching cache entry: %w", err)
+	}
+
+	return true, nil
+}
+
+// Save stores the given output files under digest, uploads them to the remote if
+// one is set, and evicts old local entries if needed. Directories are stored recursively,
+// and symlinks as links.
+// A failed upload is passed to Warn rather than failing the save.
+func (s *ArtifactStore) Save(ctx context.Context, digest string, paths []string) error {
+	files, dirs, err := expandDirs(paths)
+	if err != nil {
+		return err
+	}
+
+	entry := ArtifactEntry{Files: make([]ArtifactFile, 0, len(files)), Dirs: dirs}
+
+	for _, path := range files {
+		file, err := s.storeObject(path)
+		if err != nil {
+			return fmt.Errorf("caching %s: %w", path, err)
+		}
+
+		entry.Files = append(entry.Files, file)
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding cache entry: %w", err)
+	}
+
+	err = WriteFileAtomic(s.entryPath(digest), data, cacheFileMode)
+	if err != nil {
+		return fmt.Errorf("writing cache entry: %w", err)
+	}
+
+	if s.Remote != nil {
+		err = s.upload(ctx, digest, entry, data)
+		if err != nil {
+			s.warn(fmt.Errorf("uploading entry %s: %w", digest, err))
+		}
+	}
+
+	return s.Evict()
+}
+
+// Usage returns how many entries the local store holds and the total size of their objects.
+func (s *ArtifactStore) Usage() (int, int64, error) {
+	entries, err := s.listEntries()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	seen := make(map[string]bool)
+
+	var total int64
+
+	for _, e := range entries {
+		for _, f := range e.entry.objects() {
+			if !seen[f.Hash] {
+				seen[f.Hash] = true
+				total += f.Size
+			}
+		}
+	}
+
+	return len(entries), total, nil
+}
+
+func (s *ArtifactStore) entryPath(digest string) string {
+	return filepath.Join(s.Dir, "entries", digest+".json")
+}
+
+// fetchObject makes sure the object for hash is in the local store, downloading it if needed.
+func (s *ArtifactStore) fetchObject(ctx context.Context, hash string) error {
+	_, err := os.Stat(s.objectPath(hash))
+	if err == nil {
+		return nil
+	}
+
+	if s.Remote == nil {
+		return fmt.Errorf("%w: object %s", ErrCacheMiss, hash)
+	}
+
+	body, err := s.remoteGet(ctx, objectKey(hash))
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = body.Close() }()
+
+	err = copyVerified(body, s.objectPath(hash), cacheFileMode, hash)
+	if err != nil && !isUnusable(err) && ctx.Err() == nil {
+		s.warn(fmt.Errorf("downloading object %s: %w", hash, err))
+		return fmt.Errorf("%w: object %s", ErrCacheMiss, hash)
+	}
+
+	return err
+}
+
+// listEntries returns all entries, least recently used first.
+func (s *ArtifactStore) listEntries() ([]storedEntry, error) {
+	dir := filepath.Join(s.Dir, "entries")
+
+	dirEntries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("listing cache entries: %w", err)
+	}
+
+	entries := make([]storedEntry, 0, len(dirEntries))
+
+	for _, d := range dirEntries {
+		if d.IsDir() || filepath.Ext(d.Name()) != ".json" {
+			continue
+		}
+
+		path := filepath.Join(dir, d.Name())
+
+		info, err := d.Info()
+		if err != nil {
+			continue // removed concurrently
+		}
+
+		entry, err := readArtifactEntry(path)
+		if err != nil {
+			continue // removed concurrently or corrupt; eviction skips it
+		}
+
+		entries = append(entries, storedEntry{path: path, used: info.ModTime(), entry: entry})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].used.Before(entries[j].used) })
+
+	return entries, nil
+}
+
+// loadEntry reads the entry for digest, downloading it into the local store if needed.
+func (s *ArtifactStore) loadEntry(ctx context.Context, digest string) (ArtifactEntry, error) {
+	path := s.entryPath(digest)
+
+	entry, err := readArtifactEntry(path)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return entry, err
+	}
+
+	if s.Remote == nil {
+		return ArtifactEntry{}, fmt.Errorf("%w: entry %s", ErrCacheMiss, digest)
+	}
+
+	body, err := s.remoteGet(ctx, entryKey(digest))
+	if err != nil {
+		return ArtifactEntry{}, err
+	}
+
+	defer func() { _ = body.Close() }()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ArtifactEntry{}, fmt.Errorf("reading remote cache entry: %w", err)
+		}
+
+		s.warn(fmt.Errorf("downloading entry %s: %w", digest, err))
+
+		return ArtifactEntry{}, fmt.Errorf("%w: entry %s", ErrCacheMiss, digest)
+	}
+
+	entry, err = decodeArtifactEntry(data)
+	if err != nil {
+		return ArtifactEntry{}, err
+	}
+
+	err = WriteFileAtomic(path, data, cacheFileMode)
+	if err != nil {
+		return ArtifactEntry{}, fmt.Errorf("writing cache entry: %w", err)
+	}
+
+	return entry, nil
+}
+
+func (s *ArtifactStore) objectPath(hash string) string {
+	return filepath.Join(s.Dir, "objects", hash)
+}
+
+func (s *ArtifactStore) putFile(ctx context.Context, key, path string) error {
+	//nolint:gosec // G304: Reading from the configured cache directory.
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = file.Close() }()
+
+	return s.Remote.Put(ctx, key, file)
+}
+
+// remoteGet gets key from the remote. Failures other than a miss are passed to
+// Warn and returned as a miss, so an unreachable remote never fails a run; only
+// cancellation is returned as is.
+func (s *ArtifactStore) remoteGet(ctx context.Context, key string) (io.ReadCloser, error) {
+	body, err := s.Remote.Get(ctx, key)
+	if err == nil || isUnusable(err) || ctx.Err() != nil {
+		return body, err
+	}
+
+	s.warn(fmt.Errorf("reading %s: %w", key, err))
+
+	return nil, fmt.Errorf("%w: %s", ErrCacheMiss, key)
+}
+
+// restoreFile copies a stored object back to its output path, verifying its hash.
+// A corrupted local object is removed so the next save or fetch replaces it.
+func (s *ArtifactStore) restoreFile(ctx context.Context, f ArtifactFile) error {
+	err := s.fetchObject(ctx, f.Hash)
+	if err != nil {
+		return err
+	}
+
+	//nolint:gosec // G304: Reading from the configured cache directory.
+	in, err := os.Open(s.objectPath(f.Hash))
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = in.Close() }()
+
+	err = copyVerified(in, f.Path, f.Mode, f.Hash)
+	if errors.Is(err, ErrCacheCorrupt) {
+		_ = os.Remove(s.objectPath(f.Hash))
+	}
+
+	return err
+}
+
+// storeObject copies path into the object store, keyed by its content hash.
+// An existing object is rewritten, so a corrupted local copy is repaired on the next save.
+// A symlink is recorded as where it points, with nothing stored.
+func (s *ArtifactStore) storeObject(path string) (ArtifactFile, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return ArtifactFile{}, err
+	}
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		link, err := os.Readlink(path)
+		if err != nil {
+			return ArtifactFile{}, err
+		}
+
+		return ArtifactFile{Path: path, Link: link, Mode: info.Mode().Perm()}, nil
+	}
+
+	hash, size, err := hashFile(path)
+	if err != nil {
+		return ArtifactFile{}, err
+	}
+
+	//nolint:gosec // G304: Caching user-declared outputs is the function's purpose.
+	in, err := os.Open(path)
+	if err != nil {
+		return ArtifactFile{}, err
+	}
+
+	defer func() { _ = in.Close() }()
+
+	err = copyVerified(in, s.objectPath(hash), cacheFileMode, hash)
+	if err != nil {
+		return ArtifactFile{}, err
+	}
+
+	return ArtifactFile{Path: path, Hash: hash, Mode: info.Mode().Perm(), Size: size}, nil
+}
+
+// upload pushes an entry's objects, then the entry itself, to the remote.
+// Objects are verified before upload so a corrupted local copy is never shared.
+func (s *ArtifactStore) upload(ctx context.Context, digest string, entry ArtifactEntry, data []byte) error {
+	uploaded := make(map[string]bool)
+
+	for _, f := range entry.objects() {
+		if uploaded[f.Hash] {
+			continue
+		}
+
+		hash, _, err := hashFile(s.objectPath(f.Hash))
+		if err != nil {
+			return fmt.Errorf("uploading %s: %w", f.Path, err)
+		}
+
+		if hash != f.Hash {
+			return fmt.Errorf("uploading %s: %w", f.Path, ErrCacheCorrupt)
+		}
+
+		err = s.putFile(ctx, objectKey(f.Hash), s.objectPath(f.Hash))
+		if err != nil {
+			return err
+		}
+
+		uploaded[f.Hash] = true
+	}
+
+	return s.Remote.Put(ctx, entryKey(digest), bytes.NewReader(data))
+}
+
+// warn passes err to s.Warn, if set.
+func (s *ArtifactStore) warn(err error) {
+	if s.Warn != nil {
+		s.Warn(err)
+	}
+}
+
+// FormatSize renders n bytes for display, e.g. "512 B" or "1.5 MB". Units are powers of 1024.
+func FormatSize(n int64) string {
+	const unit = 1024
+
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	value := float64(n)
+	suffixes := "KMGT"
+
+	i := -1
+	for value >= unit && i < len(suffixes)-1 {
+		value /= unit
+		i++
+	}
+
+	return fmt.Sprintf("%.1f %cB", value, suffixes[i])
+}
+
+// unexported constants.
+const (
+	cacheDirMode  = 0o755
+	cacheFileMode = 0o644
+)
+
+// unexported variables.
+var (
+	errNotAnOutput  = errors.New("not a declared output")
+	errUnderSymlink = errors.New("under the symlink")
+)
+
+type storedEntry struct {
+	path  string
+	used  time.Time
+	entry ArtifactEntry
+}
+
+// copyVerified writes src to dest atomically, failing with ErrCacheCorrupt (and
+// leaving dest untouched) if the content's SHA-256 is not wantHash.
+func copyVerified(src io.Reader, dest string, mode fs.FileMode, wantHash string) error {
+	return writeAtomic(dest, mode, func(w io.Writer) error {
+		hasher := sha256.New()
+
+		_, err := io.Copy(io.MultiWriter(w, hasher), src)
+		if err != nil {
+			return err
+		}
+
+		if hex.EncodeToString(hasher.Sum(nil)) != wantHash {
+			return fmt.Errorf("%w: %s", ErrCacheCorrupt, dest)
+		}
+
+		return nil
+	})
+}
+
+func decodeArtifactEntry(data []byte) (ArtifactEntry, error) {
+	var entry ArtifactEntry
+
+	err := json.Unmarshal(data, &entry)
+	if err != nil {
+		return ArtifactEntry{}, fmt.Errorf("%w: decoding cache entry: %w", ErrCacheCorrupt, err)
+	}
+
+	return entry, nil
+}
+
+func entryKey(digest string) string {
+	return "entries/" + digest + ".json"
+}
+
+// expandDirs replaces directories in paths with the regular files and symlinks beneath
+// them, which it returns along with the directories. Symlinks aren't followed.
+func expandDirs(paths []string) ([]string, []string, error) {
+	var files, dirs []string
+
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case p == path && d.IsDir():
+				dirs = append(dirs, p)
+			case d.Type().IsRegular() || d.Type()&fs.ModeSymlink != 0:
+				files = append(files, p)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("listing outputs in %s: %w", path, err)
+		}
+	}
+
+	return files, dirs, nil
+}
+
+func hashFile(path string) (string, int64, error) {
+	//nolint:gosec // G304: Hashing user-declared outputs is the function's purpose.
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+
+	defer func() { _ = file.Close() }()
+
+	hasher := sha256.New()
+
+	size, err := io.Copy(hasher, file)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), size, nil
+}
+
+// isRegularFile reports whether path is a regular file, not a symlink to one.
+func isRegularFile(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// isUnusable reports whether err means cached data is absent or invalid,
+// which callers treat as a miss rather than a failure.
+func isUnusable(err error) bool {
+	return errors.Is(err, ErrCacheMiss) || errors.Is(err, ErrCacheCorrupt)
+}
+
+func objectKey(hash string) string {
+	return "objects/" + hash
+}
+
+func readArtifactEntry(path string) (ArtifactEntry, error) {
+	//nolint:gosec // G304: Reading entries from the configured cache directory.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ArtifactEntry{}, err
+	}
+
+	return decodeArtifactEntry(data)
+}
+
+// restorable reports whether path, read from a cache entry, may be restored for a
+// target with the given output patterns: it mustn't contain .., and it must match one
+// of the patterns or lie under a directory that does. A path matching no pattern,
+// including an absolute path when the patterns are relative, could be anywhere.
+func restorable(path string, outputs []string) bool {
+	if path == "" || slices.Contains(strings.Split(filepath.ToSlash(path), "/"), "..") {
+		return false
+	}
+
+	for cur := path; ; cur = filepath.Dir(cur) {
+		ok, err := MatchesPath(cur, outputs, MatchOptions{})
+		if err == nil && ok {
+			return true
+		}
+
+		if parent := filepath.Dir(cur); parent == cur || parent == "." {
+			return false
+		}
+	}
+}
+
+// restoreLink makes f.Path a symlink to f.Link, unless it already is one.
+// The link is created beside f.Path and renamed into place.
+func restoreLink(f ArtifactFile) error {
+	current, err := os.Readlink(f.Path)
+	if err == nil && current == f.Link {
+		return nil
+	}
+
+	dir := filepath.Dir(f.Path)
+
+	err = os.MkdirAll(dir, cacheDirMode)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(dir, fmt.Sprintf(".tmp-%s-%d", filepath.Base(f.Path), time.Now().UnixNano()))
+
+	err = os.Symlink(f.Link, tmp)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp, f.Path)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+// writeAtomic writes dest via a temporary file in the same directory and renames it into place.
+func writeAtomic(dest string, mode fs.FileMode, write func(io.Writer) error) error {
+	dir := filepath.Dir(dest)
+
+	err := os.MkdirAll(dir, cacheDirMode)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-"+filepath.Base(dest)+"-*")
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	err = write(tmp)
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmp.Name(), mode)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dest)
+}
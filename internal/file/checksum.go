@@ -8,8 +8,16 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+)
+
+// Exported constants.
+const (
+	// StatCacheSuffix is appended to a Checksum dest to name its stat cache.
+	StatCacheSuffix = ".stat"
 )
 
 // Exported variables.
@@ -23,11 +31,13 @@ type FileOps struct {
 	MkdirAll  func(string, fs.FileMode) error
 	OpenFile  func(string) (io.ReadCloser, error)
 	ReadFile  func(string) ([]byte, error)
+	Stat      func(string) (fs.FileInfo, error)
 	WriteFile func(string, []byte, fs.FileMode) error
 }
 
 // Checksum reports whether the content hash of inputs differs from the stored hash at dest.
-// When the hash changes, the new hash is written to dest.
+// When the hash changes, the new hash is written to dest. File hashes are remembered in
+// a stat cache next to dest (dest + StatCacheSuffix), so unchanged files aren't re-read.
 // If ops is nil, DefaultFileOps() is used.
 func Checksum(
 	inputs []string,
@@ -47,17 +57,19 @@ func Checksum(
 		ops = DefaultFileOps()
 	}
 
-	matches, err := matchFn(inputs)
+	stats := LoadStatCache(dest + StatCacheSuffix)
+
+	nextHash, err := Digest(inputs, "", matchFn, ops, &HashOptions{StatCache: stats})
 	if err != nil {
 		return false, err
 	}
 
-	nextHash, err := computeChecksum(matches, ops)
+	err = stats.Save()
 	if err != nil {
 		return false, err
 	}
 
-	prevHash, err := readChecksum(dest, ops)
+	prevHash, err := ReadChecksum(dest, ops)
 	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return false, err
 	}
@@ -66,7 +78,7 @@ func Checksum(
 		return false, nil
 	}
 
-	err = writeChecksum(dest, nextHash, ops)
+	err = WriteChecksum(dest, nextHash, ops)
 	if err != nil {
 		return false, err
 	}
@@ -74,6 +86,29 @@ func Checksum(
 	return true, nil
 }
 
+// CombineHashes returns the digest of files (path to content hash, as from HashFiles),
+// with key mixed in as Digest does. Paths are part of the digest, so renaming, adding
+// or removing a file changes it too.
+func CombineHashes(key string, files map[string]string) string {
+	hasher := sha256.New()
+
+	// An empty key leaves the hash identical to a plain content checksum.
+	if key != "" {
+		_, _ = io.WriteString(hasher, key)
+		_, _ = io.WriteString(hasher, "\x00")
+	}
+
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		// hash.Hash.Write never returns an error per Go documentation
+		_, _ = io.WriteString(hasher, path)
+		_, _ = io.WriteString(hasher, "\x00")
+		_, _ = io.WriteString(hasher, files[path])
+		_, _ = io.WriteString(hasher, "\x00")
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
 // DefaultFileOps returns the standard OS implementations.
 func DefaultFileOps() *FileOps {
 	return &FileOps{
@@ -81,39 +116,72 @@ func DefaultFileOps() *FileOps {
 		//nolint:gosec // G304: Opening user-specified files is the function's purpose.
 		OpenFile:  func(name string) (io.ReadCloser, error) { return os.Open(name) },
 		ReadFile:  os.ReadFile,
+		Stat:      os.Stat,
 		WriteFile: os.WriteFile,
 	}
 }
 
-func computeChecksum(paths []string, ops *FileOps) (string, error) {
-	hasher := sha256.New()
-	for _, path := range paths {
-		// hash.Hash.Write never returns an error per Go documentation
-		_, _ = io.WriteString(hasher, path)
-		_, _ = io.WriteString(hasher, "\x00")
+// Digest returns the content hash of the files matching inputs, with key mixed in.
+// A change to key (e.g. arguments or command text) changes the digest even when
+// the input files are identical; an empty key gives a plain content hash.
+// If ops is nil, DefaultFileOps() is used; if hashing is nil, files are hashed with
+// SHA-256 in parallel and without a stat cache.
+func Digest(
+	inputs []string,
+	key string,
+	matchFn func([]string) ([]string, error),
+	ops *FileOps,
+	hashing *HashOptions,
+) (string, error) {
+	files, err := HashFiles(inputs, matchFn, ops, hashing)
+	if err != nil {
+		return "", err
+	}
 
-		file, err := ops.OpenFile(path)
-		if err != nil {
-			return "", fmt.Errorf("opening %s: %w", path, err)
-		}
+	return CombineHashes(key, files), nil
+}
 
-		_, err = io.Copy(hasher, file)
-		if err != nil {
-			_ = file.Close()
+// HashFiles returns the content hash of each file matching inputs, keyed by path.
+// If ops is nil, DefaultFileOps() is used; if hashing is nil, files are hashed with
+// SHA-256 in parallel and without a stat cache.
+func HashFiles(
+	inputs []string,
+	matchFn func([]string) ([]string, error),
+	ops *FileOps,
+	hashing *HashOptions,
+) (map[string]string, error) {
+	if len(inputs) == 0 {
+		return nil, ErrNoInputPatterns
+	}
 
-			return "", fmt.Errorf("reading %s: %w", path, err)
-		}
+	if ops == nil {
+		ops = DefaultFileOps()
+	}
 
-		err = file.Close()
-		if err != nil {
-			return "", fmt.Errorf("closing %s: %w", path, err)
-		}
+	if hashing == nil {
+		hashing = &HashOptions{}
+	}
+
+	matches, err := matchFn(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	sums, err := hashing.hashFiles(matches, ops)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string, len(matches))
+	for i, path := range matches {
+		files[path] = sums[i]
 	}
 
-	return hex.EncodeToString(hasher.Sum(nil)), nil
+	return files, nil
 }
 
-func readChecksum(path string, ops *FileOps) (string, error) {
+// ReadChecksum returns the hash stored at path.
+func ReadChecksum(path string, ops *FileOps) (string, error) {
 	data, err := ops.ReadFile(path)
 	if err != nil {
 		return "", fmt.Errorf("reading checksum file: %w", err)
@@ -122,7 +190,8 @@ func readChecksum(path string, ops *FileOps) (string, error) {
 	return string(data), nil
 }
 
-func writeChecksum(path, sum string, ops *FileOps) error {
+// WriteChecksum stores sum at path, creating parent directories as needed.
+func WriteChecksum(path, sum string, ops *FileOps) error {
 	dir := filepath.Dir(path)
 	if dir != "." {
 		//nolint:mnd // standard cache directory permissions
@@ -0,0 +1,342 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Exported variables.
+var (
+	ErrCopyIntoSelf = errors.New("cannot copy a directory into itself")
+	ErrNoMatches    = errors.New("patterns matched no files")
+)
+
+// FileCopy is one file for GlobCopies to copy: Src to Dst.
+type FileCopy struct {
+	Src string
+	Dst string
+}
+
+// Copy copies src to dst, replacing files already at dst. dst is the path of the copy,
+// not a directory to copy into. Directories are copied recursively and symlinks are
+// recreated rather than followed. Modes are preserved, and each file is written to a
+// temporary file and renamed into place, so readers never see a partial copy.
+func Copy(dst, src string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+
+	if info.IsDir() {
+		inside, err := isWithin(dst, src)
+		if err != nil {
+			return err
+		}
+
+		if inside {
+			return fmt.Errorf("%w: %s -> %s", ErrCopyIntoSelf, src, dst)
+		}
+	}
+
+	return copyPath(dst, src, info)
+}
+
+// GlobCopies returns the copies that put the files matching patterns under dir, each
+// at its path relative to the part of its pattern before the first wildcard: with
+// "assets/**/*.png", assets/img/logo.png is copied to dir/img/logo.png. Matched
+// directories aren't copied themselves; match their contents (e.g. "assets/**").
+// Exclusions (! patterns) apply to every pattern. It returns ErrNoMatches if nothing
+// matches, which usually means a pattern is wrong.
+func GlobCopies(dir string, patterns []string) ([]FileCopy, error) {
+	includes, exclusions := partitionNegated(patterns)
+	if len(includes) == 0 {
+		return nil, ErrNoPatterns
+	}
+
+	seen := make(map[string]bool)
+
+	var copies []FileCopy
+
+	for _, pattern := range includes {
+		matches, err := Match(append([]string{pattern}, exclusions...)...)
+		if err != nil {
+			return nil, err
+		}
+
+		base, _ := doublestar.SplitPattern(filepath.ToSlash(filepath.Clean(pattern)))
+
+		for _, path := range matches {
+			info, err := os.Lstat(path)
+			if err != nil {
+				return nil, fmt.Errorf("copying %s: %w", path, err)
+			}
+
+			if info.IsDir() || seen[path] {
+				continue
+			}
+
+			seen[path] = true
+
+			rel, err := filepath.Rel(filepath.FromSlash(base), path)
+			if err != nil {
+				return nil, fmt.Errorf("copying %s: %w", path, err)
+			}
+
+			copies = append(copies, FileCopy{Src: path, Dst: filepath.Join(dir, rel)})
+		}
+	}
+
+	if len(copies) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoMatches, strings.Join(patterns, " "))
+	}
+
+	return copies, nil
+}
+
+// Mkdir creates path, along with any missing parents. It is not an error for path
+// to exist already.
+func Mkdir(path string) error {
+	err := os.MkdirAll(path, dirMode)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Move moves src to dst, replacing dst if it is a file. Across file systems, where
+// it can't be renamed, src is copied and then removed.
+func Move(dst, src string) error {
+	err := os.MkdirAll(filepath.Dir(dst), dirMode)
+	if err != nil {
+		return fmt.Errorf("moving %s: %w", src, err)
+	}
+
+	err = os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+
+	if !crossDevice(err) {
+		return fmt.Errorf("moving %s: %w", src, err)
+	}
+
+	err = Copy(dst, src)
+	if err != nil {
+		return err
+	}
+
+	return Remove(src)
+}
+
+// Remove removes path and, if it is a directory, everything under it. It is not an
+// error for path not to exist. Read-only files and directories, such as Go's module
+// cache or read-only files on Windows, are made writable so they can be removed.
+// Symlinks are removed, never followed, so what they point to is left as it is.
+func Remove(path string) error {
+	err := os.RemoveAll(path)
+	if err == nil {
+		return nil
+	}
+
+	_ = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		// Chmod follows symlinks, which could make files outside path writable.
+		if err == nil && d.Type()&fs.ModeSymlink == 0 {
+			mode := fs.FileMode(writableFileMode)
+			if d.IsDir() {
+				mode = writableDirMode
+			}
+
+			_ = os.Chmod(p, mode)
+		}
+
+		return nil
+	})
+
+	err = os.RemoveAll(path)
+	if err != nil {
+		return fmt.Errorf("removing %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// RemoveOwned removes the entries of dir that owned reports, by name, as belonging to
+// the caller, and then dir itself if nothing else is left in it, so a directory shared
+// with other files is never cleared out. It reports whether dir was removed. It is not
+// an error for dir not to exist.
+func RemoveOwned(dir string, owned func(name string) bool) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", dir, err)
+	}
+
+	kept := 0
+
+	for _, entry := range entries {
+		if !owned(entry.Name()) {
+			kept++
+			continue
+		}
+
+		err := Remove(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if kept > 0 {
+		return false, nil
+	}
+
+	err = os.Remove(dir)
+	if err != nil {
+		return false, fmt.Errorf("removing %s: %w", dir, err)
+	}
+
+	return true, nil
+}
+
+// WriteFileAtomic writes data to dest with mode, via a temporary file in the same
+// directory that is renamed into place, so readers see either the old content or
+// the new. Missing parent directories are created.
+func WriteFileAtomic(dest string, data []byte, mode fs.FileMode) error {
+	return writeAtomic(dest, mode, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// unexported constants.
+const (
+	dirMode          = 0o755
+	writableDirMode  = 0o700
+	writableFileMode = 0o600
+)
+
+// unexported variables.
+var (
+	errUnsupportedFile = errors.New("not a regular file, directory or symlink")
+)
+
+// copyPath copies src, described by info, to dst.
+func copyPath(dst, src string, info fs.FileInfo) error {
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		return copySymlink(dst, src)
+	case info.IsDir():
+		return copyDir(dst, src, info.Mode().Perm())
+	case info.Mode().IsRegular():
+		return copyFile(dst, src, info.Mode().Perm())
+	default:
+		return fmt.Errorf("copying %s: %w", src, errUnsupportedFile)
+	}
+}
+
+// copyDir copies the directory src and its contents to dst, then gives dst mode.
+// Until then dst stays writable, so read-only directories can be filled in.
+func copyDir(dst, src string, mode fs.FileMode) error {
+	err := os.MkdirAll(dst, mode|writableDirMode)
+	if err != nil {
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("copying %s: %w", src, err)
+		}
+
+		err = copyPath(filepath.Join(dst, entry.Name()), filepath.Join(src, entry.Name()), info)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.Chmod(dst, mode)
+	if err != nil {
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+
+	return nil
+}
+
+func copyFile(dst, src string, mode fs.FileMode) error {
+	//nolint:gosec // G304: Copying a file the caller named.
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+
+	defer func() { _ = in.Close() }()
+
+	err = writeAtomic(dst, mode, func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+
+	return nil
+}
+
+func copySymlink(dst, src string) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(dst), dirMode)
+	if err != nil {
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+
+	err = os.Remove(dst)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+
+	err = os.Symlink(target, dst)
+	if err != nil {
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+
+	return nil
+}
+
+// isWithin reports whether path is dir or lies under it.
+func isWithin(path, dir string) (bool, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false, fmt.Errorf("resolving %s: %w", path, err)
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false, fmt.Errorf("resolving %s: %w", dir, err)
+	}
+
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return false, nil //nolint:nilerr // on different volumes, so not within
+	}
+
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))), nil
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package internal
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errNotSameDevice is Windows' ERROR_NOT_SAME_DEVICE.
+const errNotSameDevice = syscall.Errno(17)
+
+// crossDevice reports whether err is a rename failing because its paths are on
+// different volumes.
+func crossDevice(err error) bool {
+	return errors.Is(err, errNotSameDevice)
+}
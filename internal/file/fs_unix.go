@@ -0,0 +1,14 @@
+//go:build unix
+
+package internal
+
+import (
+	"errors"
+	"syscall"
+)
+
+// crossDevice reports whether err is a rename failing because its paths are on
+// different file systems.
+func crossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
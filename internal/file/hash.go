@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"io"
+	"io/fs"
+	"runtime"
+	"sync"
+)
+
+// Exported variables.
+var (
+	ErrUnknownHashAlgorithm = errors.New("unknown hash algorithm")
+)
+
+// HashAlgorithm selects how Digest hashes each file's contents.
+type HashAlgorithm int
+
+// HashAlgorithm values.
+const (
+	// HashSHA256 hashes file contents with SHA-256 (the default).
+	HashSHA256 HashAlgorithm = iota
+	// HashCRC64 hashes file contents with CRC-64, which is faster but not
+	// collision resistant, so only suits inputs nobody would forge.
+	HashCRC64
+)
+
+// ParseHashAlgorithm returns the algorithm named by name: "sha256" or "crc64".
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	for _, alg := range []HashAlgorithm{HashSHA256, HashCRC64} {
+		if alg.String() == name {
+			return alg, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %q (want sha256 or crc64)", ErrUnknownHashAlgorithm, name)
+}
+
+// String returns the algorithm's name.
+func (a HashAlgorithm) String() string {
+	if a == HashCRC64 {
+		return "crc64"
+	}
+
+	return "sha256"
+}
+
+func (a HashAlgorithm) new() hash.Hash {
+	if a == HashCRC64 {
+		return crc64.New(crc64Table)
+	}
+
+	return sha256.New()
+}
+
+// HashOptions configures how Digest hashes the matched files. Files are hashed
+// on Workers goroutines (default GOMAXPROCS). With a StatCache, files whose size,
+// modification time and inode haven't changed reuse their recorded hash.
+type HashOptions struct {
+	Algorithm HashAlgorithm
+	StatCache *StatCache
+	Workers   int
+}
+
+// hashFile returns the hex-encoded hash of path's contents.
+func (o HashOptions) hashFile(path string, ops *FileOps) (string, error) {
+	var info fs.FileInfo
+
+	if o.StatCache != nil {
+		var err error
+
+		info, err = ops.Stat(path)
+		if err != nil {
+			return "", fmt.Errorf("reading %s: %w", path, err)
+		}
+
+		if sum, ok := o.StatCache.get(path, info, o.Algorithm); ok {
+			return sum, nil
+		}
+	}
+
+	file, err := ops.OpenFile(path)
+	if err != nil {
+		return "", fmt.Errorf("opening %s: %w", path, err)
+	}
+
+	hasher := o.Algorithm.new()
+
+	_, err = io.Copy(hasher, file)
+	if err != nil {
+		_ = file.Close()
+
+		return "", fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return "", fmt.Errorf("closing %s: %w", path, err)
+	}
+
+	sum := hex.EncodeToString(hasher.Sum(nil))
+
+	if o.StatCache != nil {
+		o.StatCache.put(path, info, o.Algorithm, sum)
+	}
+
+	return sum, nil
+}
+
+// hashFiles returns the hash of each of paths, in order, hashing them in parallel.
+// If any fail, the error for the first failing path is returned.
+func (o HashOptions) hashFiles(paths []string, ops *FileOps) ([]string, error) {
+	sums := make([]string, len(paths))
+	errs := make([]error, len(paths))
+
+	workers := o.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for range min(workers, len(paths)) {
+		wg.Go(func() {
+			for i := range indexes {
+				sums[i], errs[i] = o.hashFile(paths[i], ops)
+			}
+		})
+	}
+
+	for i := range paths {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sums, nil
+}
+
+// unexported variables.
+var (
+	crc64Table = crc64.MakeTable(crc64.ECMA)
+)
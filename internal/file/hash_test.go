@@ -0,0 +1,184 @@
+// TEST-036: Hashing properties - validates the stat cache, parallel hashing, and hash algorithms
+// traces: ARCH-002
+
+package internal_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	internalfile "github.com/toejough/targ/internal/file"
+)
+
+func BenchmarkDigest(b *testing.B) {
+	dir := b.TempDir()
+	paths := writeHashFiles(b, dir, 500, 16<<10)
+	matchFn := func([]string) ([]string, error) { return paths, nil }
+
+	// Serial without a stat cache is how Digest hashed files before the stat cache.
+	b.Run("Serial", func(b *testing.B) {
+		for b.Loop() {
+			_, err := internalfile.Digest(paths, "", matchFn, nil, &internalfile.HashOptions{Workers: 1})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Parallel", func(b *testing.B) {
+		for b.Loop() {
+			_, err := internalfile.Digest(paths, "", matchFn, nil, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("StatCacheHit", func(b *testing.B) {
+		cachePath := filepath.Join(dir, "stat-cache.json")
+		warm := &internalfile.HashOptions{StatCache: internalfile.LoadStatCache(cachePath)}
+
+		_, err := internalfile.Digest(paths, "", matchFn, nil, warm)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		err = warm.StatCache.Save()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.ResetTimer()
+
+		for b.Loop() {
+			hashing := &internalfile.HashOptions{StatCache: internalfile.LoadStatCache(cachePath)}
+
+			_, err := internalfile.Digest(paths, "", matchFn, nil, hashing)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestProperty_Hashing(t *testing.T) {
+	t.Parallel()
+
+	t.Run("StatCacheGivesTheSameDigest", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		paths := writeHashFiles(t, dir, 20, 64)
+		matchFn := func([]string) ([]string, error) { return paths, nil }
+		cachePath := filepath.Join(dir, "stat-cache.json")
+
+		plain, err := internalfile.Digest(paths, "key", matchFn, nil, &internalfile.HashOptions{Workers: 1})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		for range 2 {
+			hashing := &internalfile.HashOptions{StatCache: internalfile.LoadStatCache(cachePath)}
+
+			cached, err := internalfile.Digest(paths, "key", matchFn, nil, hashing)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cached).To(Equal(plain))
+			g.Expect(hashing.StatCache.Save()).To(Succeed())
+		}
+
+		g.Expect(cachePath).To(BeAnExistingFile())
+	})
+
+	t.Run("StatCacheNoticesSameSizeEdits", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		paths := writeHashFiles(t, dir, 3, 64)
+		matchFn := func([]string) ([]string, error) { return paths, nil }
+		cachePath := filepath.Join(dir, "stat-cache.json")
+
+		digest := func() string {
+			hashing := &internalfile.HashOptions{StatCache: internalfile.LoadStatCache(cachePath)}
+
+			sum, err := internalfile.Digest(paths, "", matchFn, nil, hashing)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(hashing.StatCache.Save()).To(Succeed())
+
+			return sum
+		}
+
+		before := digest()
+
+		info, err := os.Stat(paths[1])
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(os.WriteFile(paths[1], []byte(fmt.Sprintf("%064d", 9)), 0o600)).To(Succeed())
+		g.Expect(os.Chtimes(paths[1], info.ModTime(), info.ModTime().Add(time.Second))).To(Succeed())
+
+		g.Expect(digest()).ToNot(Equal(before))
+	})
+
+	t.Run("AlgorithmsDiffer", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		paths := writeHashFiles(t, dir, 3, 64)
+		matchFn := func([]string) ([]string, error) { return paths, nil }
+
+		sha, err := internalfile.Digest(paths, "", matchFn, nil, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		crc := &internalfile.HashOptions{Algorithm: internalfile.HashCRC64}
+
+		fast, err := internalfile.Digest(paths, "", matchFn, nil, crc)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(fast).ToNot(Equal(sha))
+
+		again, err := internalfile.Digest(paths, "", matchFn, nil, crc)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(again).To(Equal(fast))
+	})
+
+	t.Run("ParseHashAlgorithmRejectsUnknownNames", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		alg, err := internalfile.ParseHashAlgorithm("crc64")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(alg).To(Equal(internalfile.HashCRC64))
+
+		_, err = internalfile.ParseHashAlgorithm("md5")
+		g.Expect(err).To(MatchError(internalfile.ErrUnknownHashAlgorithm))
+	})
+}
+
+// writeHashFiles writes count files of size bytes under dir, dated in the past so the
+// stat cache trusts their timestamps, and returns their paths.
+func writeHashFiles(tb testing.TB, dir string, count, size int) []string {
+	tb.Helper()
+
+	past := time.Now().Add(-time.Hour)
+	paths := make([]string, count)
+
+	for i := range paths {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("file-%03d.txt", i))
+		content := fmt.Sprintf("%0*d", size, i)
+
+		err := os.WriteFile(paths[i], []byte(content), 0o600)
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		err = os.Chtimes(paths[i], past, past)
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	return paths
+}
@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// unexported variables.
+var (
+	//nolint:gochecknoglobals // read in order, so .targignore rules override .gitignore
+	ignoreFileNames = []string{".gitignore", ".targignore"}
+)
+
+// ignoreFiles applies .gitignore and .targignore files with git's semantics: the
+// last matching rule wins, `!` re-includes, a trailing `/` only matches directories,
+// and nothing inside an ignored directory can be re-included. Files are read from
+// the repository root (the nearest directory containing .git) down to each path;
+// outside a repository, from the working directory down. .git is always ignored.
+type ignoreFiles struct {
+	cwd   string
+	roots map[string]string       // by directory: the root to read ignore files from, or ""
+	rules map[string][]ignoreRule // by absolute directory
+}
+
+// ignored reports whether path, a file or a directory if isDir, is ignored.
+func (f *ignoreFiles) ignored(filePath string, isDir bool) bool {
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return false
+	}
+
+	root := f.rootFor(filepath.Dir(abs))
+	if root == "" {
+		return false
+	}
+
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+
+	for i := range parts {
+		if parts[i] == ".git" {
+			return true
+		}
+
+		if f.matches(root, parts[:i+1], i < len(parts)-1 || isDir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches applies the rules of each directory from root down to parts' parent.
+func (f *ignoreFiles) matches(root string, parts []string, isDir bool) bool {
+	ignored := false
+
+	for depth := range parts {
+		dir := filepath.Join(append([]string{root}, parts[:depth]...)...)
+		rel := strings.Join(parts[depth:], "/")
+
+		for _, rule := range f.rulesFor(dir) {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+
+			if rule.matches(rel) {
+				ignored = !rule.negate
+			}
+		}
+	}
+
+	return ignored
+}
+
+// rootFor returns the directory to read ignore files from for paths in dir.
+func (f *ignoreFiles) rootFor(dir string) string {
+	root, ok := f.roots[dir]
+	if ok {
+		return root
+	}
+
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+
+	switch parent := filepath.Dir(dir); {
+	case err == nil:
+		root = dir
+	case parent != dir:
+		root = f.rootFor(parent)
+	}
+
+	if root == "" && (dir == f.cwd || strings.HasPrefix(dir, f.cwd+string(filepath.Separator))) {
+		root = f.cwd
+	}
+
+	f.roots[dir] = root
+
+	return root
+}
+
+// rulesFor returns the rules of the ignore files in dir, reading them the first time.
+func (f *ignoreFiles) rulesFor(dir string) []ignoreRule {
+	rules, ok := f.rules[dir]
+	if ok {
+		return rules
+	}
+
+	for _, name := range ignoreFileNames {
+		rules = append(rules, readIgnoreFile(filepath.Join(dir, name))...)
+	}
+
+	f.rules[dir] = rules
+
+	return rules
+}
+
+// ignoreRule is one pattern line of an ignore file.
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool // contains a slash, so it matches relative to the file's directory
+}
+
+func (r ignoreRule) matches(rel string) bool {
+	if r.anchored {
+		ok, _ := doublestar.Match(r.pattern, rel)
+		return ok
+	}
+
+	ok, _ := doublestar.Match(r.pattern, path.Base(rel))
+
+	return ok
+}
+
+func newIgnoreFiles() (*ignoreFiles, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("getting working directory: %w", err)
+	}
+
+	return &ignoreFiles{
+		cwd:   cwd,
+		roots: make(map[string]string),
+		rules: make(map[string][]ignoreRule),
+	}, nil
+}
+
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+
+	if after, ok := strings.CutPrefix(line, "!"); ok {
+		rule.negate = true
+		line = after
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+
+	if after, ok := strings.CutSuffix(line, "/"); ok {
+		rule.dirOnly = true
+		line = after
+	}
+
+	rule.anchored = strings.Contains(line, "/")
+	rule.pattern = strings.TrimPrefix(line, "/")
+
+	return rule, rule.pattern != ""
+}
+
+// readIgnoreFile returns the rules in the ignore file at filePath, or none if it doesn't exist.
+func readIgnoreFile(filePath string) []ignoreRule {
+	file, err := os.Open(filePath) //nolint:gosec // ignore files are read from the working tree
+	if err != nil {
+		return nil
+	}
+
+	defer file.Close()
+
+	var rules []ignoreRule
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreLine(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules
+}
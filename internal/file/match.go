@@ -18,17 +18,36 @@ var (
 	ErrUnmatchedBrace = errors.New("unmatched brace in pattern")
 )
 
+// MatchOptions configures how patterns are matched.
+type MatchOptions struct {
+	// RespectIgnoreFiles also excludes what .gitignore and .targignore files ignore,
+	// read from the repository root down.
+	RespectIgnoreFiles bool
+}
+
 // Match expands one or more patterns using fish-style globs (including ** and {a,b}).
+// Patterns starting with ! exclude what they match, along with everything under a
+// directory they match, from the other patterns' results.
 func Match(patterns ...string) ([]string, error) {
+	return MatchWithOptions(patterns, MatchOptions{})
+}
+
+// MatchWithOptions expands patterns as Match does, with opts applied.
+func MatchWithOptions(patterns []string, opts MatchOptions) ([]string, error) {
 	if len(patterns) == 0 {
 		return nil, ErrNoPatterns
 	}
 
+	includes, filter, err := splitPatterns(patterns, opts)
+	if err != nil {
+		return nil, err
+	}
+
 	seen := make(map[string]bool)
 
 	var matches []string
 
-	for _, pattern := range patterns {
+	for _, pattern := range includes {
 		pattern = filepath.Clean(pattern)
 		patternFileSys, base := patternFS(pattern)
 
@@ -42,12 +61,7 @@ func Match(patterns ...string) ([]string, error) {
 				exp = filepath.Clean(strings.TrimPrefix(exp, base))
 			}
 
-			list, err := doublestar.Glob(patternFileSys, exp)
-			if err != nil {
-				return nil, fmt.Errorf("matching pattern %q: %w", exp, err)
-			}
-
-			for _, match := range list {
+			err := doublestar.GlobWalk(patternFileSys, exp, func(match string, entry fs.DirEntry) error {
 				path := match
 				if base != "" {
 					path = filepath.Join(base, match)
@@ -56,8 +70,15 @@ func Match(patterns ...string) ([]string, error) {
 				if !seen[path] {
 					seen[path] = true
 
-					matches = append(matches, path)
+					if !filter.excludes(path, entry.IsDir()) {
+						matches = append(matches, path)
+					}
 				}
+
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("matching pattern %q: %w", exp, err)
 			}
 		}
 	}
@@ -67,6 +88,74 @@ func Match(patterns ...string) ([]string, error) {
 	return matches, nil
 }
 
+// MatchesPath reports whether MatchWithOptions(patterns, opts) would include path,
+// if it exists, without globbing the file system.
+func MatchesPath(path string, patterns []string, opts MatchOptions) (bool, error) {
+	includes, filter, err := splitPatterns(patterns, opts)
+	if err != nil {
+		return false, err
+	}
+
+	path = filepath.Clean(path)
+
+	for _, pattern := range includes {
+		expanded, err := expandBraces(filepath.ToSlash(filepath.Clean(pattern)))
+		if err != nil {
+			return false, err
+		}
+
+		for _, exp := range expanded {
+			ok, err := doublestar.Match(exp, filepath.ToSlash(path))
+			if err != nil {
+				return false, fmt.Errorf("matching pattern %q: %w", exp, err)
+			}
+
+			if !ok {
+				continue
+			}
+
+			isDir := false
+
+			if filter.ignore != nil {
+				info, err := os.Lstat(path)
+				isDir = err == nil && info.IsDir()
+			}
+
+			return !filter.excludes(path, isDir), nil
+		}
+	}
+
+	return false, nil
+}
+
+// matchFilter holds the exclusions among a set of patterns.
+type matchFilter struct {
+	negated []string     // cleaned and brace-expanded, with forward slashes
+	ignore  *ignoreFiles // nil unless ignore files are respected
+}
+
+// excludes reports whether path, or a directory above it, is excluded. isDir says
+// whether path itself is a directory, which some ignore file rules depend on.
+func (f *matchFilter) excludes(path string, isDir bool) bool {
+	for cur := filepath.ToSlash(path); ; cur = filepath.ToSlash(filepath.Dir(cur)) {
+		for _, neg := range f.negated {
+			if ok, _ := doublestar.Match(neg, cur); ok {
+				return true
+			}
+		}
+
+		if cur == "." || cur == "/" || !strings.Contains(cur, "/") {
+			break
+		}
+	}
+
+	if f.ignore == nil {
+		return false
+	}
+
+	return f.ignore.ignored(path, isDir)
+}
+
 func expandBraces(pattern string) ([]string, error) {
 	start := strings.Index(pattern, "{")
 	if start == -1 {
@@ -116,6 +205,40 @@ func patternFS(pattern string) (fs.FS, string) {
 	return os.DirFS("."), ""
 }
 
+// splitPatterns separates the patterns that select files from the exclusions,
+// which include ignore files if opts respects them.
+func splitPatterns(patterns []string, opts MatchOptions) ([]string, *matchFilter, error) {
+	var includes []string
+
+	filter := &matchFilter{}
+
+	if opts.RespectIgnoreFiles {
+		ignore, err := newIgnoreFiles()
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading ignore files: %w", err)
+		}
+
+		filter.ignore = ignore
+	}
+
+	for _, pattern := range patterns {
+		negated, ok := strings.CutPrefix(pattern, "!")
+		if !ok {
+			includes = append(includes, pattern)
+			continue
+		}
+
+		expanded, err := expandBraces(filepath.ToSlash(filepath.Clean(negated)))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		filter.negated = append(filter.negated, expanded...)
+	}
+
+	return includes, filter, nil
+}
+
 func splitBraceOptions(content string) []string {
 	var parts []string
 
@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Exported variables.
+var (
+	ErrNoOutputPatterns = errors.New("no output patterns provided")
+)
+
+// Newer reports whether outputs need rebuilding from inputs.
+// It returns true when any output pattern matches no files, or when the newest
+// input was modified after the oldest output. Inputs that match nothing are not
+// an error: there is nothing to rebuild from, so existing outputs are up to date.
+// Inputs are matched with inputOpts and outputs without options, since outputs are
+// often what ignore files ignore. If ops is nil, DefaultFileOps() is used.
+func Newer(
+	inputs, outputs []string,
+	inputOpts MatchOptions,
+	matchFn func([]string, MatchOptions) ([]string, error),
+	ops *FileOps,
+) (bool, error) {
+	if len(inputs) == 0 {
+		return false, ErrNoInputPatterns
+	}
+
+	if len(outputs) == 0 {
+		return false, ErrNoOutputPatterns
+	}
+
+	if ops == nil {
+		ops = DefaultFileOps()
+	}
+
+	var outputFiles []string
+
+	selecting, exclusions := partitionNegated(outputs)
+
+	// Match output patterns one at a time so a single missing output is detected
+	// even when other patterns match. Exclusions apply to each of them.
+	for _, pattern := range selecting {
+		matches, err := matchFn(append([]string{pattern}, exclusions...), MatchOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		if len(matches) == 0 {
+			return true, nil
+		}
+
+		outputFiles = append(outputFiles, matches...)
+	}
+
+	inputFiles, err := matchFn(inputs, inputOpts)
+	if err != nil {
+		return false, err
+	}
+
+	if len(inputFiles) == 0 {
+		return false, nil
+	}
+
+	newestInput, err := modTimeBound(inputFiles, ops, time.Time.After)
+	if err != nil {
+		return false, err
+	}
+
+	oldestOutput, err := modTimeBound(outputFiles, ops, time.Time.Before)
+	if err != nil {
+		return false, err
+	}
+
+	return newestInput.After(oldestOutput), nil
+}
+
+// modTimeBound returns the modification time among paths that wins the comparison
+// (the newest for time.Time.After, the oldest for time.Time.Before).
+func modTimeBound(
+	paths []string,
+	ops *FileOps,
+	wins func(time.Time, time.Time) bool,
+) (time.Time, error) {
+	var bound time.Time
+
+	for i, path := range paths {
+		info, err := ops.Stat(path)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("getting file info for %s: %w", path, err)
+		}
+
+		if i == 0 || wins(info.ModTime(), bound) {
+			bound = info.ModTime()
+		}
+	}
+
+	return bound, nil
+}
+
+// partitionNegated separates patterns that select files from ! exclusions.
+func partitionNegated(patterns []string) ([]string, []string) {
+	var selecting, exclusions []string
+
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			exclusions = append(exclusions, pattern)
+		} else {
+			selecting = append(selecting, pattern)
+		}
+	}
+
+	return selecting, exclusions
+}
@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Exported variables.
+var (
+	ErrCacheCorrupt       = errors.New("cache data failed hash verification")
+	ErrCacheMiss          = errors.New("cache miss")
+	ErrUnsupportedBackend = errors.New("unsupported remote cache")
+)
+
+// unexported variables.
+var (
+	errUnexpectedStatus = errors.New("unexpected status")
+)
+
+// CacheBackend stores artifact cache data outside the local cache directory, so
+// results can be shared between machines. Keys are slash-separated relative paths
+// ("entries/<digest>.json", "objects/<sha256>"), mirroring the local layout.
+type CacheBackend interface {
+	// Get returns the value stored at key, or an error wrapping ErrCacheMiss if there is none.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Put stores body at key, replacing any existing value.
+	Put(ctx context.Context, key string, body io.Reader) error
+}
+
+// DirBackend is a CacheBackend backed by a shared directory, such as an NFS mount.
+type DirBackend struct {
+	Dir string
+}
+
+// Get opens the file for key.
+func (b DirBackend) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	//nolint:gosec // G304: Reading from the configured cache directory.
+	file, err := os.Open(b.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrCacheMiss, key)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading remote cache %s: %w", key, err)
+	}
+
+	return file, nil
+}
+
+// Put writes body to the file for key atomically, so concurrent readers never see a partial value.
+func (b DirBackend) Put(_ context.Context, key string, body io.Reader) error {
+	err := writeAtomic(b.path(key), cacheFileMode, func(w io.Writer) error {
+		_, err := io.Copy(w, body)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("writing remote cache %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (b DirBackend) path(key string) string {
+	return filepath.Join(b.Dir, filepath.FromSlash(key))
+}
+
+// HTTPBackend is a CacheBackend that GETs and PUTs keys relative to a base URL.
+// A 404 response is a cache miss.
+type HTTPBackend struct {
+	URL    string
+	Client *http.Client // if nil, http.DefaultClient is used
+}
+
+// Get fetches key from the server.
+func (b HTTPBackend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url(key), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating remote cache request: %w", err)
+	}
+
+	resp, err := b.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("reading remote cache %s: %w", key, err)
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		return resp.Body, nil
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrCacheMiss, key)
+	}
+
+	return nil, fmt.Errorf("reading remote cache %s: %w: %s", key, errUnexpectedStatus, resp.Status)
+}
+
+// Put uploads body to key on the server.
+func (b HTTPBackend) Put(ctx context.Context, key string, body io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, b.url(key), body)
+	if err != nil {
+		return fmt.Errorf("creating remote cache request: %w", err)
+	}
+
+	resp, err := b.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("writing remote cache %s: %w", key, err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("writing remote cache %s: %w: %s", key, errUnexpectedStatus, resp.Status)
+	}
+
+	return nil
+}
+
+func (b HTTPBackend) client() *http.Client {
+	if b.Client != nil {
+		return b.Client
+	}
+
+	return http.DefaultClient
+}
+
+func (b HTTPBackend) url(key string) string {
+	return strings.TrimSuffix(b.URL, "/") + "/" + key
+}
+
+// ParseCacheBackend returns the backend for spec: an http:// or https:// URL
+// selects HTTPBackend, a file:// URL or plain path selects DirBackend.
+func ParseCacheBackend(spec string) (CacheBackend, error) {
+	switch {
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return HTTPBackend{URL: spec}, nil
+	case strings.HasPrefix(spec, "file://"):
+		return DirBackend{Dir: strings.TrimPrefix(spec, "file://")}, nil
+	case strings.Contains(spec, "://"):
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedBackend, spec)
+	default:
+		return DirBackend{Dir: spec}, nil
+	}
+}
@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// unexported constants.
+const (
+	statCacheVersion = 1
+	// statCacheRacyWindow is how recently a file may have been modified and still be
+	// hashed again next time: a write within the same mtime tick as the hash would
+	// otherwise go unnoticed on file systems with coarse timestamps.
+	statCacheRacyWindow = 2 * time.Second
+)
+
+// StatCache remembers file hashes keyed on each file's size, modification time and
+// inode, so Digest can skip reading files that haven't changed since they were hashed.
+// It is safe for concurrent use.
+type StatCache struct {
+	path    string
+	cwd     string
+	mu      sync.Mutex
+	entries map[string]statCacheEntry
+	changed bool
+}
+
+// LoadStatCache reads the stat cache stored at path. A missing or unreadable cache
+// starts out empty: the cache only saves work, so nothing is lost without it.
+func LoadStatCache(path string) *StatCache {
+	cwd, _ := os.Getwd()
+
+	cache := &StatCache{path: path, cwd: cwd, entries: make(map[string]statCacheEntry)}
+
+	//nolint:gosec // G304: Reading the caller's cache file is the function's purpose.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+
+	var stored statCacheFile
+
+	err = json.Unmarshal(data, &stored)
+	if err != nil || stored.Version != statCacheVersion || stored.Entries == nil {
+		return cache
+	}
+
+	cache.entries = stored.Entries
+
+	return cache
+}
+
+// Save writes the cache back to its path if anything was hashed since it was loaded.
+// Entries for files that no longer exist are dropped.
+func (c *StatCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.changed {
+		return nil
+	}
+
+	for path := range c.entries {
+		_, err := os.Lstat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			delete(c.entries, path)
+		}
+	}
+
+	data, err := json.Marshal(statCacheFile{Version: statCacheVersion, Entries: c.entries})
+	if err != nil {
+		return fmt.Errorf("encoding stat cache: %w", err)
+	}
+
+	err = WriteFileAtomic(c.path, data, cacheFileMode)
+	if err != nil {
+		return fmt.Errorf("writing stat cache: %w", err)
+	}
+
+	c.changed = false
+
+	return nil
+}
+
+// get returns the recorded hash of path if info still matches what was recorded.
+func (c *StatCache) get(path string, info fs.FileInfo, alg HashAlgorithm) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[c.key(path)]
+	if !ok || entry != newStatCacheEntry(info, alg, entry.Hash) {
+		return "", false
+	}
+
+	return entry.Hash, true
+}
+
+// key returns the absolute form of path, so relative and absolute patterns share entries.
+func (c *StatCache) key(path string) string {
+	if filepath.IsAbs(path) || c.cwd == "" {
+		return filepath.Clean(path)
+	}
+
+	return filepath.Join(c.cwd, path)
+}
+
+// put records hash for path as described by info, unless path was modified too
+// recently for its timestamp to be trusted.
+func (c *StatCache) put(path string, info fs.FileInfo, alg HashAlgorithm, hash string) {
+	if time.Since(info.ModTime()) < statCacheRacyWindow {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[c.key(path)] = newStatCacheEntry(info, alg, hash)
+	c.changed = true
+}
+
+type statCacheEntry struct {
+	Size      int64  `json:"size"`
+	ModTime   int64  `json:"mtime"`
+	Inode     uint64 `json:"inode,omitempty"`
+	Algorithm string `json:"algorithm"`
+	Hash      string `json:"hash"`
+}
+
+type statCacheFile struct {
+	Version int                       `json:"version"`
+	Entries map[string]statCacheEntry `json:"entries"`
+}
+
+func newStatCacheEntry(info fs.FileInfo, alg HashAlgorithm, hash string) statCacheEntry {
+	return statCacheEntry{
+		Size:      info.Size(),
+		ModTime:   info.ModTime().UnixNano(),
+		Inode:     inode(info),
+		Algorithm: alg.String(),
+		Hash:      hash,
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package internal
+
+import "io/fs"
+
+// inode returns 0: the stat cache relies on size and modification time alone here.
+func inode(fs.FileInfo) uint64 {
+	return 0
+}
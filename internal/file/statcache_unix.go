@@ -0,0 +1,18 @@
+//go:build unix
+
+package internal
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// inode returns the inode number of the file described by info.
+func inode(info fs.FileInfo) uint64 {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0
+	}
+
+	return uint64(stat.Ino) //nolint:unconvert // Ino is narrower on some platforms.
+}
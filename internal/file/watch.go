@@ -2,10 +2,21 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Exported variables.
+var (
+	ErrWatchBackendUnsupported = errors.New("native file watching is not supported on this platform")
 )
 
 // ChangeSet holds the files that changed between watch polls.
@@ -15,39 +26,157 @@ type ChangeSet struct {
 	Modified []string
 }
 
+// Files returns every changed path, whether added, removed or modified, sorted.
+func (c ChangeSet) Files() []string {
+	files := make([]string, 0, len(c.Added)+len(c.Removed)+len(c.Modified))
+	files = append(files, c.Added...)
+	files = append(files, c.Removed...)
+	files = append(files, c.Modified...)
+	sort.Strings(files)
+
+	return files
+}
+
+// NotifyEvent is a file system event from a Notifier.
+// Dir is set when a directory appeared under a watched one, or when events were
+// lost, so the set of watched directories must be refreshed.
+type NotifyEvent struct {
+	Path string
+	Dir  bool
+}
+
+// Notifier abstracts a native file system event source (inotify on Linux) for testing.
+type Notifier interface {
+	// Add watches the entries of dir. Adding a directory twice is not an error.
+	Add(dir string) error
+	Events() <-chan NotifyEvent
+	Errors() <-chan error
+	Close() error
+}
+
 // Ticker abstracts time.Ticker for testing.
 type Ticker interface {
 	C() <-chan time.Time
 	Stop()
 }
 
+// WatchBackend selects how Watch detects changes.
+type WatchBackend int
+
+// WatchBackend values.
+const (
+	// WatchBackendAuto uses native file system events where supported and falls back to polling.
+	WatchBackendAuto WatchBackend = iota
+	// WatchBackendPoll re-globs the patterns and stats every match each Interval.
+	WatchBackendPoll
+	// WatchBackendNative uses native file system events, failing where they aren't supported.
+	WatchBackendNative
+)
+
+// WatchBusyPolicy decides what happens to changes detected while the callback is running.
+type WatchBusyPolicy int
+
+// WatchBusyPolicy values.
+const (
+	// WatchQueue reports changes that arrive during a run once it returns (the default).
+	WatchQueue WatchBusyPolicy = iota
+	// WatchDrop discards changes that arrive during a run.
+	WatchDrop
+	// WatchRestart cancels the running callback's context and runs it again once it returns,
+	// with the interrupted run's changes included.
+	WatchRestart
+)
+
 // WatchOps provides watch operations for dependency injection.
+// If NewNotifier is nil, only polling is available.
 type WatchOps struct {
-	NewTicker func(time.Duration) Ticker
-	Stat      func(string) (os.FileInfo, error)
+	NewTicker   func(time.Duration) Ticker
+	NewNotifier func() (Notifier, error)
+	Stat        func(string) (os.FileInfo, error)
 }
 
 // WatchOptions configures file watching behavior.
+// Interval only applies to the polling backend.
+//
+// With Debounce set, the callback runs once no change has been seen for Debounce,
+// with every change since the last run. MaxWait caps how long a steady stream of
+// changes can hold it back.
+//
+// RunFirst runs the callback once as soon as watching starts, with an empty ChangeSet,
+// so that with WatchRestart even a first run that never returns (a dev server) is
+// restarted by the next change.
+//
+// GracePeriod is how long commands run by a cancelled run get to exit after SIGTERM
+// before they are killed (default 5s). Watch itself only records it; the caller
+// applies it to the run's context.
+//
+// Each value received on Rerun runs the callback again, with any changes not yet
+// reported, as though files had changed. OnBusy applies as it does to changes.
+//
+// RespectIgnoreFiles leaves out what .gitignore and .targignore files ignore, as
+// MatchOptions does.
 type WatchOptions struct {
-	Interval time.Duration
+	Interval           time.Duration
+	Backend            WatchBackend
+	Debounce           time.Duration
+	MaxWait            time.Duration
+	OnBusy             WatchBusyPolicy
+	RunFirst           bool
+	GracePeriod        time.Duration
+	Rerun              <-chan struct{}
+	RespectIgnoreFiles bool
+}
+
+// Grace returns GracePeriod, or the default if it isn't set.
+func (o WatchOptions) Grace() time.Duration {
+	if o.GracePeriod > 0 {
+		return o.GracePeriod
+	}
+
+	return defaultGracePeriod
+}
+
+// MatchOptions returns the options patterns are matched with.
+func (o WatchOptions) MatchOptions() MatchOptions {
+	return MatchOptions{RespectIgnoreFiles: o.RespectIgnoreFiles}
 }
 
 // DefaultWatchOps returns the standard implementations.
 func DefaultWatchOps() *WatchOps {
 	return &WatchOps{
-		NewTicker: func(d time.Duration) Ticker { return &realTicker{ticker: time.NewTicker(d)} },
-		Stat:      os.Stat,
+		NewTicker:   func(d time.Duration) Ticker { return &realTicker{ticker: time.NewTicker(d)} },
+		NewNotifier: newNotifier,
+		Stat:        os.Stat,
 	}
 }
 
-// Watch polls patterns for changes and invokes callback with any detected changes.
+// Watch watches patterns for changes and invokes callback with any detected changes.
+// With native events, patterns are only re-globbed when something under their base
+// directories changes; the changes reported are the same as when polling.
+// Patterns are expanded with matchFn, given opts.MatchOptions().
 // If ops is nil, DefaultWatchOps() is used.
 func Watch(
 	ctx context.Context,
 	patterns []string,
 	opts WatchOptions,
 	callback func(ChangeSet) error,
-	matchFn func([]string) ([]string, error),
+	matchFn func([]string, MatchOptions) ([]string, error),
+	ops *WatchOps,
+) error {
+	return WatchContext(ctx, patterns, opts, func(_ context.Context, changes ChangeSet) error {
+		return callback(changes)
+	}, matchFn, ops)
+}
+
+// WatchContext is Watch with a callback that takes a context. The context is cancelled
+// when the watch ends and, with WatchRestart, when newer changes interrupt the run.
+// Watch returns once the running callback has returned.
+func WatchContext(
+	ctx context.Context,
+	patterns []string,
+	opts WatchOptions,
+	callback func(context.Context, ChangeSet) error,
+	matchFn func([]string, MatchOptions) ([]string, error),
 	ops *WatchOps,
 ) error {
 	if len(patterns) == 0 {
@@ -58,37 +187,57 @@ func Watch(
 		ops = DefaultWatchOps()
 	}
 
+	w := &watcher{
+		patterns: patterns,
+		opts:     opts,
+		callback: callback,
+		matchFn: func(p []string) ([]string, error) {
+			return matchFn(p, opts.MatchOptions())
+		},
+		ops: ops,
+	}
+
+	if opts.Backend != WatchBackendPoll {
+		notifier, err := startNotifier(patterns, opts.MatchOptions(), ops)
+
+		switch {
+		case err == nil:
+			defer notifier.Close()
+
+			w.notifier = notifier
+
+			return w.watch(ctx)
+		case opts.Backend == WatchBackendNative:
+			return err
+		}
+	}
+
 	interval := opts.Interval
 	if interval == 0 {
 		interval = defaultWatchInterval
 	}
 
-	prev, err := snapshot(patterns, matchFn, ops)
-	if err != nil {
-		return err
-	}
-
 	ticker := ops.NewTicker(interval)
 	defer ticker.Stop()
 
-	for {
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("watch cancelled: %w", ctx.Err())
-		case <-ticker.C():
-			prev, err = processTick(prev, patterns, matchFn, ops, callback)
-			if err != nil {
-				return err
-			}
-		}
-	}
+	w.ticks = ticker.C()
+
+	return w.watch(ctx)
 }
 
 // unexported constants.
 const (
+	defaultGracePeriod   = 5 * time.Second
 	defaultWatchInterval = 250 * time.Millisecond
 )
 
+// dirWatch is a directory to watch for a pattern, and how many levels below it
+// the pattern can match (-1 for any depth).
+type dirWatch struct {
+	dir   string
+	depth int
+}
+
 type fileSnapshot struct {
 	Files map[string]int64
 	List  []string
@@ -103,6 +252,288 @@ func (t *realTicker) C() <-chan time.Time { return t.ticker.C }
 
 func (t *realTicker) Stop() { t.ticker.Stop() }
 
+// watchRun is a callback run in progress.
+type watchRun struct {
+	cancel    context.CancelFunc
+	done      chan error
+	from      *fileSnapshot // what the previous run reported, restored if this one is restarted
+	restarted bool
+}
+
+// watcher takes a snapshot whenever the backend reports activity, and runs the
+// callback with the difference from the snapshot the previous run was given.
+type watcher struct {
+	patterns []string
+	opts     WatchOptions
+	callback func(context.Context, ChangeSet) error
+	matchFn  func([]string) ([]string, error)
+	ops      *WatchOps
+	notifier Notifier         // nil when polling
+	ticks    <-chan time.Time // nil with a notifier
+
+	latest   *fileSnapshot
+	reported *fileSnapshot
+	first    time.Time   // when the oldest unreported change was seen
+	settle   *time.Timer // fires once changes have been quiet for Debounce
+	settled  bool        // unreported changes can be reported
+	forced   bool        // a rerun was requested, so run even without changes
+	run      *watchRun
+}
+
+// changed handles a snapshot that differs from the previous one.
+func (w *watcher) changed() {
+	if w.run != nil {
+		switch w.opts.OnBusy {
+		case WatchDrop:
+			return
+		case WatchRestart:
+			w.run.restarted = true
+			w.run.cancel()
+		case WatchQueue:
+		}
+	}
+
+	now := time.Now()
+	if w.first.IsZero() {
+		w.first = now
+	}
+
+	wait := w.opts.Debounce
+	if w.opts.MaxWait > 0 {
+		wait = min(wait, w.opts.MaxWait-now.Sub(w.first))
+	}
+
+	if wait <= 0 {
+		w.settle.Stop()
+		w.settled = true
+
+		return
+	}
+
+	w.settled = false
+	w.settle.Reset(wait)
+}
+
+// dispatch starts the callback if changes have settled and it isn't already running.
+func (w *watcher) dispatch(ctx context.Context) {
+	if w.run != nil || !w.settled {
+		return
+	}
+
+	w.settled = false
+	w.first = time.Time{}
+
+	changes := diffSnapshot(w.reported, w.latest)
+	if changes == nil && w.forced {
+		changes = &ChangeSet{Added: []string{}, Removed: []string{}, Modified: []string{}}
+	}
+
+	w.forced = false
+
+	if changes != nil {
+		w.start(ctx, *changes)
+	}
+}
+
+// finish handles the running callback returning err.
+func (w *watcher) finish(err error) error {
+	run := w.run
+	w.run = nil
+
+	run.cancel()
+
+	if err != nil && run.restarted {
+		w.reported = run.from
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if w.opts.OnBusy == WatchDrop {
+		w.reported = w.latest
+		w.first = time.Time{}
+		w.settled = false
+		w.settle.Stop()
+	}
+
+	return nil
+}
+
+// refresh takes a new snapshot, first re-adding watches if directories appeared.
+func (w *watcher) refresh(rescan bool) error {
+	if rescan {
+		err := addWatches(w.notifier, w.patterns, w.opts.MatchOptions())
+		if err != nil {
+			return err
+		}
+	}
+
+	next, err := snapshot(w.patterns, w.matchFn, w.ops)
+	if err != nil {
+		return err
+	}
+
+	if diffSnapshot(w.latest, next) != nil {
+		w.latest = next
+		w.changed()
+	}
+
+	return nil
+}
+
+// rerun handles a request to run the callback again, whether or not files changed.
+func (w *watcher) rerun() {
+	if w.run != nil {
+		switch w.opts.OnBusy {
+		case WatchDrop:
+			return
+		case WatchRestart:
+			w.run.restarted = true
+			w.run.cancel()
+		case WatchQueue:
+		}
+	}
+
+	w.forced = true
+	w.settled = true
+	w.settle.Stop()
+}
+
+// start runs the callback with changes, which are everything up to the latest snapshot.
+func (w *watcher) start(ctx context.Context, changes ChangeSet) {
+	runCtx, cancel := context.WithCancel(ctx)
+	run := &watchRun{cancel: cancel, done: make(chan error, 1), from: w.reported}
+	w.run = run
+	w.reported = w.latest
+
+	go func() {
+		run.done <- w.callback(runCtx, changes)
+	}()
+}
+
+// stop cancels the running callback, if any, and waits for it to return.
+func (w *watcher) stop() {
+	if w.run != nil {
+		w.run.cancel()
+		<-w.run.done
+	}
+}
+
+func (w *watcher) watch(ctx context.Context) error {
+	latest, err := snapshot(w.patterns, w.matchFn, w.ops)
+	if err != nil {
+		return err
+	}
+
+	w.latest = latest
+	w.reported = latest
+	w.settle = time.NewTimer(time.Hour)
+	w.settle.Stop()
+
+	defer w.stop()
+
+	if w.opts.RunFirst {
+		w.start(ctx, ChangeSet{Added: []string{}, Removed: []string{}, Modified: []string{}})
+	}
+
+	var (
+		events <-chan NotifyEvent
+		errs   <-chan error
+	)
+
+	if w.notifier != nil {
+		events = w.notifier.Events()
+		errs = w.notifier.Errors()
+	}
+
+	for {
+		var done <-chan error
+		if w.run != nil {
+			done = w.run.done
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("watch cancelled: %w", ctx.Err())
+		case <-w.ticks:
+			err = w.refresh(false)
+		case event := <-events:
+			err = w.refresh(drainEvents(w.notifier) || event.Dir)
+		case err := <-errs:
+			return fmt.Errorf("reading file events: %w", err)
+		case <-w.settle.C:
+			w.settled = true
+		case <-w.opts.Rerun:
+			w.rerun()
+		case runErr := <-done:
+			err = w.finish(runErr)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		w.dispatch(ctx)
+	}
+}
+
+// addWatches adds the directories patterns can match files in to notifier.
+// A base directory that doesn't exist yet is covered by watching its nearest
+// existing parent, whose Dir event refreshes the watches once it appears.
+func addWatches(notifier Notifier, patterns []string, opts MatchOptions) error {
+	includes, filter, err := splitPatterns(patterns, opts)
+	if err != nil {
+		return err
+	}
+
+	dirs, err := watchDirs(includes)
+	if err != nil {
+		return err
+	}
+
+	for _, w := range dirs {
+		dir := w.dir
+
+		for {
+			info, err := os.Stat(dir)
+			if err == nil && info.IsDir() {
+				break
+			}
+
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				return fmt.Errorf("watching %s: %w", w.dir, err)
+			}
+
+			dir = parent
+			w.depth = 0
+		}
+
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || !entry.IsDir() {
+				return nil //nolint:nilerr // unreadable entries can't be watched; polling would skip them too
+			}
+
+			if w.depth >= 0 && pathDepth(dir, path) > w.depth {
+				return fs.SkipDir
+			}
+
+			if path != dir && filter.excludes(path, true) {
+				return fs.SkipDir
+			}
+
+			return notifier.Add(path)
+		})
+		if err != nil {
+			return fmt.Errorf("watching %s: %w", dir, err)
+		}
+	}
+
+	return nil
+}
+
 func diffSnapshot(prev, next *fileSnapshot) *ChangeSet {
 	added := []string{}
 	removed := []string{}
@@ -140,30 +571,29 @@ func diffSnapshot(prev, next *fileSnapshot) *ChangeSet {
 	}
 }
 
-// processTick handles a single watch tick, returning the new snapshot if changes occurred.
-func processTick(
-	prev *fileSnapshot,
-	patterns []string,
-	matchFn func([]string) ([]string, error),
-	ops *WatchOps,
-	callback func(ChangeSet) error,
-) (*fileSnapshot, error) {
-	next, err := snapshot(patterns, matchFn, ops)
-	if err != nil {
-		return prev, err
-	}
+// drainEvents discards the events already queued on notifier, so a burst of events
+// is handled with a single snapshot. Returns whether any of them was a Dir event.
+func drainEvents(notifier Notifier) bool {
+	dir := false
 
-	changes := diffSnapshot(prev, next)
-	if changes != nil {
-		err := callback(*changes)
-		if err != nil {
-			return prev, err
+	for {
+		select {
+		case event := <-notifier.Events():
+			dir = dir || event.Dir
+		default:
+			return dir
 		}
+	}
+}
 
-		return next, nil
+// pathDepth returns how many directories below base path is.
+func pathDepth(base, path string) int {
+	rel, err := filepath.Rel(base, path)
+	if err != nil || rel == "." {
+		return 0
 	}
 
-	return prev, nil
+	return strings.Count(rel, string(filepath.Separator)) + 1
 }
 
 func snapshot(
@@ -177,20 +607,73 @@ func snapshot(
 	}
 
 	files := make(map[string]int64, len(matches))
+	list := make([]string, 0, len(matches))
 
 	for _, path := range matches {
 		info, err := ops.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Deleted since it was matched, so it is removed, not an error.
+			continue
+		}
+
 		if err != nil {
 			return nil, fmt.Errorf("getting file info for %s: %w", path, err)
 		}
 
 		files[path] = info.ModTime().UnixNano()
+		list = append(list, path)
 	}
 
-	sort.Strings(matches)
+	sort.Strings(list)
 
 	return &fileSnapshot{
 		Files: files,
-		List:  matches,
+		List:  list,
 	}, nil
 }
+
+// startNotifier returns a notifier watching the directories patterns can match in.
+func startNotifier(patterns []string, opts MatchOptions, ops *WatchOps) (Notifier, error) {
+	if ops.NewNotifier == nil {
+		return nil, ErrWatchBackendUnsupported
+	}
+
+	notifier, err := ops.NewNotifier()
+	if err != nil {
+		return nil, err
+	}
+
+	err = addWatches(notifier, patterns, opts)
+	if err != nil {
+		_ = notifier.Close()
+		return nil, err
+	}
+
+	return notifier, nil
+}
+
+// watchDirs returns the base directories of include patterns: the part before the
+// first glob metacharacter, after brace expansion.
+func watchDirs(patterns []string) ([]dirWatch, error) {
+	var dirs []dirWatch
+
+	for _, pattern := range patterns {
+		expanded, err := expandBraces(filepath.ToSlash(filepath.Clean(pattern)))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, exp := range expanded {
+			base, rest := doublestar.SplitPattern(exp)
+
+			depth := strings.Count(rest, "/")
+			if strings.Contains(rest, "**") {
+				depth = -1
+			}
+
+			dirs = append(dirs, dirWatch{dir: filepath.FromSlash(base), depth: depth})
+		}
+	}
+
+	return dirs, nil
+}
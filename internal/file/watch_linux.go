@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// unexported constants.
+const (
+	inotifyBufferSize = 64 * 1024
+	inotifyMask       = unix.IN_ATTRIB | unix.IN_CLOSE_WRITE | unix.IN_CREATE | unix.IN_DELETE |
+		unix.IN_DELETE_SELF | unix.IN_MODIFY | unix.IN_MOVE_SELF | unix.IN_MOVED_FROM |
+		unix.IN_MOVED_TO | unix.IN_ONLYDIR
+	notifyEventBuffer = 64
+)
+
+// inotifyNotifier implements Notifier with inotify. The descriptor is non-blocking,
+// so reads go through the runtime poller and Close interrupts a pending read.
+type inotifyNotifier struct {
+	fd     int
+	file   *os.File
+	events chan NotifyEvent
+	errors chan error
+	done   chan struct{}
+
+	mu   sync.Mutex
+	dirs map[int]string
+	once sync.Once
+}
+
+func (n *inotifyNotifier) Add(dir string) error {
+	wd, err := unix.InotifyAddWatch(n.fd, dir, inotifyMask)
+	if err != nil {
+		return fmt.Errorf("adding inotify watch for %s: %w", dir, err)
+	}
+
+	n.mu.Lock()
+	n.dirs[wd] = dir
+	n.mu.Unlock()
+
+	return nil
+}
+
+func (n *inotifyNotifier) Close() error {
+	var err error
+
+	n.once.Do(func() {
+		close(n.done)
+		err = n.file.Close()
+	})
+
+	return err
+}
+
+func (n *inotifyNotifier) Errors() <-chan error { return n.errors }
+
+func (n *inotifyNotifier) Events() <-chan NotifyEvent { return n.events }
+
+// event converts a raw inotify event. Returns false for events that carry no change.
+func (n *inotifyNotifier) event(wd int, mask uint32, name string) (NotifyEvent, bool) {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		return NotifyEvent{Dir: true}, true
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	dir, ok := n.dirs[wd]
+	if !ok {
+		return NotifyEvent{}, false
+	}
+
+	if mask&unix.IN_IGNORED != 0 {
+		delete(n.dirs, wd)
+		return NotifyEvent{}, false
+	}
+
+	return NotifyEvent{
+		Path: filepath.Join(dir, name),
+		Dir:  mask&unix.IN_ISDIR != 0 && mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0,
+	}, true
+}
+
+// read delivers events until the notifier is closed or reading fails.
+func (n *inotifyNotifier) read() {
+	buf := make([]byte, inotifyBufferSize)
+
+	for {
+		count, err := n.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				n.errors <- err
+			}
+
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= count; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			offset = nameStart + int(raw.Len)
+			name := strings.TrimRight(string(buf[nameStart:offset]), "\x00")
+
+			event, ok := n.event(int(raw.Wd), raw.Mask, name)
+			if !ok {
+				continue
+			}
+
+			select {
+			case n.events <- event:
+			case <-n.done:
+				return
+			}
+		}
+	}
+}
+
+func newNotifier() (Notifier, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("initializing inotify: %w", err)
+	}
+
+	n := &inotifyNotifier{
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		events: make(chan NotifyEvent, notifyEventBuffer),
+		errors: make(chan error, 1),
+		done:   make(chan struct{}),
+		dirs:   make(map[int]string),
+	}
+
+	go n.read()
+
+	return n, nil
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package internal
+
+func newNotifier() (Notifier, error) {
+	return nil, ErrWatchBackendUnsupported
+}
@@ -0,0 +1,88 @@
+// TEST-037: Watch properties - validates snapshots of files that change while they are taken
+// traces: ARCH-002
+
+package internal_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	internalfile "github.com/toejough/targ/internal/file"
+)
+
+func TestProperty_WatchSnapshot(t *testing.T) {
+	t.Parallel()
+
+	t.Run("FileDeletedDuringSnapshotIsRemoved", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		kept := filepath.Join(dir, "kept.txt")
+		deleted := filepath.Join(dir, "deleted.txt")
+		g.Expect(os.WriteFile(kept, []byte("kept"), 0o600)).To(Succeed())
+		g.Expect(os.WriteFile(deleted, []byte("deleted"), 0o600)).To(Succeed())
+
+		// The second snapshot deletes a file after globbing matched it, before it is stat'ed.
+		snapshots := 0
+		matchFn := func(patterns []string, opts internalfile.MatchOptions) ([]string, error) {
+			matches, err := internalfile.MatchWithOptions(patterns, opts)
+
+			snapshots++
+			if snapshots == 2 {
+				g.Expect(os.Remove(deleted)).To(Succeed())
+			}
+
+			return matches, err
+		}
+
+		ticks := make(chan time.Time)
+		ops := &internalfile.WatchOps{
+			NewTicker: func(time.Duration) internalfile.Ticker { return manualTicker(ticks) },
+			Stat:      os.Stat,
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		changes := make(chan internalfile.ChangeSet, 1)
+		done := make(chan error, 1)
+
+		go func() {
+			done <- internalfile.Watch(
+				ctx,
+				[]string{filepath.Join(dir, "*.txt")},
+				internalfile.WatchOptions{Backend: internalfile.WatchBackendPoll},
+				func(c internalfile.ChangeSet) error {
+					changes <- c
+					return nil
+				},
+				matchFn,
+				ops,
+			)
+		}()
+
+		ticks <- time.Now()
+		g.Eventually(changes).Should(Receive(Equal(internalfile.ChangeSet{
+			Added:    []string{},
+			Removed:  []string{deleted},
+			Modified: []string{},
+		})))
+
+		// Still watching.
+		ticks <- time.Now()
+
+		cancel()
+		g.Eventually(done).Should(Receive(MatchError(context.Canceled)))
+	})
+}
+
+// manualTicker is a Ticker that ticks when the test sends on its channel.
+type manualTicker chan time.Time
+
+func (m manualTicker) C() <-chan time.Time { return m }
+
+func (m manualTicker) Stop() {}
@@ -39,6 +39,7 @@ func All() []Def {
 	glob := placeholderGlob()
 	mode := placeholderMode()
 	n := placeholderN()
+	policy := placeholderBusyPolicy()
 	shell := placeholderShell()
 
 	return []Def{
@@ -74,6 +75,14 @@ func All() []Def {
 			Desc:  "Run multiple targets concurrently",
 			Mode:  FlagModeTargOnly,
 		},
+		{
+			Long:        "jobs",
+			Short:       "j",
+			Desc:        "Limit how many targets run at once",
+			Placeholder: &n,
+			TakesValue:  true,
+			Mode:        FlagModeTargOnly,
+		},
 		{
 			Long:        "times",
 			Desc:        "Run the command n times",
@@ -82,6 +91,11 @@ func All() []Def {
 			Mode:        FlagModeTargOnly,
 		},
 		{Long: "retry", Desc: "Continue on failure", Mode: FlagModeTargOnly},
+		{
+			Long: "dry-run",
+			Desc: "Print the execution plan without running anything",
+			Mode: FlagModeTargOnly,
+		},
 		{
 			Long:        "backoff",
 			Desc:        "Exponential backoff",
@@ -96,6 +110,44 @@ func All() []Def {
 			TakesValue:  true,
 			Mode:        FlagModeTargOnly,
 		},
+		{
+			Long:        "watch-debounce",
+			Desc:        "Wait for file changes to settle before re-running",
+			Placeholder: &duration,
+			TakesValue:  true,
+			Mode:        FlagModeTargOnly,
+		},
+		{
+			Long:        "watch-max-wait",
+			Desc:        "Longest to wait for file changes to settle",
+			Placeholder: &duration,
+			TakesValue:  true,
+			Mode:        FlagModeTargOnly,
+		},
+		{
+			Long:        "watch-grace",
+			Desc:        "Time a restarted run gets to exit before it is killed",
+			Placeholder: &duration,
+			TakesValue:  true,
+			Mode:        FlagModeTargOnly,
+		},
+		{
+			Long:        "watch-on-busy",
+			Desc:        "What to do with file changes during a run",
+			Placeholder: &policy,
+			TakesValue:  true,
+			Mode:        FlagModeTargOnly,
+		},
+		{
+			Long: "watch-deps",
+			Desc: "Also watch what dependencies cache and watch; re-run only what changes affect",
+			Mode: FlagModeTargOnly,
+		},
+		{
+			Long: "watch-status",
+			Desc: "Clear the screen and sum up each watch run; r re-runs, q quits",
+			Mode: FlagModeTargOnly,
+		},
 		{
 			Long:        "cache",
 			Desc:        "Skip if files unchanged (repeatable)",
@@ -103,6 +155,11 @@ func All() []Def {
 			TakesValue:  true,
 			Mode:        FlagModeTargOnly,
 		},
+		{
+			Long: "explain",
+			Desc: "Say why cached targets re-run: changed inputs, args, command or env",
+			Mode: FlagModeTargOnly,
+		},
 		{
 			Long:        "while",
 			Desc:        "Run while shell command succeeds",
@@ -117,6 +174,30 @@ func All() []Def {
 			TakesValue:  true,
 			Mode:        FlagModeTargOnly,
 		},
+		{
+			Long:     "graph",
+			Desc:     "Print the dependency graph (--format dot|mermaid|json)",
+			RootOnly: true,
+			Mode:     FlagModeTargOnly,
+		},
+		{
+			Long:     "cache-status",
+			Desc:     "Show which cached targets would re-run, and which inputs changed",
+			RootOnly: true,
+			Mode:     FlagModeTargOnly,
+		},
+		{
+			Long:     "cache-clean",
+			Desc:     "Remove a target's cache entry (or --all caches)",
+			RootOnly: true,
+			Mode:     FlagModeTargOnly,
+		},
+		{
+			Long:     "cache-info",
+			Desc:     "Show cache sizes and entries, including cached binaries",
+			RootOnly: true,
+			Mode:     FlagModeTargOnly,
+		},
 		{
 			Long:     "no-binary-cache",
 			Desc:     "Disable binary caching",
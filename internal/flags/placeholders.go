@@ -35,6 +35,10 @@ func PlaceholdersUsedByFlags(defs []Def) []Placeholder {
 	return result
 }
 
+func placeholderBusyPolicy() Placeholder {
+	return Placeholder{Name: "{queue|drop|restart}"}
+}
+
 func placeholderCmd() Placeholder {
 	return Placeholder{Name: "<cmd>"}
 }
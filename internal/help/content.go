@@ -44,12 +44,13 @@ type Example struct {
 
 // ExecutionInfo represents execution configuration for a target.
 type ExecutionInfo struct {
-	Deps          string // "build, test (serial)"
-	CachePatterns string // "*.go, **/*.mod"
-	WatchPatterns string // "*.go"
-	Timeout       string // "30s"
-	Times         string // "3"
-	Retry         string // "yes (backoff: 1s × 2.0)"
+	Deps           string // "build, test (serial)"
+	CachePatterns  string // "*.go, **/*.mod"
+	OutputPatterns string // "bin/app"
+	WatchPatterns  string // "*.go"
+	Timeout        string // "30s"
+	Times          string // "3"
+	Retry          string // "yes (backoff: 1s × 2.0)"
 }
 
 // Flag represents a command-line flag.
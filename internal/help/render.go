@@ -158,6 +158,10 @@ func (cb *ContentBuilder) renderExecutionInfo(styles Styles) string {
 		lines = append(lines, "Cache: "+info.CachePatterns)
 	}
 
+	if info.OutputPatterns != "" {
+		lines = append(lines, "Outputs: "+info.OutputPatterns)
+	}
+
 	if info.WatchPatterns != "" {
 		lines = append(lines, "Watch: "+info.WatchPatterns)
 	}
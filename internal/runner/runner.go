@@ -30,6 +30,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/toejough/targ/internal/discover"
+	internalfile "github.com/toejough/targ/internal/file"
 	"github.com/toejough/targ/internal/flags"
 	"github.com/toejough/targ/internal/help"
 )
@@ -939,6 +940,7 @@ func main() {
 	minArgsForCompletion   = 2      // Minimum args for __complete (binary + arg)
 	minCommandNameWidth    = 10     // Minimum column width for command names in help output
 	pkgNameMain            = "main" // package main check for targ files
+	projectCacheHashLen    = 8      // bytes of the project path's hash naming its cache dir
 	targLocalModule        = "targ.local"
 )
 
@@ -1410,6 +1412,39 @@ func (r *targRunner) exitWithCleanup(code int) int {
 	return code
 }
 
+// handleBinaryCacheCommand adds the binary cache to --cache-info and --cache-clean --all,
+// once the targ binary has handled the target caches.
+func (r *targRunner) handleBinaryCacheCommand() int {
+	if len(r.args) == 0 {
+		return 0
+	}
+
+	dir := targCacheDir()
+
+	switch {
+	case r.args[0] == "--cache-info":
+		err := writeBinaryCacheInfo(os.Stdout, dir)
+		if err != nil {
+			r.logError("Error reading binary cache", err)
+			return 1
+		}
+	case r.args[0] == "--cache-clean" && slices.Equal(r.args[1:], []string{"--all"}):
+		removed, err := cleanBinaryCache(dir)
+		if err != nil {
+			r.logError("Error removing binary cache", err)
+			return 1
+		}
+
+		if removed {
+			fmt.Printf("removed %s\n", dir)
+		} else if _, err := os.Stat(dir); err == nil {
+			fmt.Printf("cleaned %s, keeping files targ didn't create\n", dir)
+		}
+	}
+
+	return 0
+}
+
 func (r *targRunner) handleCreateFlag(args []string) int {
 	if ContainsHelpFlag(args) {
 		PrintCreateHelp(os.Stdout)
@@ -1842,13 +1877,21 @@ func (r *targRunner) run() int {
 		return r.exitWithCleanup(1)
 	}
 
-	// Handle multi-module cases
+	var code int
+
 	if len(moduleGroups) > 1 {
-		return r.handleMultiModule(moduleGroups, helpRequested, helpTargets)
+		// Handle multi-module cases
+		code = r.handleMultiModule(moduleGroups, helpRequested, helpTargets)
+	} else {
+		// Single module case
+		code = r.handleSingleModule(infos)
+	}
+
+	if code != 0 {
+		return code
 	}
 
-	// Single module case
-	return r.handleSingleModule(infos)
+	return r.handleBinaryCacheCommand()
 }
 
 func (r *targRunner) setupBinaryPath(importRoot, cacheKey string) (string, error) {
@@ -2366,6 +2409,36 @@ func buildTargetExpression(opts CreateOptions) (string, error) {
 	return expr.String(), nil
 }
 
+// cleanBinaryCache removes what targ stores in the binary cache dir: the bin, mod and
+// tmp directories of each project's subdirectory. Directories are only removed once
+// nothing else is left in them. It reports whether dir itself was removed.
+func cleanBinaryCache(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", dir, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !isProjectCacheDirName(entry.Name()) {
+			continue
+		}
+
+		_, err := internalfile.RemoveOwned(filepath.Join(dir, entry.Name()), func(name string) bool {
+			return name == "bin" || name == "mod" || name == "tmp"
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// Only the now empty project directories are left to remove.
+	return internalfile.RemoveOwned(dir, func(string) bool { return false })
+}
+
 // cleanupStaleModSymlinks removes stale go.mod/go.sum symlinks from before the fix.
 func cleanupStaleModSymlinks(root string) {
 	for _, name := range []string{"go.mod", "go.sum"} {
@@ -3399,6 +3472,12 @@ func isIncludableModuleFile(name string) bool {
 	return strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go")
 }
 
+// isProjectCacheDirName reports whether name is that of a directory projectCacheDir returns.
+func isProjectCacheDirName(name string) bool {
+	decoded, err := hex.DecodeString(name)
+	return err == nil && len(decoded) == projectCacheHashLen
+}
+
 func isTargRegisterCall(call *ast.CallExpr) bool {
 	selector, ok := call.Fun.(*ast.SelectorExpr)
 	if !ok {
@@ -3618,7 +3697,7 @@ func printNoTargetsCompletion(args []string) {
 // Uses a hash of the project path to isolate projects.
 func projectCacheDir(projectPath string) string {
 	hash := sha256.Sum256([]byte(projectPath))
-	return filepath.Join(targCacheDir(), hex.EncodeToString(hash[:8]))
+	return filepath.Join(targCacheDir(), hex.EncodeToString(hash[:projectCacheHashLen]))
 }
 
 // queryModuleCommands queries a module binary for its available commands.
@@ -3983,6 +4062,58 @@ func validateNoPackageMain(mt moduleTargets) error {
 	return nil
 }
 
+// writeBinaryCacheInfo writes the size of the binary cache under dir and the
+// binaries in it, one line each.
+func writeBinaryCacheInfo(w io.Writer, dir string) error {
+	var (
+		lines []string
+		size  int64
+		total int64
+	)
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == dir {
+			return filepath.SkipAll
+		}
+
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		total += info.Size()
+
+		if filepath.Base(filepath.Dir(path)) == "bin" && strings.HasPrefix(d.Name(), "targ_") {
+			size += info.Size()
+			lines = append(lines, fmt.Sprintf("  %s: %s, built %s",
+				path, internalfile.FormatSize(info.Size()), info.ModTime().Format(time.DateTime)))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	noun := "binaries"
+	if len(lines) == 1 {
+		noun = "binary"
+	}
+
+	_, _ = fmt.Fprintf(w, "binary cache %s: %d %s (%s), %s total\n",
+		dir, len(lines), noun, internalfile.FormatSize(size), internalfile.FormatSize(total))
+
+	for _, line := range lines {
+		_, _ = fmt.Fprintln(w, line)
+	}
+
+	return nil
+}
+
 func writeFallbackGoMod(root, modulePath string, dep TargDependency) error {
 	modPath := filepath.Join(root, "go.mod")
 
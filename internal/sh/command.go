@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Command is a command to run with its own working directory, environment and IO.
+// Nil Stdin, Stdout or Stderr connect the process to the null device, as with exec.Cmd.
+type Command struct {
+	Name    string
+	Args    []string
+	Dir     string
+	Env     []string // extra KEY=value variables, added to the current environment
+	Stdin   io.Reader
+	Stdout  io.Writer
+	Stderr  io.Writer
+	Cleanup *CleanupManager // kills the process on SIGINT/SIGTERM once enabled; nil skips it
+}
+
+// Run runs c in its own process group and waits for it to exit.
+// While it runs, it is registered with c.Cleanup. When ctx is cancelled, the process
+// and all its children are stopped, honoring the grace period set on ctx.
+// Failures are returned as a *CommandError.
+func (c *Command) Run(ctx context.Context) error {
+	cmd, tail := c.exec(ctx)
+	start := time.Now()
+
+	err := cmd.Start()
+	if err != nil {
+		return startError(c.Name, c.Args, err)
+	}
+
+	return c.wait(ctx, cmd, start, tail)
+}
+
+// exec returns the exec.Cmd that runs c in its own process group, along with the
+// buffer keeping the tail of its stderr.
+func (c *Command) exec(ctx context.Context) (*exec.Cmd, *tailBuffer) {
+	var tail *tailBuffer
+
+	cmd := exec.CommandContext(ctx, c.Name, c.Args...)
+	cmd.Dir = c.Dir
+	cmd.Stdin = c.Stdin
+	cmd.Stdout, cmd.Stderr, tail = tailOutput(c.Stdout, c.Stderr)
+	cmd.Cancel = nil // stopCommand stops the whole process group instead
+
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+
+	SetProcGroup(cmd)
+
+	return cmd, tail
+}
+
+// wait waits for cmd, started from c at start, to exit, stopping it if ctx is cancelled
+// first. While it runs, it is registered with c.Cleanup.
+func (c *Command) wait(ctx context.Context, cmd *exec.Cmd, start time.Time, tail *tailBuffer) error {
+	if c.Cleanup != nil {
+		c.Cleanup.RegisterProcess(cmd.Process)
+		defer c.Cleanup.UnregisterProcess(cmd.Process)
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return commandError(c.Name, c.Args, start, err, tail)
+	case <-ctx.Done():
+		stopCommand(ctx, cmd, done)
+
+		return commandError(c.Name, c.Args, start, fmt.Errorf("command cancelled: %w", ctx.Err()), tail)
+	}
+}
@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// CommandError is the error returned when a command can't be started, exits
+// non-zero, is stopped by a signal, or is cancelled.
+type CommandError struct {
+	Command  string        // the command line, quoted for display
+	Ran      bool          // false if the command couldn't be started (e.g. not found)
+	ExitCode int           // the command's exit code, or -1 if it didn't exit normally
+	Signal   string        // the signal that stopped the command, if any
+	Duration time.Duration // how long the command ran
+	Stderr   []string      // the last lines the command wrote to stderr (or, combined, to both)
+	Err      error         // the underlying error
+}
+
+func (e *CommandError) Error() string {
+	return e.Command + ": " + e.Err.Error()
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// CmdRan reports whether the command that returned err ran, even if it then failed.
+// It is false only when the command couldn't be started, e.g. because it wasn't found.
+func CmdRan(err error) bool {
+	if err == nil {
+		return true
+	}
+
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.Ran
+	}
+
+	var exitErr *exec.ExitError
+
+	return errors.As(err, &exitErr)
+}
+
+// ExitStatus returns the exit code of the command that returned err: 0 if err is nil,
+// the command's exit code if it exited with one, and 1 otherwise.
+func ExitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) && cmdErr.ExitCode > 0 {
+		return cmdErr.ExitCode
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return exitErr.ExitCode()
+	}
+
+	return 1
+}
+
+// unexported constants.
+const (
+	stderrTailLines = 10
+)
+
+// tailBuffer keeps the last stderrTailLines lines written to it.
+type tailBuffer struct {
+	mu      sync.Mutex
+	lines   []string
+	partial []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.partial = append(b.partial, p...)
+
+	for {
+		i := bytes.IndexByte(b.partial, '\n')
+		if i < 0 {
+			break
+		}
+
+		b.lines = append(b.lines, strings.TrimSuffix(string(b.partial[:i]), "\r"))
+		b.partial = b.partial[i+1:]
+	}
+
+	if len(b.lines) > stderrTailLines {
+		b.lines = append([]string(nil), b.lines[len(b.lines)-stderrTailLines:]...)
+	}
+
+	return len(p), nil
+}
+
+// tail returns the last lines written, including an unterminated last line.
+// It is nil-safe, returning nothing for a nil buffer.
+func (b *tailBuffer) tail() []string {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lines := append([]string(nil), b.lines...)
+	if len(b.partial) > 0 {
+		lines = append(lines, string(b.partial))
+	}
+
+	if len(lines) > stderrTailLines {
+		lines = lines[len(lines)-stderrTailLines:]
+	}
+
+	return lines
+}
+
+// commandError returns the CommandError for name and args, started at start,
+// finishing with err, or nil if err is nil.
+func commandError(name string, args []string, start time.Time, err error, stderr *tailBuffer) error {
+	if err == nil {
+		return nil
+	}
+
+	cmdErr := &CommandError{
+		Command:  FormatCommand(name, args),
+		Ran:      true,
+		ExitCode: -1,
+		Duration: time.Since(start),
+		Stderr:   stderr.tail(),
+		Err:      err,
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		cmdErr.ExitCode = exitErr.ExitCode()
+
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			cmdErr.Signal = status.Signal().String()
+		}
+	}
+
+	return cmdErr
+}
+
+// startError returns the CommandError for name and args failing to start with err.
+func startError(name string, args []string, err error) error {
+	return &CommandError{
+		Command:  FormatCommand(name, args),
+		ExitCode: -1,
+		Err:      fmt.Errorf("starting command: %w", err),
+	}
+}
+
+// tailOutput returns the writers to give a command as its stdout and stderr so that
+// the last lines of its stderr are kept, along with the buffer that keeps them.
+// When stdout and stderr are the same writer, both are given one shared writer that
+// also feeds the tail, so the command still gets a single pipe and its output stays in
+// the order it was written; the tail then holds the last lines of both.
+func tailOutput(stdout, stderr io.Writer) (io.Writer, io.Writer, *tailBuffer) {
+	tail := &tailBuffer{}
+	if stderr == nil {
+		return stdout, tail, tail
+	}
+
+	tailed := io.MultiWriter(stderr, tail)
+	if sameWriter(stdout, stderr) {
+		return tailed, tailed, tail
+	}
+
+	return stdout, tailed, tail
+}
+
+// sameWriter reports whether a and b are the same writer. Only pointers are compared,
+// which can't panic the way comparing, say, two func-based writers does, so writers
+// that aren't pointers are never the same.
+func sameWriter(a, b io.Writer) bool {
+	if a == nil || reflect.TypeOf(a).Kind() != reflect.Pointer {
+		return false
+	}
+
+	return a == b
+}
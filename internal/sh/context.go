@@ -5,47 +5,38 @@ import (
 	"fmt"
 	"io"
 	"os/exec"
+	"time"
 )
 
+// GracePeriod returns the grace period set on ctx with WithGracePeriod, or 0.
+func GracePeriod(ctx context.Context) time.Duration {
+	d, _ := ctx.Value(gracePeriodKey{}).(time.Duration)
+	return d
+}
+
 // OutputContext executes a command and returns combined output, with context support.
 // When ctx is cancelled, the process and all its children are killed.
+// Failures are returned as a *CommandError.
 func OutputContext(
 	ctx context.Context,
 	name string,
 	args []string,
 	stdin io.Reader,
 ) (string, error) {
-	cmd := exec.CommandContext(ctx, name, args...)
-	cmd.Stdin = stdin
-
-	// Capture combined output
 	var buf SafeBuffer
 
-	cmd.Stdout = &buf
-	cmd.Stderr = &buf
-
-	done := make(chan error, 1)
-
-	SetProcGroup(cmd)
-
-	err := cmd.Start()
-	if err != nil {
-		return "", fmt.Errorf("starting command: %w", err)
+	cmd := &Command{
+		Name:    name,
+		Args:    args,
+		Stdin:   stdin,
+		Stdout:  &buf,
+		Stderr:  &buf,
+		Cleanup: defaultCleanup,
 	}
 
-	go func() {
-		done <- cmd.Wait()
-	}()
+	err := cmd.Run(ctx)
 
-	select {
-	case err := <-done:
-		return buf.String(), err
-	case <-ctx.Done():
-		KillProcessGroup(cmd)
-		<-done
-
-		return buf.String(), fmt.Errorf("command cancelled: %w", ctx.Err())
-	}
+	return buf.String(), err
 }
 
 // RunContextV runs a command with context support, printing it first.
@@ -60,15 +51,55 @@ func RunContextV(ctx context.Context, env *ShellEnv, name string, args []string)
 }
 
 // RunContextWithIO runs a command with context support and custom IO.
+// Failures are returned as a *CommandError.
 func RunContextWithIO(ctx context.Context, env *ShellEnv, name string, args []string) error {
 	if env == nil {
 		env = DefaultShellEnv()
 	}
 
-	cmd := exec.CommandContext(ctx, name, args...)
-	cmd.Stdout = env.Stdout
-	cmd.Stderr = env.Stderr
-	cmd.Stdin = env.Stdin
+	cmd := &Command{
+		Name:    name,
+		Args:    args,
+		Env:     env.Env,
+		Stdin:   env.Stdin,
+		Stdout:  env.Stdout,
+		Stderr:  env.Stderr,
+		Cleanup: env.Cleanup,
+	}
 
-	return runWithContext(ctx, cmd)
+	return cmd.Run(ctx)
+}
+
+// WithGracePeriod returns a context whose commands, when it is cancelled, are sent
+// SIGTERM and given d to exit before their process group is killed. Without a grace
+// period, cancelled commands are killed straight away.
+func WithGracePeriod(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, gracePeriodKey{}, d)
+}
+
+type gracePeriodKey struct{}
+
+// stopCommand stops cmd, whose context was cancelled, and waits for Wait's result on done.
+// Any processes left in its group once it exits are killed.
+func stopCommand(ctx context.Context, cmd *exec.Cmd, done <-chan error) {
+	grace := GracePeriod(ctx)
+	if grace <= 0 {
+		KillProcessGroup(cmd)
+		<-done
+
+		return
+	}
+
+	TerminateProcessGroup(cmd)
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		KillProcessGroup(cmd)
+	case <-timer.C:
+		KillProcessGroup(cmd)
+		<-done
+	}
 }
@@ -3,8 +3,6 @@
 package internal
 
 import (
-	"context"
-	"fmt"
 	"os/exec"
 	"syscall"
 )
@@ -22,29 +20,9 @@ func SetProcGroup(cmd *exec.Cmd) {
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 }
 
-// runWithContext runs a command with context cancellation support.
-// On Unix, it uses process groups to kill the entire process tree.
-func runWithContext(ctx context.Context, cmd *exec.Cmd) error {
-	SetProcGroup(cmd)
-
-	err := cmd.Start()
-	if err != nil {
-		return fmt.Errorf("starting command: %w", err)
-	}
-
-	done := make(chan error, 1)
-
-	go func() {
-		done <- cmd.Wait()
-	}()
-
-	select {
-	case err := <-done:
-		return err
-	case <-ctx.Done():
-		KillProcessGroup(cmd)
-		<-done
-
-		return fmt.Errorf("command cancelled: %w", ctx.Err())
+// TerminateProcessGroup asks the process and all its children to exit.
+func TerminateProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
 	}
 }
@@ -3,7 +3,6 @@
 package internal
 
 import (
-	"context"
 	"os/exec"
 )
 
@@ -22,26 +21,7 @@ func SetProcGroup(cmd *exec.Cmd) {
 	// Job Objects would be needed for full process tree management.
 }
 
-// runWithContext runs a command with context cancellation support.
-// On Windows, this uses basic process termination.
-// Note: Child processes may not be terminated - for full process tree
-// cleanup, consider using Job Objects in a future enhancement.
-func runWithContext(ctx context.Context, cmd *exec.Cmd) error {
-	if err := cmd.Start(); err != nil {
-		return err
-	}
-
-	done := make(chan error, 1)
-	go func() {
-		done <- cmd.Wait()
-	}()
-
-	select {
-	case err := <-done:
-		return err
-	case <-ctx.Done():
-		KillProcessGroup(cmd)
-		<-done
-		return ctx.Err()
-	}
+// TerminateProcessGroup kills the process on Windows, which has no SIGTERM.
+func TerminateProcessGroup(cmd *exec.Cmd) {
+	KillProcessGroup(cmd)
 }
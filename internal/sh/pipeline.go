@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Pipeline is a series of commands run together, each reading the previous one's
+// stdout, as with | in a shell.
+type Pipeline []*Command
+
+// Run runs the commands in p together and waits for them all to exit. Each runs in its
+// own process group, with its stdout connected to the next command's stdin: the first
+// command's Stdin and the last one's Stdout are used as given, the rest are replaced
+// by the pipes between them. When ctx is cancelled, every command is stopped as by
+// Command.Run. As with a shell's pipefail option, it returns the error of the last
+// command to fail, so a failure anywhere fails the pipeline.
+func (p Pipeline) Run(ctx context.Context) error {
+	if len(p) == 0 {
+		return errEmptyPipeline
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stages := make([]*pipelineStage, len(p))
+	for i, c := range p {
+		cmd, tail := c.exec(ctx)
+		stages[i] = &pipelineStage{command: c, tail: tail, cmd: cmd}
+	}
+
+	pipes, err := connectStages(stages)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+
+	start := time.Now()
+
+	for _, stage := range stages {
+		err := stage.cmd.Start()
+		if err != nil {
+			closeFiles(pipes)
+			cancel()
+			wg.Wait()
+
+			return startError(stage.command.Name, stage.command.Args, err)
+		}
+
+		wg.Go(func() {
+			stage.err = stage.command.wait(ctx, stage.cmd, start, stage.tail)
+		})
+	}
+
+	// The commands have their own copies of the pipes now. Closing ours lets each
+	// command see end of file once the one before it exits.
+	closeFiles(pipes)
+	wg.Wait()
+
+	for i := len(stages) - 1; i >= 0; i-- {
+		if stages[i].err != nil {
+			return stages[i].err
+		}
+	}
+
+	return nil
+}
+
+// unexported variables.
+var (
+	errEmptyPipeline = errors.New("pipeline has no commands")
+)
+
+// pipelineStage is one command of a running pipeline.
+type pipelineStage struct {
+	command *Command
+	cmd     *exec.Cmd
+	tail    *tailBuffer
+	err     error
+}
+
+// closeFiles closes files, ignoring errors.
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
+
+// connectStages connects each stage's stdout to the next stage's stdin with a pipe,
+// returning both ends of every pipe for the caller to close once the stages have started.
+func connectStages(stages []*pipelineStage) ([]*os.File, error) {
+	var pipes []*os.File
+
+	for i := 1; i < len(stages); i++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			closeFiles(pipes)
+			return nil, fmt.Errorf("creating pipe: %w", err)
+		}
+
+		stages[i-1].cmd.Stdout = w
+		stages[i].cmd.Stdin = r
+		pipes = append(pipes, r, w)
+	}
+
+	return pipes, nil
+}
@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // SafeBuffer is a thread-safe buffer for concurrent writes.
@@ -45,6 +46,7 @@ type ShellEnv struct {
 	Stdout      io.Writer
 	Stderr      io.Writer
 	Cleanup     *CleanupManager
+	Env         []string // extra KEY=value variables for commands run by RunContextWithIO
 }
 
 // DefaultShellEnv returns the standard OS implementations with the default cleanup manager.
@@ -95,6 +97,7 @@ func IsWindowsOS() bool {
 }
 
 // Output executes a command and returns combined output.
+// Failures are returned as a *CommandError.
 func Output(env *ShellEnv, name string, args ...string) (string, error) {
 	if env == nil {
 		env = DefaultShellEnv()
@@ -104,25 +107,25 @@ func Output(env *ShellEnv, name string, args ...string) (string, error) {
 	cmd.Stdin = env.Stdin
 	SetProcGroup(cmd)
 
-	var buf SafeBuffer
+	var (
+		buf  SafeBuffer
+		tail *tailBuffer
+	)
 
-	cmd.Stdout = &buf
-	cmd.Stderr = &buf
+	cmd.Stdout, cmd.Stderr, tail = tailOutput(&buf, &buf)
+
+	start := time.Now()
 
 	err := cmd.Start()
 	if err != nil {
-		return "", fmt.Errorf("starting command: %w", err)
+		return "", startError(name, args, err)
 	}
 
 	env.Cleanup.RegisterProcess(cmd.Process)
 	err = cmd.Wait()
 	env.Cleanup.UnregisterProcess(cmd.Process)
 
-	if err != nil {
-		return buf.String(), fmt.Errorf("waiting for command: %w", err)
-	}
-
-	return buf.String(), nil
+	return buf.String(), commandError(name, args, start, err, tail)
 }
 
 // QuoteArg quotes an argument for display (exported for testing).
@@ -139,31 +142,32 @@ func QuoteArg(value string) string {
 }
 
 // Run executes a command streaming stdout/stderr.
+// Failures are returned as a *CommandError.
 func Run(env *ShellEnv, name string, args ...string) error {
 	if env == nil {
 		env = DefaultShellEnv()
 	}
 
 	cmd := env.ExecCommand(name, args...)
-	cmd.Stdout = env.Stdout
-	cmd.Stderr = env.Stderr
 	cmd.Stdin = env.Stdin
 	SetProcGroup(cmd)
 
+	var tail *tailBuffer
+
+	cmd.Stdout, cmd.Stderr, tail = tailOutput(env.Stdout, env.Stderr)
+
+	start := time.Now()
+
 	err := cmd.Start()
 	if err != nil {
-		return fmt.Errorf("starting command: %w", err)
+		return startError(name, args, err)
 	}
 
 	env.Cleanup.RegisterProcess(cmd.Process)
 	err = cmd.Wait()
 	env.Cleanup.UnregisterProcess(cmd.Process)
 
-	if err != nil {
-		return fmt.Errorf("waiting for command: %w", err)
-	}
-
-	return nil
+	return commandError(name, args, start, err, tail)
 }
 
 // RunV executes a command and prints it first.
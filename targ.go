@@ -3,6 +3,7 @@ package targ
 
 import (
 	"context"
+	"io/fs"
 	"os"
 
 	"github.com/toejough/targ/internal/core"
@@ -31,24 +32,68 @@ const (
 	Errored = core.Errored
 	// Fail indicates a target execution failed.
 	Fail = core.Fail
+	// FromDeps, passed to .Watch(), also watches the .Cache() and .Watch() patterns of
+	// everything the target depends on. A change re-runs only the affected dependencies.
+	// Example: targ.Targ().Name("ci").Deps(build, lint, test).Watch(targ.FromDeps)
+	FromDeps = "__targ_watch_deps__"
 	// Pass indicates a target executed successfully.
 	Pass              = core.Pass
 	TagKindFlag       = core.TagKindFlag
 	TagKindPositional = core.TagKindPositional
 	TagKindUnknown    = core.TagKindUnknown
+	// WatchBackendAuto uses native file events where supported, falling back to polling.
+	WatchBackendAuto = internalfile.WatchBackendAuto
+	// WatchBackendNative uses native file events (inotify on Linux) and fails elsewhere.
+	WatchBackendNative = internalfile.WatchBackendNative
+	// WatchBackendPoll re-globs and stats the watched files every Interval.
+	WatchBackendPoll = internalfile.WatchBackendPoll
+	// WatchDrop discards file changes made while the watch callback is running.
+	WatchDrop = internalfile.WatchDrop
+	// WatchQueue reports file changes made during a run once it returns (the default).
+	WatchQueue = internalfile.WatchQueue
+	// WatchRestart cancels the running callback's context and runs it again with the new changes.
+	WatchRestart = internalfile.WatchRestart
 )
 
 // Exported variables.
 var (
-	ErrEmptyDest       = internalfile.ErrEmptyDest
-	ErrNoInputPatterns = internalfile.ErrNoInputPatterns
-	ErrNoPatterns      = internalfile.ErrNoPatterns
-	ErrUnmatchedBrace  = internalfile.ErrUnmatchedBrace
+	ErrCacheCorrupt            = internalfile.ErrCacheCorrupt
+	ErrCacheMiss               = internalfile.ErrCacheMiss
+	ErrCopyIntoSelf            = internalfile.ErrCopyIntoSelf
+	ErrEmptyDest               = internalfile.ErrEmptyDest
+	ErrLockDeadlock            = core.ErrLockDeadlock
+	ErrNoInputPatterns         = internalfile.ErrNoInputPatterns
+	ErrNoMatches               = internalfile.ErrNoMatches
+	ErrNoOutputPatterns        = internalfile.ErrNoOutputPatterns
+	ErrNoPatterns              = internalfile.ErrNoPatterns
+	ErrUnmatchedBrace          = internalfile.ErrUnmatchedBrace
+	ErrUnsupportedBackend      = internalfile.ErrUnsupportedBackend
+	ErrWatchBackendUnsupported = internalfile.ErrWatchBackendUnsupported
 )
 
+// CacheBackend stores artifact cache data outside the local cache directory,
+// so results can be shared between machines. Get must return an error wrapping
+// ErrCacheMiss for keys it doesn't have.
+type CacheBackend = core.CacheBackend
+
 // ChangeSet holds the files that changed between watch polls.
 type ChangeSet = internalfile.ChangeSet
 
+// Command is an external command built with Cmd.
+type Command = core.Command
+
+// CommandError is the error returned by Cmd, Run, Output and their variants when a
+// command can't be started, exits non-zero, is stopped by a signal, or is cancelled.
+// It carries the command line, exit code, signal, duration and the last lines of stderr.
+type CommandError = core.CommandError
+
+// CycleError is the error returned when a target depends on itself, directly or
+// through other targets. Its Path lists the targets in the cycle.
+type CycleError = core.CycleError
+
+// CycleStep is one target in a dependency cycle: its name and where it was created.
+type CycleStep = core.CycleStep
+
 // DepGroup is the exported view of a dependency group.
 type DepGroup = core.DepGroup
 
@@ -70,9 +115,16 @@ type ExitError = core.ExitError
 // Interleaved wraps a value to be parsed from interleaved positional arguments.
 type Interleaved[T any] = core.Interleaved[T]
 
+// MatchOptions configures how Match patterns are matched, e.g. to leave out what
+// .gitignore and .targignore files ignore.
+type MatchOptions = internalfile.MatchOptions
+
 // MultiError wraps multiple target failures from a collect-all-errors parallel run.
 type MultiError = core.MultiError
 
+// Pipeline is a series of commands built with Pipe, each reading the previous one's stdout.
+type Pipeline = core.Pipeline
+
 // Result represents the outcome status of a parallel target execution.
 type Result = core.Result
 
@@ -94,6 +146,12 @@ type Target = core.Target
 // TargetGroup represents a named collection of targets that can be run together.
 type TargetGroup = core.TargetGroup
 
+// WatchBackend selects how Watch detects changes.
+type WatchBackend = internalfile.WatchBackend
+
+// WatchBusyPolicy decides what happens to file changes made while a watch callback is running.
+type WatchBusyPolicy = internalfile.WatchBusyPolicy
+
 // WatchOptions configures file watching behavior.
 type WatchOptions = internalfile.WatchOptions
 
@@ -107,19 +165,68 @@ func AppendBuiltinExamples(custom ...Example) []Example {
 // BuiltinExamples returns the default targ examples (completion setup, chaining).
 func BuiltinExamples() []Example { return core.BuiltinExamples() }
 
+// Changes returns the file changes that triggered the current run of a watched target.
+// It is empty on the watch's first run and outside watch mode. Shell command targets
+// get the same files, one per line, in $TARG_CHANGED_FILES.
+func Changes(ctx context.Context) ChangeSet {
+	return core.Changes(ctx)
+}
+
 // CheckCleanWorkTree verifies the git working tree has no uncommitted changes.
 func CheckCleanWorkTree(ctx context.Context) error {
 	return core.CheckCleanWorkTree(ctx)
 }
 
 // Checksum reports whether the content hash of inputs differs from the stored hash at dest.
-// When the hash changes, the new hash is written to dest.
+// When the hash changes, the new hash is written to dest. File hashes are remembered
+// in dest + ".stat", so files whose size and modification time haven't changed aren't re-read.
 func Checksum(inputs []string, dest string) (bool, error) {
+	return ChecksumWithOptions(inputs, dest, MatchOptions{})
+}
+
+// ChecksumWithOptions is Checksum with inputs matched as by MatchWithOptions.
+func ChecksumWithOptions(inputs []string, dest string, opts MatchOptions) (bool, error) {
 	return internalfile.Checksum(inputs, dest, func(patterns []string) ([]string, error) {
-		return Match(patterns...)
+		return MatchWithOptions(patterns, opts)
 	}, nil)
 }
 
+// Cmd returns a command that runs name with args, configured by chaining:
+//
+//	err := targ.Cmd("go", "test", "./...").Dir("sub").Env("CGO_ENABLED=0").Verbose().Run(ctx)
+//	out, err := targ.Cmd("git", "rev-parse", "HEAD").Output(ctx)
+//	files, err := targ.Cmd("git", "ls-files").Lines(ctx)
+//
+// Commands run in their own process group, which is killed when ctx is cancelled.
+// In parallel mode, their output is routed through the parallel printer.
+func Cmd(name string, args ...string) *Command {
+	return core.Cmd(name, args...)
+}
+
+// CmdRan reports whether the command that returned err ran, even if it then failed.
+// It is false only when the command couldn't be started, e.g. because it wasn't found.
+func CmdRan(err error) bool {
+	return core.CmdRan(err)
+}
+
+// Copy copies src to dst, replacing files already at dst. dst is the path of the copy,
+// not a directory to copy into. Directories are copied recursively, symlinks are
+// recreated, modes are preserved, and each file is written atomically.
+func Copy(dst, src string) error {
+	return core.Copy(dst, src)
+}
+
+// CopyGlob copies the files matching patterns (see Match) into dir, each at its path
+// relative to the part of its pattern before the first wildcard:
+//
+//	err := targ.CopyGlob("dist", "assets/**/*.png", "!assets/raw/**")
+//
+// copies assets/img/logo.png to dist/img/logo.png. It fails with ErrNoMatches if
+// nothing matches.
+func CopyGlob(dir string, patterns ...string) error {
+	return core.CopyGlob(dir, patterns...)
+}
+
 // DeregisterFrom removes all targets registered by the named package.
 // Must be called from init() before targ executes.
 //
@@ -181,6 +288,12 @@ func ExecuteWithOptions(
 	return core.ExecuteWithOptions(args, opts, targets...)
 }
 
+// ExitStatus returns the exit code for err: 0 if err is nil, the exit code of the
+// failed command it carries (see CommandError), and 1 otherwise.
+func ExitStatus(err error) int {
+	return core.ExitStatus(err)
+}
+
 // Group creates a named group containing the given members.
 // Members can be *Target or *Group (for nested hierarchies).
 //
@@ -211,10 +324,67 @@ func Main(targets ...any) {
 // --- File Utilities ---
 
 // Match expands one or more patterns using fish-style globs (including ** and {a,b}).
+// Patterns starting with ! exclude matching paths, and everything under matching
+// directories, from the results:
+//
+//	targ.Match("**/*.go", "!vendor", "!**/zz_*.go")
 func Match(patterns ...string) ([]string, error) {
 	return internalfile.Match(patterns...)
 }
 
+// MatchWithOptions expands patterns as Match does, with opts applied:
+//
+//	targ.MatchWithOptions([]string{"**/*.go"}, targ.MatchOptions{RespectIgnoreFiles: true})
+func MatchWithOptions(patterns []string, opts MatchOptions) ([]string, error) {
+	return internalfile.MatchWithOptions(patterns, opts)
+}
+
+// Mkdir creates path and any missing parents. It is not an error for path to exist.
+func Mkdir(path string) error {
+	return core.Mkdir(path)
+}
+
+// Move moves src to dst. When they are on different file systems, src is copied
+// and then removed.
+func Move(dst, src string) error {
+	return core.Move(dst, src)
+}
+
+// NewDirCacheBackend returns a CacheBackend that stores entries in a shared directory,
+// such as an NFS mount.
+func NewDirCacheBackend(dir string) CacheBackend {
+	return internalfile.DirBackend{Dir: dir}
+}
+
+// NewHTTPCacheBackend returns a CacheBackend that GETs and PUTs entries relative to baseURL.
+// A 404 response is a cache miss.
+func NewHTTPCacheBackend(baseURL string) CacheBackend {
+	return internalfile.HTTPBackend{URL: baseURL}
+}
+
+// Newer reports whether outputs are missing or older than the newest input.
+// Patterns use the same fish-style globs as Match.
+func Newer(inputs, outputs []string) (bool, error) {
+	return NewerWithOptions(inputs, outputs, MatchOptions{})
+}
+
+// NewerWithOptions is Newer with inputs matched as by MatchWithOptions. Outputs are
+// matched as by Match, since they are often what ignore files ignore.
+func NewerWithOptions(inputs, outputs []string, opts MatchOptions) (bool, error) {
+	return internalfile.Newer(inputs, outputs, opts, internalfile.MatchWithOptions, nil)
+}
+
+// OutCmd returns a function that runs name with args, followed by the args it's
+// called with, and returns its stdout:
+//
+//	gitOut := targ.OutCmd("git")
+//	head, err := gitOut(ctx, "rev-parse", "HEAD")
+//
+// To also bind a directory or environment, use Cmd(...).OutputFunc().
+func OutCmd(name string, args ...string) func(ctx context.Context, args ...string) (string, error) {
+	return core.OutCmd(name, args...)
+}
+
 // Output executes a command and returns combined output.
 func Output(name string, args ...string) (string, error) {
 	return internalsh.Output(nil, name, args...)
@@ -226,6 +396,12 @@ func OutputContext(ctx context.Context, name string, args ...string) (string, er
 	return internalsh.OutputContext(ctx, name, args, os.Stdin)
 }
 
+// Pipe returns a pipeline that runs cmds together, each reading the previous one's
+// stdout, as with | in a shell, but without one. Cancelling the context kills every command.
+func Pipe(cmds ...*Command) *Pipeline {
+	return core.Pipe(cmds...)
+}
+
 // PrependBuiltinExamples adds built-in examples before custom examples.
 func PrependBuiltinExamples(custom ...Example) []Example {
 	return core.PrependBuiltinExamples(custom...)
@@ -250,11 +426,28 @@ func Register(targets ...any) {
 	core.RegisterTargetWithSkip(core.CallerSkipPublicAPI, targets...)
 }
 
+// Rm removes path and everything under it, including read-only files, like rm -rf
+// on every platform. It is not an error for path not to exist.
+func Rm(path string) error {
+	return core.Rm(path)
+}
+
 // Run executes a command streaming stdout/stderr.
 func Run(name string, args ...string) error {
 	return internalsh.Run(nil, name, args...)
 }
 
+// RunCmd returns a function that runs name with args, followed by the args it's called with:
+//
+//	compose := targ.RunCmd("docker", "compose", "-f", composeFile)
+//	err := compose(ctx, "up", "-d")
+//
+// To also bind a directory or environment, use Cmd(...).RunFunc(). Like RunContext,
+// the command is killed when ctx is cancelled, and its output is prefixed in parallel mode.
+func RunCmd(name string, args ...string) func(ctx context.Context, args ...string) error {
+	return core.RunCmd(name, args...)
+}
+
 // RunContext executes a command with context support.
 // When ctx is cancelled, the process and all its children are killed.
 // In parallel mode, stdout/stderr are routed through the parallel printer.
@@ -295,19 +488,41 @@ func Targ(fn ...any) *Target {
 	return core.Targ(fn...)
 }
 
-// Watch polls patterns for changes and invokes callback with any detected changes.
+// Watch watches patterns for changes and invokes callback with any detected changes.
+// By default it uses native file events where supported (inotify on Linux) and
+// polls every opts.Interval elsewhere; opts.Backend selects one explicitly.
 func Watch(
 	ctx context.Context,
 	patterns []string,
 	opts WatchOptions,
 	callback func(ChangeSet) error,
 ) error {
-	return internalfile.Watch(ctx, patterns, opts, callback, func(p []string) ([]string, error) {
-		return Match(p...)
-	}, nil)
+	return internalfile.Watch(ctx, patterns, opts, callback, internalfile.MatchWithOptions, nil)
+}
+
+// WatchContext is Watch with a callback that takes a context. The context is cancelled
+// when the watch ends and, with opts.OnBusy set to WatchRestart, when newer changes
+// interrupt the run. Commands run with the context are then sent SIGTERM, and killed
+// if they are still running after opts.GracePeriod.
+func WatchContext(
+	ctx context.Context,
+	patterns []string,
+	opts WatchOptions,
+	callback func(context.Context, ChangeSet) error,
+) error {
+	return internalfile.WatchContext(ctx, patterns, opts, func(ctx context.Context, changes ChangeSet) error {
+		return callback(internalsh.WithGracePeriod(ctx, opts.Grace()), changes)
+	}, internalfile.MatchWithOptions, nil)
 }
 
 // WithExeSuffix appends the OS-specific executable suffix if missing.
 func WithExeSuffix(name string) string {
 	return internalsh.WithExeSuffix(nil, name)
 }
+
+// WriteFile writes data to path with perm atomically: via a temporary file renamed
+// into place, so readers never see a partial file. Missing parent directories are
+// created.
+func WriteFile(path string, data []byte, perm fs.FileMode) error {
+	return core.WriteFile(path, data, perm)
+}
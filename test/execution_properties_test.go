@@ -11,6 +11,11 @@ package targ_test
 import (
 	"context"
 	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -411,6 +416,227 @@ func TestProperty_Execution(t *testing.T) {
 		g.Expect(executed).To(BeFalse(), "group 2 should not run if group 1 fails")
 	})
 
+	t.Run("SharedDependencyRunsOnceInSerialGraph", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var builds atomic.Int32
+
+		build := targ.Targ(func() { builds.Add(1) }).Name("build")
+		lint := targ.Targ(func() {}).Name("lint").Deps(build)
+		test := targ.Targ(func() {}).Name("test").Deps(build)
+		ci := targ.Targ(func() {}).Name("ci").Deps(lint, test)
+
+		err := ci.Run(context.Background())
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(builds.Load()).To(Equal(int32(1)))
+	})
+
+	t.Run("SharedDependencyRunsOnceInParallelGroup", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var builds atomic.Int32
+
+		build := targ.Targ(func() {
+			builds.Add(1)
+			time.Sleep(20 * time.Millisecond)
+		}).Name("build")
+		lint := targ.Targ(func() {}).Name("lint").Deps(build)
+		test := targ.Targ(func() {}).Name("test").Deps(build)
+		vet := targ.Targ(func() {}).Name("vet").Deps(build)
+		ci := targ.Targ(func() {}).Name("ci").Deps(lint, test, vet, targ.DepModeParallel)
+
+		err := ci.Run(context.Background())
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(builds.Load()).To(Equal(int32(1)))
+	})
+
+	t.Run("SharedDependencyErrorReachesEveryDependent", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var (
+			builds   atomic.Int32
+			mainRuns atomic.Int32
+		)
+
+		build := targ.Targ(func() error {
+			builds.Add(1)
+			return errors.New("build broke")
+		}).Name("build")
+		lint := targ.Targ(func() { mainRuns.Add(1) }).Name("lint").Deps(build)
+		test := targ.Targ(func() { mainRuns.Add(1) }).Name("test").Deps(build)
+		ci := targ.Targ(func() {}).Name("ci").Deps(lint, test, targ.DepModeParallel, targ.CollectAllErrors)
+
+		err := ci.Run(context.Background())
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("lint: build broke"))
+		g.Expect(err.Error()).To(ContainSubstring("test: build broke"))
+		g.Expect(builds.Load()).To(Equal(int32(1)))
+		g.Expect(mainRuns.Load()).To(Equal(int32(0)))
+	})
+
+	t.Run("SharedDependencyRunsOncePerCLIInvocation", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var builds atomic.Int32
+
+		build := targ.Targ(func() { builds.Add(1) }).Name("build")
+		lint := targ.Targ(func() {}).Name("lint").Deps(build)
+		test := targ.Targ(func() {}).Name("test").Deps(build)
+
+		_, err := targ.Execute([]string{"app", "lint", "test"}, lint, test)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(builds.Load()).To(Equal(int32(1)))
+
+		_, err = targ.Execute([]string{"app", "lint"}, lint, test)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(builds.Load()).To(Equal(int32(2)), "a new invocation runs deps again")
+	})
+
+	t.Run("TargetRunsOnceAsRootAndDependency", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var builds atomic.Int32
+
+		build := targ.Targ(func() { builds.Add(1) }).Name("build")
+		test := targ.Targ(func() {}).Name("test").Deps(build)
+
+		_, err := targ.Execute([]string{"app", "build", "test"}, build, test)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(builds.Load()).To(Equal(int32(1)))
+
+		_, err = targ.Execute([]string{"app", "test", "build"}, build, test)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(builds.Load()).To(Equal(int32(2)))
+	})
+
+	t.Run("ShellAndDepsOnlyRootsRunOnce", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		log := filepath.Join(t.TempDir(), "log")
+		shl := targ.Targ("echo shl >> " + log).Name("shl")
+		ci := targ.Targ().Name("ci").Deps(shl)
+		all := targ.Targ().Name("all").Deps(ci, shl)
+
+		for _, args := range [][]string{
+			{"app", "shl", "ci"},
+			{"app", "ci", "shl"},
+			{"app", "ci", "all"},
+		} {
+			g.Expect(os.WriteFile(log, nil, 0o600)).To(Succeed())
+
+			result, err := targ.Execute(args, shl, ci, all)
+			g.Expect(err).NotTo(HaveOccurred(), result.Output)
+			g.Expect(os.ReadFile(log)).To(Equal([]byte("shl\n")), "%v", args)
+		}
+	})
+
+	t.Run("TargetRunsOncePerArgs", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var greeted []string
+
+		greet := targ.Targ(func(name string) { greeted = append(greeted, name) }).Name("greet")
+		all := targ.Targ(func(ctx context.Context) error {
+			for _, name := range []string{"a", "b", "a"} {
+				err := greet.Run(ctx, name)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}).Name("all")
+
+		g.Expect(all.Run(context.Background())).To(Succeed())
+		g.Expect(greeted).To(Equal([]string{"a", "b"}))
+	})
+
+	t.Run("DependencyCyclesFailBeforeAnythingRuns", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var runs atomic.Int32
+
+		a := targ.Targ(func() { runs.Add(1) }).Name("a")
+		b := targ.Targ(func() { runs.Add(1) }).Name("b").Deps(a)
+		a.Deps(b)
+
+		ok := targ.Targ(func() { runs.Add(1) }).Name("ok")
+
+		result, err := targ.Execute([]string{"app", "ok", "a"}, ok, a, b)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(result.Output).To(ContainSubstring(`dependency cycle: "a" depends on itself`))
+
+		var cycleErr *targ.CycleError
+
+		g.Expect(errors.As(err, &cycleErr)).To(BeTrue())
+		g.Expect(cycleErr.Path[0].Source).To(ContainSubstring("execution_properties_test.go:"))
+
+		err = a.Run(context.Background())
+		g.Expect(errors.As(err, &cycleErr)).To(BeTrue())
+
+		// Run from inside another target's run, in the same invocation.
+		outer := targ.Targ(func(ctx context.Context) error { return a.Run(ctx) }).Name("outer")
+		err = outer.Run(context.Background())
+		g.Expect(errors.As(err, &cycleErr)).To(BeTrue())
+		g.Expect(runs.Load()).To(BeZero())
+	})
+
+	t.Run("PanickingTargetReleasesWaiters", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		build := targ.Targ(func() { panic("boom") }).Name("build")
+
+		var second error
+
+		outer := targ.Targ(func(ctx context.Context) error {
+			func() {
+				defer func() { _ = recover() }()
+
+				_ = build.Run(ctx)
+			}()
+
+			second = build.Run(ctx)
+
+			return nil
+		}).Name("outer")
+
+		g.Expect(outer.Run(context.Background())).To(Succeed())
+		g.Expect(second).To(MatchError(ContainSubstring("build: boom")))
+	})
+
+	t.Run("ExitingTargetReleasesWaiters", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		build := targ.Targ(func() { runtime.Goexit() }).Name("build")
+
+		var second error
+
+		outer := targ.Targ(func(ctx context.Context) error {
+			var wg sync.WaitGroup
+
+			wg.Go(func() { _ = build.Run(ctx) })
+			wg.Wait()
+
+			second = build.Run(ctx)
+
+			return nil
+		}).Name("outer")
+
+		g.Expect(outer.Run(context.Background())).To(Succeed())
+		g.Expect(second).To(MatchError(ContainSubstring("runtime.Goexit")))
+		g.Expect(second).To(MatchError(HaveSuffix(": build")))
+	})
+
 	t.Run("TimeoutEnforcesLimit", func(t *testing.T) {
 		t.Parallel()
 		g := NewWithT(t)
@@ -1200,6 +1426,400 @@ func TestProperty_Execution(t *testing.T) {
 		g.Expect(executed).To(BeTrue())
 	})
 
+	t.Run("WatchRestartCancelsRunningTarget", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "input.txt")
+		g.Expect(os.WriteFile(input, nil, 0o600)).To(Succeed())
+
+		started := make(chan struct{}, 10)
+		cancelled := make(chan struct{}, 10)
+
+		runs := 0
+		target := targ.Targ(func(ctx context.Context) error {
+			runs++
+			if runs == 1 {
+				return nil
+			}
+
+			started <- struct{}{}
+			<-ctx.Done()
+			cancelled <- struct{}{}
+
+			return ctx.Err()
+		}).Watch(filepath.Join(dir, "*.txt"), targ.WatchOptions{
+			Interval: 10 * time.Millisecond,
+			Backend:  targ.WatchBackendPoll,
+			OnBusy:   targ.WatchRestart,
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+
+		go func() { done <- target.Run(ctx) }()
+
+		touches := 0
+		touch := func() {
+			touches++
+			later := time.Now().Add(time.Duration(touches) * time.Second)
+			_ = os.Chtimes(input, later, later)
+		}
+
+		g.Eventually(func() bool {
+			touch()
+			select {
+			case <-started:
+				return true
+			case <-time.After(100 * time.Millisecond):
+				return false
+			}
+		}).WithTimeout(5 * time.Second).Should(BeTrue())
+
+		writeFileAt(t, filepath.Join(dir, "other.txt"), time.Now())
+		g.Eventually(cancelled).WithTimeout(5 * time.Second).Should(Receive())
+		g.Eventually(started).WithTimeout(5*time.Second).Should(Receive(), "restarted after cancel")
+
+		cancel()
+		g.Eventually(done).WithTimeout(5 * time.Second).Should(Receive(MatchError(ContainSubstring("watch"))))
+	})
+
+	t.Run("WatchRestartStopsLongRunningCommand", func(t *testing.T) {
+		t.Parallel()
+
+		for _, tc := range []struct {
+			name, trap, grace string
+			log               string
+		}{
+			{"Graceful", "echo term >> LOG; exit 0", "5s", "start\nterm\nstart\n"},
+			{"KilledAfterGrace", "", "100ms", "start\nstart\n"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+				g := NewWithT(t)
+
+				dir := t.TempDir()
+				input := filepath.Join(dir, "input.txt")
+				log := filepath.Join(dir, "log")
+				writeFileAt(t, input, time.Now().Add(-time.Hour))
+
+				// A dev server: never returns on its own.
+				script := strings.ReplaceAll(
+					"echo start >> LOG; trap '"+tc.trap+"' TERM; while true; do sleep 0.05; done", "LOG", log)
+				serve := targ.Targ(script).Name("serve")
+
+				ctx, cancel := context.WithCancel(context.Background())
+				done := make(chan error, 1)
+
+				go func() {
+					_, err := targ.ExecuteWithOptions([]string{
+						"app", "--watch", filepath.Join(dir, "*.txt"), "--watch-on-busy", "restart",
+						"--watch-grace", tc.grace, "serve",
+					}, targ.RunOptions{Context: ctx}, serve)
+					done <- err
+				}()
+
+				readLog := func() string {
+					data, _ := os.ReadFile(log)
+					return string(data)
+				}
+
+				g.Eventually(readLog).WithTimeout(5 * time.Second).Should(Equal("start\n"))
+				time.Sleep(300 * time.Millisecond) // let the watcher take its first snapshot
+
+				writeFileAt(t, input, time.Now())
+				g.Eventually(readLog).WithTimeout(5 * time.Second).Should(Equal(tc.log))
+
+				cancel()
+				g.Eventually(done).WithTimeout(10 * time.Second).Should(Receive(HaveOccurred()))
+			})
+		}
+	})
+
+	t.Run("WatchedFunctionTargetSeesChanges", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "input.txt")
+		writeFileAt(t, input, time.Now().Add(-time.Hour))
+
+		seen := make(chan targ.ChangeSet, 10)
+		target := targ.Targ(func(ctx context.Context) {
+			seen <- targ.Changes(ctx)
+		}).Watch(filepath.Join(dir, "*.txt"), targ.WatchOptions{
+			Interval: 10 * time.Millisecond,
+			Backend:  targ.WatchBackendPoll,
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+
+		go func() { done <- target.Run(ctx) }()
+
+		var changes targ.ChangeSet
+
+		g.Eventually(seen).WithTimeout(5 * time.Second).Should(Receive(&changes))
+		g.Expect(changes.Files()).To(BeEmpty(), "the first run has no changes")
+		time.Sleep(100 * time.Millisecond) // let the watcher take its first snapshot
+
+		writeFileAt(t, input, time.Now())
+		g.Eventually(seen).WithTimeout(5 * time.Second).Should(Receive(Equal(targ.ChangeSet{
+			Added: []string{}, Removed: []string{}, Modified: []string{input},
+		})))
+
+		cancel()
+		g.Eventually(done).WithTimeout(5 * time.Second).Should(Receive())
+		g.Expect(targ.Changes(context.Background()).Files()).To(BeEmpty(), "empty outside watch mode")
+	})
+
+	t.Run("WatchedShellTargetGetsChangedFilesEnv", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		first := filepath.Join(dir, "a.txt")
+		second := filepath.Join(dir, "b.txt")
+		log := filepath.Join(dir, "log")
+		writeFileAt(t, first, time.Now().Add(-time.Hour))
+
+		check := targ.Targ(`echo "[$TARG_CHANGED_FILES]" >> ` + log).Name("check")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+
+		go func() {
+			_, err := targ.ExecuteWithOptions([]string{
+				"app", "--watch", filepath.Join(dir, "*.txt"), "--watch-debounce", "200ms", "check",
+			}, targ.RunOptions{Context: ctx}, check)
+			done <- err
+		}()
+
+		readLog := func() string {
+			data, _ := os.ReadFile(log)
+			return string(data)
+		}
+
+		g.Eventually(readLog).WithTimeout(5 * time.Second).Should(Equal("[]\n"))
+		time.Sleep(300 * time.Millisecond) // let the watcher take its first snapshot
+
+		writeFileAt(t, first, time.Now())
+		writeFileAt(t, second, time.Now())
+		g.Eventually(readLog).WithTimeout(5 * time.Second).
+			Should(Equal("[]\n[" + first + "\n" + second + "]\n"))
+
+		cancel()
+		g.Eventually(done).WithTimeout(5 * time.Second).Should(Receive(HaveOccurred()))
+	})
+
+	t.Run("WatchStatusSumsUpRunsAndReadsKeys", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "input.txt")
+
+		ran := make(chan struct{}, 10)
+		runs := 0
+		target := targ.Targ(func() error {
+			runs++
+			ran <- struct{}{}
+
+			if runs == 3 {
+				return errors.New("boom")
+			}
+
+			return nil
+		}).Name("check")
+
+		keys, typed := io.Pipe()
+		done := make(chan targ.ExecuteResult, 1)
+
+		go func() {
+			result, err := targ.ExecuteWithOptions([]string{
+				"app", "--watch", filepath.Join(dir, "*.txt"), "--watch-status", "check",
+			}, targ.RunOptions{Stdin: keys}, target)
+			g.Expect(err).NotTo(HaveOccurred(), "q quits cleanly")
+
+			done <- result
+		}()
+
+		g.Eventually(ran).WithTimeout(5 * time.Second).Should(Receive())
+
+		_, _ = typed.Write([]byte("r"))
+		g.Eventually(ran).WithTimeout(5*time.Second).Should(Receive(), "r re-runs")
+
+		writeFileAt(t, input, time.Now())
+		g.Eventually(ran).WithTimeout(5*time.Second).Should(Receive(), "changes re-run")
+
+		writeFileAt(t, input, time.Now().Add(time.Second))
+		g.Eventually(ran).WithTimeout(5*time.Second).Should(Receive(), "a failed run doesn't end the watch")
+
+		_, _ = typed.Write([]byte("q"))
+
+		var result targ.ExecuteResult
+
+		g.Eventually(done).WithTimeout(5 * time.Second).Should(Receive(&result))
+		g.Expect(result.Output).To(MatchRegexp(`(?s)^run #1\nPASS in \S+\n` +
+			`watching for changes \(r: re-run, q: quit\)\n─+\n` +
+			`run #2 \(re-run\)\nPASS in .*─+\n` +
+			`run #3 triggered by \S*input\.txt\nFAIL in \S+: boom\n.*─+\n` +
+			`run #4 triggered by \S*input\.txt\nPASS in `))
+	})
+
+	t.Run("WatchStatusLeavesNonTerminalStdinAlone", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		stdin, typed, err := os.Pipe()
+		g.Expect(err).NotTo(HaveOccurred())
+
+		defer stdin.Close()
+		defer typed.Close()
+
+		_, err = typed.Write([]byte("q"))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		ran := make(chan struct{}, 10)
+		target := targ.Targ(func() { ran <- struct{}{} }).Name("check")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			_, _ = targ.ExecuteWithOptions([]string{
+				"app", "--watch", filepath.Join(t.TempDir(), "*.txt"), "--watch-status", "check",
+			}, targ.RunOptions{Context: ctx, Stdin: stdin}, target)
+		}()
+
+		g.Eventually(ran).WithTimeout(5 * time.Second).Should(Receive())
+		g.Consistently(done).WithTimeout(200*time.Millisecond).ShouldNot(BeClosed(),
+			"q on a pipe isn't a key press")
+
+		cancel()
+		g.Eventually(done).WithTimeout(5 * time.Second).Should(BeClosed())
+
+		buf := make([]byte, 1)
+		_, err = stdin.Read(buf)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(buf)).To(Equal("q"), "stdin is left for the commands run")
+	})
+
+	t.Run("WatchingDepsRerunsOnlyAffectedDeps", func(t *testing.T) {
+		t.Parallel()
+
+		for _, tc := range []struct {
+			name string
+			run  func(ctx context.Context, ci *targ.Target) error
+		}{
+			{"FromDeps", func(ctx context.Context, ci *targ.Target) error {
+				return ci.Watch(targ.FromDeps, targ.WatchOptions{Interval: 10 * time.Millisecond}).Run(ctx)
+			}},
+			{"WatchDepsFlag", func(ctx context.Context, ci *targ.Target) error {
+				_, err := targ.ExecuteWithOptions(
+					[]string{"app", "--watch-deps", "ci"}, targ.RunOptions{Context: ctx}, ci)
+
+				return err
+			}},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+				g := NewWithT(t)
+
+				dir := t.TempDir()
+				g.Expect(os.MkdirAll(filepath.Join(dir, "src"), 0o750)).To(Succeed())
+				g.Expect(os.MkdirAll(filepath.Join(dir, "docs"), 0o750)).To(Succeed())
+
+				ran := make(chan string, 10)
+				build := targ.Targ(func() { ran <- "build" }).Name("build").
+					Watch(filepath.Join(dir, "src", "*.go"), "!"+filepath.Join(dir, "src", "*_test.go"))
+				test := targ.Targ(func() { ran <- "test" }).Name("test").
+					Cache(filepath.Join(dir, "src", "*_test.go")).CacheDir(filepath.Join(dir, ".cache"))
+				docs := targ.Targ(func() { ran <- "docs" }).Name("docs").Watch(filepath.Join(dir, "docs", "*.md"))
+				ci := targ.Targ().Name("ci").Deps(build, test, docs)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				done := make(chan error, 1)
+
+				go func() { done <- tc.run(ctx, ci) }()
+
+				expectRuns := func(names ...string) {
+					t.Helper()
+
+					for _, name := range names {
+						g.Eventually(ran).WithTimeout(5 * time.Second).Should(Receive(Equal(name)))
+					}
+
+					g.Consistently(ran).WithTimeout(300 * time.Millisecond).ShouldNot(Receive())
+				}
+
+				expectRuns("build", "test", "docs")
+
+				writeFileAt(t, filepath.Join(dir, "src", "main.go"), time.Now())
+				expectRuns("build")
+
+				writeFileAt(t, filepath.Join(dir, "src", "main_test.go"), time.Now())
+				expectRuns("test")
+
+				writeFileAt(t, filepath.Join(dir, "docs", "README.md"), time.Now())
+				expectRuns("docs")
+
+				cancel()
+				g.Eventually(done).WithTimeout(5 * time.Second).Should(Receive(HaveOccurred()))
+			})
+		}
+	})
+
+	t.Run("WatchingDepsChecksTheCacheAfterDepsRun", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src.txt")
+		generated := filepath.Join(dir, "gen.txt")
+		g.Expect(os.WriteFile(src, []byte("v1"), 0o600)).To(Succeed())
+
+		generate := targ.Targ(func() error {
+			data, err := os.ReadFile(src)
+			if err != nil {
+				return err
+			}
+
+			return os.WriteFile(generated, data, 0o600)
+		}).Name("generate").Cache(src).CacheDir(filepath.Join(dir, ".cache"))
+
+		built := make(chan struct{}, 10)
+		build := targ.Targ(func() { built <- struct{}{} }).Name("build").
+			Cache(generated).
+			CacheDir(filepath.Join(dir, ".cache")).
+			Deps(generate)
+
+		result, err := targ.ExecuteWithOptions([]string{"app", "build"}, targ.RunOptions{}, build)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+		g.Expect(built).To(Receive())
+
+		// The cache entry matches gen.txt as it is now, but generate is about to change it.
+		g.Expect(os.WriteFile(src, []byte("v2"), 0o600)).To(Succeed())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+
+		go func() {
+			_, err := targ.ExecuteWithOptions(
+				[]string{"app", "--watch-deps", "build"}, targ.RunOptions{Context: ctx}, build)
+			done <- err
+		}()
+
+		g.Eventually(built).WithTimeout(5*time.Second).Should(Receive(),
+			"the cache is checked against what generate produced")
+
+		cancel()
+		g.Eventually(done).WithTimeout(5 * time.Second).Should(Receive())
+	})
+
 	t.Run("ParallelModeSkipsFlags", func(t *testing.T) {
 		t.Parallel()
 		g := NewWithT(t)
@@ -1594,3 +2214,394 @@ func TestProperty_Execution(t *testing.T) {
 		g.Eventually(dep2Cancelled.Load).Should(BeTrue())
 	})
 }
+
+func TestProperty_BoundedParallelism(t *testing.T) {
+	t.Parallel()
+
+	t.Run("JobsFlagLimitsParallelDeps", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		probe := &concurrencyProbe{}
+		ci := targ.Targ(func() {}).Name("ci").Deps(probe.parallelDeps("dep", 6)...)
+
+		result, err := targ.ExecuteWithOptions(
+			[]string{"app", "--jobs", "2", "ci"},
+			targ.RunOptions{},
+			ci,
+		)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+		g.Expect(probe.runs.Load()).To(Equal(int32(6)))
+		g.Expect(probe.peak.Load()).To(BeNumerically("<=", 2))
+	})
+
+	t.Run("MaxParallelOptionLimitsParallelDeps", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		probe := &concurrencyProbe{}
+		ci := targ.Targ(func() {}).Name("ci").Deps(probe.parallelDeps("dep", 4)...)
+
+		result, err := targ.ExecuteWithOptions(
+			[]string{"app", "ci"},
+			targ.RunOptions{MaxParallel: 1},
+			ci,
+		)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+		g.Expect(probe.peak.Load()).To(Equal(int32(1)))
+	})
+
+	t.Run("NestedParallelGroupsDoNotDeadlock", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		probe := &concurrencyProbe{}
+		a := targ.Targ(probe.work).Name("a").Deps(probe.parallelDeps("a", 2)...)
+		b := targ.Targ(probe.work).Name("b").Deps(probe.parallelDeps("b", 2)...)
+		ci := targ.Targ(func() {}).Name("ci").Deps(a, b, targ.DepModeParallel)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		result, err := targ.ExecuteWithOptions(
+			[]string{"app", "-j", "1", "ci"},
+			targ.RunOptions{Context: ctx},
+			ci,
+		)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+		g.Expect(probe.runs.Load()).To(Equal(int32(6)))
+		g.Expect(probe.peak.Load()).To(Equal(int32(1)))
+	})
+
+	t.Run("TargetsRunFromAFunctionShareTheLimit", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		probe := &concurrencyProbe{}
+		a := targ.Targ(probe.work).Name("a").Deps(probe.parallelDeps("a", 3)...)
+		b := targ.Targ(probe.work).Name("b").Deps(probe.parallelDeps("b", 3)...)
+		ci := targ.Targ(func(ctx context.Context) error {
+			probe.work()
+
+			var wg sync.WaitGroup
+
+			errs := make([]error, 2)
+			for i, target := range []*targ.Target{a, b} {
+				wg.Go(func() { errs[i] = target.Run(ctx) })
+			}
+
+			wg.Wait()
+
+			return errors.Join(errs...)
+		}).Name("ci")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		result, err := targ.ExecuteWithOptions(
+			[]string{"app", "-j", "1", "ci"},
+			targ.RunOptions{Context: ctx},
+			ci,
+		)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+		g.Expect(probe.runs.Load()).To(Equal(int32(9)))
+		g.Expect(probe.peak.Load()).To(Equal(int32(1)))
+	})
+
+	t.Run("ConcurrencyLimitsDepGroup", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		probe := &concurrencyProbe{}
+		ci := targ.Targ(func() {}).
+			Deps(probe.parallelDeps("dep", 6)...).
+			Concurrency(3)
+
+		g.Expect(ci.GetConcurrency()).To(Equal(3))
+		g.Expect(ci.Run(context.Background())).To(Succeed())
+		g.Expect(probe.runs.Load()).To(Equal(int32(6)))
+		g.Expect(probe.peak.Load()).To(BeNumerically("<=", 3))
+	})
+
+	t.Run("JobsRequiresPositiveNumber", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		ci := targ.Targ(func() {}).Name("ci")
+
+		result, err := targ.Execute([]string{"app", "--jobs", "0", "ci"}, ci)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(result.Output).To(ContainSubstring("--jobs requires a positive number"))
+	})
+}
+
+func TestProperty_DependencyGraph(t *testing.T) {
+	t.Parallel()
+
+	// graphTargets returns ci, which runs generate, then lint and test in parallel,
+	// with lint and test registered under the "check" group.
+	graphTargets := func() []any {
+		generate := targ.Targ(func() {}).Name("generate")
+		lint := targ.Targ(func() {}).Name("lint").Deps(generate)
+		test := targ.Targ(func() {}).Name("test")
+		ci := targ.Targ(func() {}).Name("ci").
+			Deps(generate).
+			Deps(lint, test, targ.DepModeParallel)
+
+		return []any{ci, targ.Group("check", lint, test)}
+	}
+
+	t.Run("DotShowsModesAndGroupClusters", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		result, err := targ.Execute([]string{"app", "--graph"}, graphTargets()...)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+		g.Expect(result.Output).To(Equal(`digraph targets {
+  rankdir=LR;
+  "ci" [label="ci"];
+  "generate" [label="generate"];
+  subgraph "cluster_1" {
+    label="check";
+    "check lint" [label="lint"];
+    "check test" [label="test"];
+  }
+  "ci" -> "generate" [label="serial"];
+  "ci" -> "check lint" [label="parallel", style=dashed];
+  "ci" -> "check test" [label="parallel", style=dashed];
+  "check lint" -> "generate" [label="serial"];
+}
+`))
+	})
+
+	t.Run("MermaidFormat", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		result, err := targ.Execute(
+			[]string{"app", "--graph", "--format=mermaid"},
+			graphTargets()...,
+		)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+		g.Expect(result.Output).To(HavePrefix("flowchart LR\n"))
+		g.Expect(result.Output).To(ContainSubstring(`subgraph g1 ["check"]`))
+		g.Expect(result.Output).To(ContainSubstring("n0 -.->|parallel| n1"))
+	})
+
+	t.Run("JSONLimitedToTarget", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		result, err := targ.Execute(
+			[]string{"app", "--graph", "check", "lint", "--format", "json"},
+			graphTargets()...,
+		)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+		g.Expect(result.Output).To(MatchJSON(`{
+			"nodes": [
+				{"id": "check lint", "name": "lint", "groups": ["check"]},
+				{"id": "generate", "name": "generate"}
+			],
+			"edges": [
+				{"from": "check lint", "to": "generate", "mode": "serial", "group": 1}
+			]
+		}`))
+	})
+
+	t.Run("CollectAllMode", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		a := targ.Targ(func() {}).Name("a")
+		ci := targ.Targ(func() {}).Name("ci").Deps(a, targ.DepModeParallel, targ.CollectAllErrors)
+
+		result, err := targ.Execute([]string{"app", "--graph", "ci"}, ci, a)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+		g.Expect(result.Output).To(ContainSubstring(`"ci" -> "a" [label="collect-all", style=dashed];`))
+	})
+
+	t.Run("ReportsCycles", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		a := targ.Targ(func() {}).Name("a")
+		b := targ.Targ(func() {}).Name("b").Deps(a)
+		a.Deps(b)
+
+		result, err := targ.Execute([]string{"app", "--graph"}, a, b)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(result.Output).To(ContainSubstring(`dependency cycle: "a" depends on itself`))
+	})
+
+	t.Run("RejectsUnknownFormat", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		result, err := targ.Execute([]string{"app", "--graph", "--format=svg"}, graphTargets()...)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(result.Output).To(ContainSubstring("--format must be"))
+	})
+}
+
+func TestProperty_NamedLocks(t *testing.T) {
+	t.Parallel()
+
+	t.Run("TargetsSharingALockNeverOverlap", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		probe := &concurrencyProbe{}
+		a := targ.Targ(probe.work).Name("a").Lock("lock-overlap")
+		b := targ.Targ(probe.work).Name("b").Lock("lock-overlap")
+		c := targ.Targ(probe.work).Name("c").Lock("lock-overlap")
+		ci := targ.Targ(func() {}).Name("ci").Deps(a, b, c, targ.DepModeParallel)
+
+		result, err := targ.ExecuteWithOptions([]string{"app", "ci"}, targ.RunOptions{}, ci)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+		g.Expect(probe.runs.Load()).To(Equal(int32(3)))
+		g.Expect(probe.peak.Load()).To(Equal(int32(1)))
+		g.Expect(result.Output).To(ContainSubstring("waiting for lock lock-overlap..."))
+		g.Expect(a.GetLocks()).To(Equal([]string{"lock-overlap"}))
+	})
+
+	t.Run("DifferentLocksRunInParallel", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var started sync.WaitGroup
+
+		started.Add(2)
+
+		rendezvous := func() error {
+			started.Done()
+
+			done := make(chan struct{})
+			go func() {
+				started.Wait()
+				close(done)
+			}()
+
+			select {
+			case <-done:
+				return nil
+			case <-time.After(5 * time.Second):
+				return errors.New("other target never started")
+			}
+		}
+
+		a := targ.Targ(rendezvous).Name("a").Lock("lock-parallel-a")
+		b := targ.Targ(rendezvous).Name("b").Lock("lock-parallel-b")
+		ci := targ.Targ(func() {}).Deps(a, b, targ.DepModeParallel)
+
+		g.Expect(ci.Run(context.Background())).To(Succeed())
+	})
+
+	t.Run("NestedTargetReusesParentLock", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		inner := targ.Targ(func() {}).Name("inner").Lock("lock-nested")
+		outer := targ.Targ(func(ctx context.Context) error {
+			return inner.Run(ctx)
+		}).Name("outer").Lock("lock-nested")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		g.Expect(outer.Run(ctx)).To(Succeed())
+	})
+
+	t.Run("NestedTargetsSharingTheParentLockTakeTurns", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		probe := &concurrencyProbe{}
+		a := targ.Targ(probe.work).Name("a").Lock("lock-nested-siblings")
+		b := targ.Targ(probe.work).Name("b").Lock("lock-nested-siblings")
+		outer := targ.Targ(func(ctx context.Context) error {
+			var wg sync.WaitGroup
+
+			errs := make([]error, 2)
+			for i, target := range []*targ.Target{a, b} {
+				wg.Go(func() { errs[i] = target.Run(ctx) })
+			}
+
+			wg.Wait()
+
+			return errors.Join(errs...)
+		}).Name("outer").Lock("lock-nested-siblings")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		g.Expect(outer.Run(ctx)).To(Succeed())
+		g.Expect(probe.runs.Load()).To(Equal(int32(2)))
+		g.Expect(probe.peak.Load()).To(Equal(int32(1)))
+	})
+
+	t.Run("LockOrderDeadlockIsReported", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var holding sync.WaitGroup
+
+		holding.Add(2)
+
+		// Each outer target takes one lock, waits until the other holds its lock,
+		// then runs an inner target needing the other's lock.
+		nested := func(inner *targ.Target) func(context.Context) error {
+			return func(ctx context.Context) error {
+				holding.Done()
+				holding.Wait()
+
+				return inner.Run(ctx)
+			}
+		}
+
+		needY := targ.Targ(func() {}).Name("need-y").Lock("lock-deadlock-y")
+		needX := targ.Targ(func() {}).Name("need-x").Lock("lock-deadlock-x")
+		a := targ.Targ(nested(needY)).Name("a").Lock("lock-deadlock-x")
+		b := targ.Targ(nested(needX)).Name("b").Lock("lock-deadlock-y")
+		ci := targ.Targ(func() {}).Deps(a, b, targ.DepModeParallel)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err := ci.Run(ctx)
+		g.Expect(err).To(MatchError(targ.ErrLockDeadlock))
+		g.Expect(err.Error()).To(ContainSubstring("holds lock-deadlock-"))
+	})
+}
+
+// concurrencyProbe records how many targets run at once.
+type concurrencyProbe struct {
+	running atomic.Int32
+	peak    atomic.Int32
+	runs    atomic.Int32
+}
+
+// parallelDeps returns Deps args for n parallel targets named prefix-1..prefix-n that do probed work.
+func (p *concurrencyProbe) parallelDeps(prefix string, n int) []any {
+	args := make([]any, 0, n+1)
+	for i := range n {
+		args = append(args, targ.Targ(p.work).Name(prefix+"-"+string(rune('1'+i))))
+	}
+
+	return append(args, targ.DepModeParallel)
+}
+
+func (p *concurrencyProbe) work() {
+	p.runs.Add(1)
+
+	now := p.running.Add(1)
+	defer p.running.Add(-1)
+
+	for {
+		peak := p.peak.Load()
+		if now <= peak || p.peak.CompareAndSwap(peak, now) {
+			break
+		}
+	}
+
+	time.Sleep(20 * time.Millisecond)
+}
@@ -0,0 +1,1574 @@
+// TEST-035: File properties - validates up-to-date checks, cache keys, the artifact cache (local and remote), and file helpers
+// traces: ARCH-002
+
+package targ_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/toejough/targ"
+)
+
+func TestProperty_Match(t *testing.T) {
+	t.Parallel()
+
+	t.Run("NegatedPatternsExcludePathsAndDirectories", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		for _, name := range []string{"main.go", "zz_gen.go", "sub/util.go", "vendor/dep/dep.go"} {
+			writeFileAt(t, filepath.Join(dir, name), time.Now())
+		}
+
+		matches, err := targ.Match(
+			filepath.Join(dir, "**", "*.go"),
+			"!"+filepath.Join(dir, "vendor"),
+			"!"+filepath.Join(dir, "**", "zz_*.go"),
+		)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(matches).To(ConsistOf(
+			filepath.Join(dir, "main.go"),
+			filepath.Join(dir, "sub", "util.go"),
+		))
+	})
+
+	t.Run("RespectingIgnoreFilesAppliesGitignoreAndTargignore", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		g.Expect(os.Mkdir(filepath.Join(dir, ".git"), 0o750)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, ".gitignore"),
+			[]byte("# build output\n*.log\n!keep.log\nbuild/\n"), 0o600)).To(Succeed())
+		g.Expect(os.MkdirAll(filepath.Join(dir, "docs"), 0o750)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, "docs", ".targignore"),
+			[]byte("/draft.md\n"), 0o600)).To(Succeed())
+
+		for _, name := range []string{
+			"main.go", "debug.log", "keep.log", "build/out.go", "src/build",
+			"docs/draft.md", "docs/guide.md", "docs/sub/draft.md", ".git/HEAD",
+		} {
+			writeFileAt(t, filepath.Join(dir, name), time.Now())
+		}
+
+		matches, err := targ.MatchWithOptions(
+			[]string{filepath.Join(dir, "**")},
+			targ.MatchOptions{RespectIgnoreFiles: true},
+		)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(matches).To(ConsistOf(
+			dir,
+			filepath.Join(dir, ".gitignore"),
+			filepath.Join(dir, "docs"),
+			filepath.Join(dir, "docs", ".targignore"),
+			filepath.Join(dir, "docs", "guide.md"),
+			filepath.Join(dir, "docs", "sub"),
+			filepath.Join(dir, "docs", "sub", "draft.md"),
+			filepath.Join(dir, "keep.log"),
+			filepath.Join(dir, "main.go"),
+			filepath.Join(dir, "src"),
+			filepath.Join(dir, "src", "build"),
+		), "build/ only matches directories and /draft.md is anchored to docs")
+	})
+
+	t.Run("CacheIgnoresItsOwnDirectory", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		writeFileAt(t, filepath.Join(dir, "main.go"), time.Now())
+
+		runs := 0
+		build := targ.Targ(func() { runs++ }).
+			Cache(filepath.Join(dir, "**", "*")).
+			CacheDir(filepath.Join(dir, ".targ-cache"))
+
+		g.Expect(build.Run(context.Background())).To(Succeed())
+		g.Expect(build.Run(context.Background())).To(Succeed())
+		g.Expect(runs).To(Equal(1), "writing the cache entry doesn't invalidate it")
+	})
+
+	t.Run("TargetRespectingIgnoreFilesLeavesIgnoredInputsOut", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		g.Expect(os.Mkdir(filepath.Join(dir, ".git"), 0o750)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, ".gitignore"),
+			[]byte("*.log\nbuild/\n"), 0o600)).To(Succeed())
+		writeFileAt(t, filepath.Join(dir, "main.go"), time.Now().Add(-time.Hour))
+
+		output := filepath.Join(dir, "build", "app")
+		runs := 0
+		build := targ.Targ(func() {
+			runs++
+			writeFileAt(t, output, time.Now())
+		}).
+			Cache(filepath.Join(dir, "**", "*")).
+			CacheDir(filepath.Join(dir, ".targ-cache")).
+			Outputs(output).
+			RespectIgnoreFiles()
+
+		g.Expect(build.GetRespectIgnoreFiles()).To(BeTrue())
+		g.Expect(build.Run(context.Background())).To(Succeed())
+		writeFileAt(t, filepath.Join(dir, "debug.log"), time.Now().Add(time.Minute))
+		g.Expect(build.Run(context.Background())).To(Succeed())
+		g.Expect(runs).To(Equal(1),
+			"a newer ignored file is no input, and outputs in an ignored directory still count")
+
+		g.Expect(os.WriteFile(filepath.Join(dir, "main.go"), []byte("changed"), 0o600)).To(Succeed())
+		g.Expect(build.Run(context.Background())).To(Succeed())
+		g.Expect(runs).To(Equal(2))
+	})
+
+	t.Run("RespectIgnoreFlagAppliesToCLIPatterns", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		g.Expect(os.Mkdir(filepath.Join(dir, ".git"), 0o750)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*.log\n"), 0o600)).To(Succeed())
+		writeFileAt(t, filepath.Join(dir, "main.go"), time.Now())
+
+		runs := 0
+		build := targ.Targ(func() { runs++ }).Name("build").Cache(targ.Disabled)
+		args := []string{
+			"app", "--respect-ignore",
+			"--cache", filepath.Join(dir, "**", "*"),
+			"--cache-dir", filepath.Join(dir, ".targ-cache"),
+			"build",
+		}
+
+		result, err := targ.ExecuteWithOptions(args, targ.RunOptions{}, build)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+
+		writeFileAt(t, filepath.Join(dir, "debug.log"), time.Now())
+
+		result, err = targ.ExecuteWithOptions(args, targ.RunOptions{}, build)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+		g.Expect(runs).To(Equal(1))
+	})
+}
+
+func TestProperty_Newer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("MissingOutputNeedsRebuild", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		writeFileAt(t, filepath.Join(dir, "main.go"), time.Now())
+
+		needs, err := targ.Newer(
+			[]string{filepath.Join(dir, "*.go")},
+			[]string{filepath.Join(dir, "bin", "app")},
+		)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(needs).To(BeTrue())
+	})
+
+	t.Run("OlderOutputNeedsRebuild", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		now := time.Now()
+		writeFileAt(t, filepath.Join(dir, "main.go"), now)
+		writeFileAt(t, filepath.Join(dir, "app"), now.Add(-time.Hour))
+
+		needs, err := targ.Newer(
+			[]string{filepath.Join(dir, "*.go")},
+			[]string{filepath.Join(dir, "app")},
+		)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(needs).To(BeTrue())
+	})
+
+	t.Run("NewerOutputIsUpToDate", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		now := time.Now()
+		writeFileAt(t, filepath.Join(dir, "main.go"), now.Add(-time.Hour))
+		writeFileAt(t, filepath.Join(dir, "app"), now)
+
+		needs, err := targ.Newer(
+			[]string{filepath.Join(dir, "*.go")},
+			[]string{filepath.Join(dir, "app")},
+		)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(needs).To(BeFalse())
+	})
+
+	t.Run("AnyMissingOutputPatternNeedsRebuild", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		now := time.Now()
+		writeFileAt(t, filepath.Join(dir, "main.go"), now.Add(-time.Hour))
+		writeFileAt(t, filepath.Join(dir, "app"), now)
+
+		needs, err := targ.Newer(
+			[]string{filepath.Join(dir, "*.go")},
+			[]string{filepath.Join(dir, "app"), filepath.Join(dir, "*.tar.gz")},
+		)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(needs).To(BeTrue())
+	})
+
+	t.Run("RequiresPatterns", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := targ.Newer(nil, []string{"app"})
+		g.Expect(err).To(MatchError(targ.ErrNoInputPatterns))
+
+		_, err = targ.Newer([]string{"*.go"}, nil)
+		g.Expect(err).To(MatchError(targ.ErrNoOutputPatterns))
+	})
+}
+
+func TestProperty_CacheOutputs(t *testing.T) {
+	t.Parallel()
+
+	t.Run("CacheHitRequiresDeclaredOutputs", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		output := filepath.Join(dir, "app")
+		writeFileAt(t, input, time.Now().Add(-time.Hour))
+
+		runs := 0
+		build := targ.Targ(func() error {
+			runs++
+			return os.WriteFile(output, []byte("binary"), 0o600)
+		}).Cache(input).Outputs(output).CacheDir(filepath.Join(dir, ".cache"))
+
+		g.Expect(build.Run(context.Background())).To(Succeed())
+		g.Expect(runs).To(Equal(1))
+
+		g.Expect(build.Run(context.Background())).To(Succeed())
+		g.Expect(runs).To(Equal(1), "inputs and outputs unchanged: cache hit")
+	})
+
+	t.Run("HelpShowsOutputs", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		build := targ.Targ(func() {}).Name("build").Cache("**/*.go").Outputs("bin/app")
+
+		result, err := targ.Execute([]string{"app", "build", "--help"}, build)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result.Output).To(ContainSubstring("Outputs: bin/app"))
+	})
+}
+
+func TestProperty_ArtifactCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("RestoresDeletedOutputsWithoutRerun", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		output := filepath.Join(dir, "bin", "app")
+		writeFileAt(t, input, time.Now())
+
+		runs := 0
+		build := targ.Targ(func() error {
+			runs++
+			return writeOutput(output, "binary")
+		}).Cache(input).Outputs(output).CacheDir(filepath.Join(dir, ".cache"))
+
+		g.Expect(build.Run(context.Background())).To(Succeed())
+		g.Expect(os.RemoveAll(filepath.Join(dir, "bin"))).To(Succeed())
+
+		g.Expect(build.Run(context.Background())).To(Succeed())
+		g.Expect(runs).To(Equal(1), "outputs restored from the artifact cache")
+		g.Expect(os.ReadFile(output)).To(Equal([]byte("binary")))
+	})
+
+	t.Run("RestoresOutputsForPreviousInputs", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		output := filepath.Join(dir, "app")
+
+		runs := 0
+		build := targ.Targ(func() error {
+			runs++
+
+			data, err := os.ReadFile(input)
+			if err != nil {
+				return err
+			}
+
+			return writeOutput(output, "built from "+string(data))
+		}).Cache(input).Outputs(output).CacheDir(filepath.Join(dir, ".cache"))
+
+		// Build on one branch, switch branches and build, then switch back.
+		g.Expect(os.WriteFile(input, []byte("main"), 0o600)).To(Succeed())
+		g.Expect(build.Run(context.Background())).To(Succeed())
+		g.Expect(os.WriteFile(input, []byte("feature"), 0o600)).To(Succeed())
+		g.Expect(build.Run(context.Background())).To(Succeed())
+		g.Expect(os.WriteFile(input, []byte("main"), 0o600)).To(Succeed())
+		g.Expect(build.Run(context.Background())).To(Succeed())
+
+		g.Expect(runs).To(Equal(2), "switching back restores instead of re-running")
+		g.Expect(os.ReadFile(output)).To(Equal([]byte("built from main")))
+	})
+
+	t.Run("FailedRunIsNotCached", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		writeFileAt(t, input, time.Now())
+
+		runs := 0
+		build := targ.Targ(func() error {
+			runs++
+			return errors.New("compile error")
+		}).Cache(input).CacheDir(filepath.Join(dir, ".cache"))
+
+		g.Expect(build.Run(context.Background())).NotTo(Succeed())
+		g.Expect(build.Run(context.Background())).NotTo(Succeed())
+		g.Expect(runs).To(Equal(2))
+	})
+
+	t.Run("CLIRestoresDeletedOutputs", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		output := filepath.Join(dir, "app")
+		writeFileAt(t, input, time.Now())
+
+		runs := 0
+		build := targ.Targ(func() error {
+			runs++
+			return writeOutput(output, "binary")
+		}).Name("build").Cache(input).Outputs(output)
+		args := []string{"app", "--cache-dir", filepath.Join(dir, ".cache")}
+
+		res, err := targ.Execute(args, build)
+		g.Expect(err).NotTo(HaveOccurred(), res.Output)
+		g.Expect(os.Remove(output)).To(Succeed())
+
+		_, err = targ.Execute(args, build)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(runs).To(Equal(1))
+		g.Expect(os.ReadFile(output)).To(Equal([]byte("binary")))
+	})
+
+	t.Run("RestoredOutputDirHoldsOnlyCachedFiles", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		dist := filepath.Join(dir, "dist")
+
+		runs := 0
+		build := targ.Targ(func() error {
+			runs++
+
+			data, err := os.ReadFile(input)
+			if err != nil {
+				return err
+			}
+
+			err = os.RemoveAll(dist)
+			if err != nil {
+				return err
+			}
+
+			return writeOutput(filepath.Join(dist, string(data)+".js"), "built")
+		}).Cache(input).Outputs(dist).CacheDir(filepath.Join(dir, ".cache"))
+
+		g.Expect(os.WriteFile(input, []byte("main"), 0o600)).To(Succeed())
+		g.Expect(build.Run(context.Background())).To(Succeed())
+		g.Expect(os.WriteFile(input, []byte("feature"), 0o600)).To(Succeed())
+		g.Expect(build.Run(context.Background())).To(Succeed())
+
+		g.Expect(writeOutput(filepath.Join(dist, "sub", "stray.js"), "stray")).To(Succeed())
+		g.Expect(os.WriteFile(input, []byte("main"), 0o600)).To(Succeed())
+		g.Expect(build.Run(context.Background())).To(Succeed())
+
+		g.Expect(runs).To(Equal(2), "switching back restores instead of re-running")
+
+		var files []string
+
+		g.Expect(filepath.WalkDir(dist, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				files = append(files, path)
+			}
+
+			return err
+		})).To(Succeed())
+		g.Expect(files).To(Equal([]string{filepath.Join(dist, "main.js")}))
+		g.Expect(filepath.Join(dist, "sub")).NotTo(BeADirectory())
+	})
+
+	t.Run("RestoresSymlinkOutputsAsLinks", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		dist := filepath.Join(dir, "dist")
+		latest := filepath.Join(dist, "latest")
+		writeFileAt(t, input, time.Now())
+
+		runs := 0
+		build := targ.Targ(func() error {
+			runs++
+
+			err := writeOutput(filepath.Join(dist, "app-v1"), "binary")
+			if err != nil {
+				return err
+			}
+
+			return os.Symlink("app-v1", latest)
+		}).Cache(input).Outputs(dist).CacheDir(filepath.Join(dir, ".cache"))
+
+		g.Expect(build.Run(context.Background())).To(Succeed())
+		g.Expect(os.RemoveAll(dist)).To(Succeed())
+
+		g.Expect(build.Run(context.Background())).To(Succeed())
+		g.Expect(runs).To(Equal(1), "outputs restored from the artifact cache")
+		g.Expect(os.Readlink(latest)).To(Equal("app-v1"))
+		g.Expect(os.ReadFile(latest)).To(Equal([]byte("binary")))
+	})
+}
+
+func TestProperty_RemoteCache(t *testing.T) {
+	t.Parallel()
+
+	// newBuild returns a cached build target and a counter of how often it actually ran.
+	newBuild := func(t *testing.T, dir string) (*targ.Target, *int) {
+		t.Helper()
+		writeFileAt(t, filepath.Join(dir, "main.go"), time.Now())
+
+		runs := 0
+		build := targ.Targ(func() error {
+			runs++
+			return writeOutput(filepath.Join(dir, "app"), "binary")
+		}).Name("build").Cache(filepath.Join(dir, "main.go")).Outputs(filepath.Join(dir, "app"))
+
+		return build, &runs
+	}
+
+	// runOn executes build as if on a machine whose local cache is cacheDir.
+	runOn := func(g Gomega, cacheDir string, opts targ.RunOptions, build *targ.Target) {
+		result, err := targ.ExecuteWithOptions(
+			[]string{"app", "--cache-dir", cacheDir, "build"}, opts, build,
+		)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+	}
+
+	t.Run("SharedDirectoryRestoresOnCleanMachine", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		build, runs := newBuild(t, dir)
+		opts := targ.RunOptions{CacheBackend: targ.NewDirCacheBackend(filepath.Join(dir, "shared"))}
+
+		runOn(g, filepath.Join(dir, "ci-cache"), opts, build)
+		g.Expect(os.Remove(filepath.Join(dir, "app"))).To(Succeed())
+		runOn(g, filepath.Join(dir, "dev-cache"), opts, build)
+
+		g.Expect(*runs).To(Equal(1), "second machine restores from the shared cache")
+		g.Expect(os.ReadFile(filepath.Join(dir, "app"))).To(Equal([]byte("binary")))
+	})
+
+	t.Run("HTTPBackendRoundTrip", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		server, store := newCacheServer(t)
+		dir := t.TempDir()
+		build, runs := newBuild(t, dir)
+		opts := targ.RunOptions{CacheBackend: targ.NewHTTPCacheBackend(server.URL + "/cache")}
+
+		runOn(g, filepath.Join(dir, "ci-cache"), opts, build)
+		g.Expect(store.keys()).To(ContainElement(HavePrefix("/cache/entries/")))
+		g.Expect(store.keys()).To(ContainElement(HavePrefix("/cache/objects/")))
+
+		g.Expect(os.Remove(filepath.Join(dir, "app"))).To(Succeed())
+		runOn(g, filepath.Join(dir, "dev-cache"), opts, build)
+
+		g.Expect(*runs).To(Equal(1))
+		g.Expect(os.ReadFile(filepath.Join(dir, "app"))).To(Equal([]byte("binary")))
+	})
+
+	t.Run("CorruptedRemoteObjectIsNotRestored", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		shared := filepath.Join(dir, "shared")
+		build, runs := newBuild(t, dir)
+		opts := targ.RunOptions{CacheBackend: targ.NewDirCacheBackend(shared)}
+
+		runOn(g, filepath.Join(dir, "ci-cache"), opts, build)
+
+		objects, err := filepath.Glob(filepath.Join(shared, "objects", "*"))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(objects).NotTo(BeEmpty())
+
+		for _, object := range objects {
+			g.Expect(os.WriteFile(object, []byte("tampered"), 0o600)).To(Succeed())
+		}
+
+		g.Expect(os.Remove(filepath.Join(dir, "app"))).To(Succeed())
+		runOn(g, filepath.Join(dir, "dev-cache"), opts, build)
+
+		g.Expect(*runs).To(Equal(2), "corrupted object is a miss, so the target re-runs")
+		g.Expect(os.ReadFile(filepath.Join(dir, "app"))).To(Equal([]byte("binary")))
+	})
+
+	t.Run("EntryPathsOutsideTheOutputsAreNotRestored", func(t *testing.T) {
+		t.Parallel()
+
+		for _, tampered := range []string{"evil", "../evil", "app/../evil"} {
+			g := NewWithT(t)
+
+			dir := t.TempDir()
+			shared := filepath.Join(dir, "shared")
+			build, runs := newBuild(t, dir)
+			opts := targ.RunOptions{CacheBackend: targ.NewDirCacheBackend(shared)}
+
+			runOn(g, filepath.Join(dir, "ci-cache"), opts, build)
+
+			entries, err := filepath.Glob(filepath.Join(shared, "entries", "*.json"))
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(entries).To(HaveLen(1))
+
+			data, err := os.ReadFile(entries[0])
+			g.Expect(err).NotTo(HaveOccurred())
+
+			app := strconv.Quote(filepath.Join(dir, "app"))
+			evil := strconv.Quote(filepath.Join(dir, tampered))
+			g.Expect(os.WriteFile(entries[0], []byte(strings.Replace(string(data), app, evil, 1)), 0o600)).
+				To(Succeed())
+
+			g.Expect(os.Remove(filepath.Join(dir, "app"))).To(Succeed())
+			runOn(g, filepath.Join(dir, "dev-cache"), opts, build)
+
+			g.Expect(*runs).To(Equal(2), "%s: the tampered entry is a miss, so the target re-runs", tampered)
+			g.Expect(filepath.Join(dir, "evil")).NotTo(BeAnExistingFile())
+			g.Expect(filepath.Join(filepath.Dir(dir), "evil")).NotTo(BeAnExistingFile())
+		}
+	})
+
+	t.Run("EntryPathsUnderItsSymlinksAreNotRestored", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		shared := filepath.Join(dir, "shared")
+		outside := filepath.Join(dir, "outside")
+		dist := filepath.Join(dir, "dist")
+		g.Expect(os.Mkdir(outside, 0o755)).To(Succeed())
+		writeFileAt(t, filepath.Join(dir, "main.go"), time.Now())
+
+		runs := 0
+		build := targ.Targ(func() error {
+			runs++
+			return writeOutput(filepath.Join(dist, "app"), "binary")
+		}).Name("build").Cache(filepath.Join(dir, "main.go")).Outputs(dist)
+		opts := targ.RunOptions{CacheBackend: targ.NewDirCacheBackend(shared)}
+
+		runOn(g, filepath.Join(dir, "ci-cache"), opts, build)
+
+		entries, err := filepath.Glob(filepath.Join(shared, "entries", "*.json"))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(entries).To(HaveLen(1))
+
+		data, err := os.ReadFile(entries[0])
+		g.Expect(err).NotTo(HaveOccurred())
+
+		var entry struct {
+			Files []map[string]any `json:"files"`
+		}
+
+		g.Expect(json.Unmarshal(data, &entry)).To(Succeed())
+		g.Expect(entry.Files).To(HaveLen(1))
+
+		// A link out of dist, then a file that restoring would write through it.
+		link := filepath.Join(dist, "link")
+		through := entry.Files[0]
+		through["path"] = filepath.Join(link, "evil")
+		entry.Files = []map[string]any{{"path": link, "link": outside, "mode": 0o777}, through}
+
+		data, err = json.Marshal(entry)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(os.WriteFile(entries[0], data, 0o600)).To(Succeed())
+
+		g.Expect(os.RemoveAll(dist)).To(Succeed())
+		runOn(g, filepath.Join(dir, "dev-cache"), opts, build)
+
+		g.Expect(runs).To(Equal(2), "the tampered entry is a miss, so the target re-runs")
+		g.Expect(filepath.Join(outside, "evil")).NotTo(BeAnExistingFile())
+		g.Expect(link).NotTo(BeAnExistingFile())
+	})
+
+	t.Run("FailingRemoteIsBestEffort", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+		t.Cleanup(server.Close)
+
+		dir := t.TempDir()
+		build, runs := newBuild(t, dir)
+		opts := targ.RunOptions{CacheBackend: targ.NewHTTPCacheBackend(server.URL)}
+
+		for _, cacheDir := range []string{"ci-cache", "dev-cache"} {
+			result, err := targ.ExecuteWithOptions(
+				[]string{"app", "--cache-dir", filepath.Join(dir, cacheDir), "build"}, opts, build,
+			)
+			g.Expect(err).NotTo(HaveOccurred(), result.Output)
+			g.Expect(result.Output).To(ContainSubstring("warning: remote cache:"))
+		}
+
+		g.Expect(*runs).To(Equal(2), "each machine misses and builds")
+	})
+
+	t.Run("EnvVarSelectsBackend", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		build, runs := newBuild(t, dir)
+		opts := targ.RunOptions{Env: map[string]string{"TARG_REMOTE_CACHE": filepath.Join(dir, "shared")}}
+
+		runOn(g, filepath.Join(dir, "ci-cache"), opts, build)
+		g.Expect(os.Remove(filepath.Join(dir, "app"))).To(Succeed())
+		runOn(g, filepath.Join(dir, "dev-cache"), opts, build)
+
+		g.Expect(*runs).To(Equal(1))
+	})
+
+	t.Run("UnsupportedBackendIsAnError", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		build, runs := newBuild(t, t.TempDir())
+
+		result, err := targ.ExecuteWithOptions(
+			[]string{"app", "build"},
+			targ.RunOptions{Env: map[string]string{"TARG_REMOTE_CACHE": "s3://bucket/cache"}},
+			build,
+		)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(result.Output).To(ContainSubstring("unsupported remote cache"))
+		g.Expect(*runs).To(Equal(0))
+	})
+}
+
+// TestProperty_ArtifactCacheEviction cannot be parallel because t.Setenv modifies process environment.
+//
+//nolint:tparallel // Cannot use t.Parallel with t.Setenv - it modifies process environment
+func TestProperty_ArtifactCacheEviction(t *testing.T) {
+	t.Run("LeastRecentlyUsedEntryIsEvicted", func(t *testing.T) {
+		g := NewWithT(t)
+
+		// Room for one 16-byte output, not two.
+		t.Setenv("TARG_CACHE_MAX_SIZE", "20")
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		output := filepath.Join(dir, "app")
+
+		runs := 0
+		build := targ.Targ(func() error {
+			runs++
+
+			data, err := os.ReadFile(input)
+			if err != nil {
+				return err
+			}
+
+			return writeOutput(output, "output for "+string(data))
+		}).Cache(input).Outputs(output).CacheDir(filepath.Join(dir, ".cache"))
+
+		for _, branch := range []string{"aaaaa", "bbbbb", "aaaaa"} {
+			g.Expect(os.WriteFile(input, []byte(branch), 0o600)).To(Succeed())
+			g.Expect(build.Run(context.Background())).To(Succeed())
+		}
+
+		g.Expect(runs).To(Equal(3), "first entry was evicted, so switching back re-runs")
+	})
+
+	t.Run("OverflowingSizeIsRejected", func(t *testing.T) {
+		g := NewWithT(t)
+
+		t.Setenv("TARG_CACHE_MAX_SIZE", "9999999999T")
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		output := filepath.Join(dir, "app")
+		writeFileAt(t, input, time.Now())
+
+		build := targ.Targ(func() error { return writeOutput(output, "binary") }).
+			Cache(input).Outputs(output).CacheDir(filepath.Join(dir, ".cache"))
+
+		err := build.Run(context.Background())
+		g.Expect(err).To(MatchError(ContainSubstring(`invalid cache size: "9999999999T"`)))
+	})
+}
+
+func TestProperty_CacheKey(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ArgsChangeMissesCache", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		writeFileAt(t, input, time.Now())
+
+		runs := 0
+		build := targ.Targ(func(_ context.Context, _ string) {
+			runs++
+		}).Cache(input).CacheDir(filepath.Join(dir, ".cache"))
+
+		g.Expect(build.Run(context.Background(), "linux")).To(Succeed())
+		g.Expect(build.Run(context.Background(), "linux")).To(Succeed())
+		g.Expect(runs).To(Equal(1), "same args: cache hit")
+
+		g.Expect(build.Run(context.Background(), "darwin")).To(Succeed())
+		g.Expect(runs).To(Equal(2), "different args: cache miss")
+	})
+
+	t.Run("ShellCommandChangeMissesCache", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		log := filepath.Join(dir, "log")
+		cacheDir := filepath.Join(dir, ".cache")
+		writeFileAt(t, input, time.Now())
+
+		shellTarget := func(word string) *targ.Target {
+			return targ.Targ("echo " + word + " >> " + log).Name("gen").Cache(input).CacheDir(cacheDir)
+		}
+
+		g.Expect(shellTarget("one").Run(context.Background())).To(Succeed())
+		g.Expect(shellTarget("one").Run(context.Background())).To(Succeed())
+		g.Expect(shellTarget("two").Run(context.Background())).To(Succeed())
+
+		data, err := os.ReadFile(log)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(data)).To(Equal("one\ntwo\n"))
+	})
+
+	t.Run("CacheEnvChangeMissesCache", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		writeFileAt(t, input, time.Now())
+
+		runs := 0
+		build := targ.Targ(func() { runs++ }).Name("build").Cache(input).CacheEnv("GOOS")
+		args := []string{"app", "--cache-dir", filepath.Join(dir, ".cache"), "build"}
+
+		run := func(goos string) {
+			_, err := targ.ExecuteWithOptions(
+				args,
+				targ.RunOptions{Env: map[string]string{"GOOS": goos}},
+				build,
+			)
+			g.Expect(err).NotTo(HaveOccurred())
+		}
+
+		run("linux")
+		run("linux")
+		g.Expect(runs).To(Equal(1), "same env: cache hit")
+
+		run("windows")
+		g.Expect(runs).To(Equal(2), "changed env: cache miss")
+	})
+
+	t.Run("TargetsWithSamePatternsDoNotShareCache", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		cacheDir := filepath.Join(dir, ".cache")
+		writeFileAt(t, input, time.Now())
+
+		var lintRuns, testRuns int
+
+		lint := targ.Targ(func() { lintRuns++ }).Name("lint").Cache(input).CacheDir(cacheDir)
+		test := targ.Targ(func() { testRuns++ }).Name("test").Cache(input).CacheDir(cacheDir)
+
+		g.Expect(lint.Run(context.Background())).To(Succeed())
+		g.Expect(test.Run(context.Background())).To(Succeed())
+		g.Expect(lint.Run(context.Background())).To(Succeed())
+		g.Expect(test.Run(context.Background())).To(Succeed())
+
+		g.Expect(lintRuns).To(Equal(1))
+		g.Expect(testRuns).To(Equal(1))
+	})
+
+	t.Run("RootRunThenDepRunHitsCache", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		log := filepath.Join(dir, "log")
+		cacheDir := filepath.Join(dir, ".cache")
+		writeFileAt(t, input, time.Now())
+
+		runs := 0
+		build := targ.Targ(func() { runs++ }).Name("build").Cache(input).CacheDir(cacheDir)
+		gen := targ.Targ("echo gen >> " + log).Name("gen").Cache(input).CacheDir(cacheDir)
+		ci := targ.Targ().Name("ci").Deps(build, gen)
+
+		for _, root := range []*targ.Target{build, gen} {
+			result, err := targ.Execute([]string{"app"}, root)
+			g.Expect(err).NotTo(HaveOccurred(), result.Output)
+		}
+
+		entries, err := os.ReadDir(cacheDir)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(entries).NotTo(BeEmpty(), "roots cache where their CacheDir says")
+
+		g.Expect(ci.Run(context.Background())).To(Succeed())
+
+		data, err := os.ReadFile(log)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(data)).To(Equal("gen\n"), "the dep run hits the shell root's entry")
+		g.Expect(runs).To(Equal(1), "the dep run hits the function root's entry")
+	})
+}
+
+func TestProperty_CacheCommands(t *testing.T) {
+	t.Parallel()
+
+	t.Run("StatusInfoAndCleanReportWhatChanged", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		added := filepath.Join(dir, "util.go")
+		cacheDir := filepath.Join(dir, ".cache")
+		writeFileAt(t, input, time.Now())
+
+		build := targ.Targ(func() {}).Name("build").Cache(filepath.Join(dir, "*.go"))
+		lint := targ.Targ(func() {}).Name("lint").Cache(input)
+
+		run := func(args ...string) string {
+			result, err := targ.Execute(
+				append([]string{"app", "--cache-dir", cacheDir}, args...), build, lint,
+			)
+			g.Expect(err).NotTo(HaveOccurred(), result.Output)
+
+			return result.Output
+		}
+
+		g.Expect(run("--cache-status")).To(Equal("build: miss\n  never run\nlint: miss\n  never run\n"))
+
+		run("build")
+		g.Expect(run("--cache-status", "build")).To(Equal("build: hit\n"))
+
+		entries, err := filepath.Glob(filepath.Join(cacheDir, "*.sum"))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(entries).To(HaveLen(1))
+
+		data, err := os.ReadFile(entries[0])
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(data)).To(ContainSubstring(`"target": "build"`))
+		g.Expect(string(data)).To(ContainSubstring(input))
+
+		g.Expect(os.WriteFile(input, []byte("package main // edited"), 0o600)).To(Succeed())
+		writeFileAt(t, added, time.Now())
+		g.Expect(run("--cache-status", "build")).To(Equal(
+			"build: miss\n  added: " + added + "\n  modified: " + input + "\n",
+		))
+
+		g.Expect(run("--cache-info")).To(
+			MatchRegexp(`(?m)^` + regexp.QuoteMeta(cacheDir) + `: 1 entry, 0 artifact sets \(0 B\), .* total\n  build: 1 input, last run `),
+		)
+
+		g.Expect(run("--cache-clean", "build")).To(Equal("removed " + entries[0] + "\n"))
+		g.Expect(run("--cache-status", "build")).To(Equal("build: miss\n  never run\n"))
+		g.Expect(run("--cache-clean", "lint")).To(Equal("lint: nothing cached\n"))
+
+		run("build")
+		g.Expect(run("--cache-clean", "--all")).To(Equal("removed " + cacheDir + "\n"))
+		g.Expect(cacheDir).NotTo(BeADirectory())
+	})
+
+	t.Run("CleanAllKeepsFilesTargDidNotCreate", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		output := filepath.Join(dir, "app")
+		writeFileAt(t, input, time.Now())
+
+		// The cache dir is one the user keeps other files in.
+		notes := filepath.Join(dir, "notes.txt")
+		g.Expect(os.WriteFile(notes, []byte("mine"), 0o600)).To(Succeed())
+
+		build := targ.Targ(func() error { return os.WriteFile(output, []byte("bin"), 0o600) }).
+			Name("build").Cache(input).Outputs(output)
+
+		run := func(args ...string) string {
+			result, err := targ.Execute(append([]string{"app", "--cache-dir", dir}, args...), build)
+			g.Expect(err).NotTo(HaveOccurred(), result.Output)
+
+			return result.Output
+		}
+
+		run()
+		g.Expect(filepath.Glob(filepath.Join(dir, "*.sum"))).To(HaveLen(1))
+		g.Expect(filepath.Join(dir, "artifacts")).To(BeADirectory())
+
+		g.Expect(run("--cache-clean", "--all")).To(Equal(
+			"cleaned " + dir + ", keeping files targ didn't create\n",
+		))
+		g.Expect(filepath.Glob(filepath.Join(dir, "*.sum"))).To(BeEmpty())
+		g.Expect(filepath.Join(dir, "artifacts")).NotTo(BeADirectory())
+		g.Expect(filepath.Join(dir, "stat-cache.json")).NotTo(BeAnExistingFile())
+		g.Expect(notes).To(BeAnExistingFile())
+		g.Expect(input).To(BeAnExistingFile())
+		g.Expect(output).To(BeAnExistingFile())
+	})
+
+	t.Run("ExplainSaysWhyTargetsReRun", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		cacheDir := filepath.Join(dir, ".cache")
+		writeFileAt(t, input, time.Now())
+
+		type buildArgs struct {
+			Target string `targ:"flag"`
+		}
+
+		build := targ.Targ(func(buildArgs) {}).Name("build").Cache(input)
+		lint := targ.Targ(func() {}).Name("lint")
+
+		run := func(target string) string {
+			result, err := targ.Execute(
+				[]string{"app", "--explain", "--cache-dir", cacheDir, "build", "--target", target},
+				build, lint,
+			)
+			g.Expect(err).NotTo(HaveOccurred(), result.Output)
+
+			return result.Output
+		}
+
+		g.Expect(run("linux")).To(Equal("build: cache miss\n  never run\n"))
+		g.Expect(run("linux")).To(BeEmpty(), "cache hit")
+
+		g.Expect(os.WriteFile(input, []byte("package main // edited"), 0o600)).To(Succeed())
+		g.Expect(run("darwin")).To(Equal("build: cache miss\n" +
+			"  modified: " + input + "\n" +
+			`  args changed: {"Target":"linux"} -> {"Target":"darwin"}` + "\n"))
+	})
+
+	t.Run("UnknownTargetsAndMissingArgsFail", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		build := targ.Targ(func() {}).Name("build").Cache(filepath.Join(t.TempDir(), "*.go"))
+
+		for _, args := range [][]string{
+			{"app", "--cache-status", "deploy"},
+			{"app", "--cache-clean", "deploy"},
+			{"app", "--cache-clean"},
+		} {
+			result, err := targ.Execute(args, build)
+			g.Expect(err).To(HaveOccurred(), args)
+			g.Expect(result.Output).To(HavePrefix("Error: "), args)
+		}
+	})
+}
+
+func TestProperty_Watch(t *testing.T) {
+	t.Parallel()
+
+	backends := map[string]targ.WatchBackend{
+		"Poll":   targ.WatchBackendPoll,
+		"Native": targ.WatchBackendNative,
+	}
+
+	for name, backend := range backends {
+		t.Run(name+"ReportsAddedModifiedRemoved", func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			if backend == targ.WatchBackendNative && runtime.GOOS != "linux" {
+				t.Skip("native watching is only supported on Linux")
+			}
+
+			dir := t.TempDir()
+			existing := filepath.Join(dir, "a.txt")
+			writeFileAt(t, existing, time.Now().Add(-time.Hour))
+
+			changes := startWatch(t, targ.WatchOptions{
+				Interval: 10 * time.Millisecond,
+				Backend:  backend,
+			}, filepath.Join(dir, "**", "*.txt"))
+			awaitWatching(t, dir, changes)
+
+			added := filepath.Join(dir, "sub", "b.txt")
+			writeFileAt(t, added, time.Now())
+			g.Eventually(changes).WithTimeout(5*time.Second).Should(Receive(Equal(targ.ChangeSet{
+				Added: []string{added}, Removed: []string{}, Modified: []string{},
+			})), "file in a new directory is added")
+
+			writeFileAt(t, existing, time.Now())
+			g.Eventually(changes).WithTimeout(5 * time.Second).Should(Receive(Equal(targ.ChangeSet{
+				Added: []string{}, Removed: []string{}, Modified: []string{existing},
+			})))
+
+			g.Expect(os.Remove(existing)).To(Succeed())
+			g.Eventually(changes).WithTimeout(5 * time.Second).Should(Receive(Equal(targ.ChangeSet{
+				Added: []string{}, Removed: []string{existing}, Modified: []string{},
+			})))
+
+			writeFileAt(t, filepath.Join(dir, "ignored.log"), time.Now())
+			g.Consistently(changes).WithTimeout(100*time.Millisecond).ShouldNot(Receive(),
+				"files outside the patterns don't trigger the callback")
+		})
+	}
+
+	t.Run("AutoBackendReportsChanges", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		changes := startWatch(t, targ.WatchOptions{
+			Interval: 10 * time.Millisecond,
+		}, filepath.Join(dir, "*.txt"))
+		awaitWatching(t, dir, changes)
+
+		g.Expect(os.WriteFile(filepath.Join(dir, "new.txt"), nil, 0o600)).To(Succeed())
+		g.Eventually(changes).WithTimeout(5 * time.Second).Should(Receive())
+	})
+
+	t.Run("NegatedPatternsAreNotReported", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		g.Expect(os.MkdirAll(filepath.Join(dir, "vendor"), 0o750)).To(Succeed())
+
+		changes := startWatch(t, targ.WatchOptions{
+			Interval: 10 * time.Millisecond,
+		}, filepath.Join(dir, "**", "*.txt"), "!"+filepath.Join(dir, "vendor"))
+		awaitWatching(t, dir, changes)
+
+		writeFileAt(t, filepath.Join(dir, "vendor", "dep.txt"), time.Now())
+		g.Consistently(changes).WithTimeout(100*time.Millisecond).ShouldNot(Receive(),
+			"files under excluded directories don't trigger the callback")
+
+		kept := filepath.Join(dir, "kept.txt")
+		writeFileAt(t, kept, time.Now())
+		g.Eventually(changes).WithTimeout(5 * time.Second).Should(Receive(Equal(targ.ChangeSet{
+			Added: []string{kept}, Removed: []string{}, Modified: []string{},
+		})))
+	})
+}
+
+func TestProperty_WatchSettling(t *testing.T) {
+	t.Parallel()
+
+	t.Run("DebounceCoalescesBursts", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		changes := startWatch(t, targ.WatchOptions{
+			Interval: 10 * time.Millisecond,
+			Debounce: 200 * time.Millisecond,
+		}, filepath.Join(dir, "*.txt"))
+		awaitWatching(t, dir, changes)
+
+		var written []string
+
+		for i := range 5 {
+			path := filepath.Join(dir, fmt.Sprintf("file-%d.txt", i))
+			writeFileAt(t, path, time.Now())
+			written = append(written, path)
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		g.Eventually(changes).WithTimeout(5 * time.Second).Should(Receive(Equal(targ.ChangeSet{
+			Added: written, Removed: []string{}, Modified: []string{},
+		})))
+		g.Consistently(changes).WithTimeout(300 * time.Millisecond).ShouldNot(Receive())
+	})
+
+	t.Run("MaxWaitBoundsDebounce", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		changes := startWatch(t, targ.WatchOptions{
+			Interval: 10 * time.Millisecond,
+			Debounce: 200 * time.Millisecond,
+			MaxWait:  400 * time.Millisecond,
+		}, filepath.Join(dir, "*.txt"))
+		awaitWatching(t, dir, changes)
+
+		stop := make(chan struct{})
+		defer close(stop)
+
+		go func() {
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				case <-time.After(50 * time.Millisecond):
+					_ = os.WriteFile(filepath.Join(dir, fmt.Sprintf("busy-%d.txt", i)), nil, 0o600)
+				}
+			}
+		}()
+
+		g.Eventually(changes).WithTimeout(2*time.Second).Should(Receive(),
+			"changes that never settle are reported after MaxWait")
+	})
+
+	t.Run("QueueReportsChangesAfterRun", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		released := make(chan struct{})
+		calls, block := startBlockingWatch(t, dir, targ.WatchOptions{
+			Interval: 10 * time.Millisecond,
+		}, awaitRelease(released))
+		block()
+
+		first := filepath.Join(dir, "first.txt")
+		writeFileAt(t, first, time.Now())
+		g.Eventually(calls).WithTimeout(5 * time.Second).Should(Receive())
+
+		second := filepath.Join(dir, "second.txt")
+		writeFileAt(t, second, time.Now())
+		g.Consistently(calls).WithTimeout(100*time.Millisecond).ShouldNot(Receive(), "run still in progress")
+
+		close(released)
+		g.Eventually(calls).WithTimeout(5 * time.Second).Should(Receive(Equal(targ.ChangeSet{
+			Added: []string{second}, Removed: []string{}, Modified: []string{},
+		})))
+	})
+
+	t.Run("DropDiscardsChangesDuringRun", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		released := make(chan struct{})
+		calls, block := startBlockingWatch(t, dir, targ.WatchOptions{
+			Interval: 10 * time.Millisecond,
+			OnBusy:   targ.WatchDrop,
+		}, awaitRelease(released))
+		block()
+
+		writeFileAt(t, filepath.Join(dir, "first.txt"), time.Now())
+		g.Eventually(calls).WithTimeout(5 * time.Second).Should(Receive())
+
+		writeFileAt(t, filepath.Join(dir, "second.txt"), time.Now())
+		time.Sleep(100 * time.Millisecond)
+
+		close(released)
+		g.Consistently(calls).WithTimeout(300 * time.Millisecond).ShouldNot(Receive())
+	})
+
+	t.Run("RestartCancelsRunAndReportsAllChanges", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		calls, block := startBlockingWatch(t, dir, targ.WatchOptions{
+			Interval: 10 * time.Millisecond,
+			OnBusy:   targ.WatchRestart,
+		}, awaitRelease(nil))
+		block()
+
+		first := filepath.Join(dir, "first.txt")
+		writeFileAt(t, first, time.Now())
+		g.Eventually(calls).WithTimeout(5 * time.Second).Should(Receive())
+
+		second := filepath.Join(dir, "second.txt")
+		writeFileAt(t, second, time.Now())
+		g.Eventually(calls).WithTimeout(5*time.Second).Should(Receive(Equal(targ.ChangeSet{
+			Added: []string{first, second}, Removed: []string{}, Modified: []string{},
+		})), "the interrupted run's changes are reported again")
+	})
+}
+
+func writeOutput(path, content string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(content), 0o600)
+}
+
+// cacheServer is an in-memory HTTP cache server for remote cache tests.
+type cacheServer struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (c *cacheServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+		body, ok := c.data[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		_, _ = w.Write(body)
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		c.data[r.URL.Path] = body
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (c *cacheServer) keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, len(c.data))
+	for key := range c.data {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// awaitRelease returns watch work that waits until released is closed or the run is cancelled.
+func awaitRelease(released <-chan struct{}) func(context.Context) error {
+	return func(ctx context.Context) error {
+		select {
+		case <-released:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// awaitWatching writes probe files matching dir/*.txt until the watcher reports
+// one, so changes made afterwards are seen, then discards the probes' changes.
+func awaitWatching(t *testing.T, dir string, changes <-chan targ.ChangeSet) {
+	t.Helper()
+
+	for i := 0; ; i++ {
+		if i == 20 {
+			t.Fatal("watcher never reported a change")
+		}
+
+		probe := filepath.Join(dir, fmt.Sprintf("probe-%d.txt", i))
+		writeFileAt(t, probe, time.Now())
+
+		select {
+		case <-changes:
+			time.Sleep(50 * time.Millisecond)
+
+			for len(changes) > 0 {
+				<-changes
+			}
+
+			return
+		case <-time.After(300 * time.Millisecond):
+		}
+	}
+}
+
+func newCacheServer(t *testing.T) (*httptest.Server, *cacheServer) {
+	t.Helper()
+
+	store := &cacheServer{data: make(map[string][]byte)}
+	server := httptest.NewServer(store)
+	t.Cleanup(server.Close)
+
+	return server, store
+}
+
+// startBlockingWatch runs targ.WatchContext on dir/*.txt until the test ends.
+// Once block is called, each run calls work after reporting its changes.
+func startBlockingWatch(
+	t *testing.T,
+	dir string,
+	opts targ.WatchOptions,
+	work func(context.Context) error,
+) (calls <-chan targ.ChangeSet, block func()) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan targ.ChangeSet, 100)
+	done := make(chan struct{})
+
+	var blocking atomic.Bool
+
+	go func() {
+		defer close(done)
+
+		_ = targ.WatchContext(ctx, []string{filepath.Join(dir, "*.txt")}, opts,
+			func(runCtx context.Context, c targ.ChangeSet) error {
+				changes <- c
+
+				if !blocking.Load() {
+					return nil
+				}
+
+				return work(runCtx)
+			})
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	awaitWatching(t, dir, changes)
+
+	return changes, func() { blocking.Store(true) }
+}
+
+// startWatch runs targ.Watch on pattern until the test ends, returning the changes it reports.
+func startWatch(t *testing.T, opts targ.WatchOptions, patterns ...string) <-chan targ.ChangeSet {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan targ.ChangeSet, 100)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		_ = targ.Watch(ctx, patterns, opts, func(c targ.ChangeSet) error {
+			changes <- c
+			return nil
+		})
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return changes
+}
+
+func writeFileAt(t *testing.T, path string, modTime time.Time) {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(path, []byte(path), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chtimes(path, modTime, modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProperty_FileHelpers(t *testing.T) {
+	t.Parallel()
+
+	t.Run("CopyCopiesTreesWithModesAndSymlinks", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src")
+		g.Expect(os.MkdirAll(filepath.Join(src, "bin"), 0o755)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(src, "bin", "tool"), []byte("#!/bin/sh\n"), 0o755)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(src, "notes.txt"), []byte("notes"), 0o600)).To(Succeed())
+		g.Expect(os.Symlink("notes.txt", filepath.Join(src, "link"))).To(Succeed())
+
+		dst := filepath.Join(dir, "nested", "dst")
+		g.Expect(os.MkdirAll(dst, 0o755)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dst, "notes.txt"), []byte("stale"), 0o644)).To(Succeed())
+
+		g.Expect(targ.Copy(dst, src)).To(Succeed())
+
+		content, err := os.ReadFile(filepath.Join(dst, "notes.txt"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(content)).To(Equal("notes"))
+
+		if runtime.GOOS != "windows" {
+			info, err := os.Stat(filepath.Join(dst, "bin", "tool"))
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o755)))
+
+			info, err = os.Stat(filepath.Join(dst, "notes.txt"))
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o600)))
+		}
+
+		target, err := os.Readlink(filepath.Join(dst, "link"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(target).To(Equal("notes.txt"))
+
+		err = targ.Copy(filepath.Join(src, "bin", "copy"), src)
+		g.Expect(err).To(MatchError(targ.ErrCopyIntoSelf))
+	})
+
+	t.Run("RmIsIdempotentAndRemovesReadOnlyTrees", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		tree := filepath.Join(dir, "tree")
+		g.Expect(os.MkdirAll(filepath.Join(tree, "sub"), 0o755)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(tree, "sub", "file"), []byte("x"), 0o444)).To(Succeed())
+		g.Expect(os.Chmod(filepath.Join(tree, "sub"), 0o555)).To(Succeed())
+
+		g.Expect(targ.Rm(tree)).To(Succeed())
+		g.Expect(tree).ToNot(BeAnExistingFile())
+		g.Expect(targ.Rm(tree)).To(Succeed())
+	})
+
+	t.Run("RmLeavesWhatSymlinksPointToAlone", func(t *testing.T) {
+		t.Parallel()
+
+		if os.Geteuid() == 0 {
+			t.Skip("root removes read-only trees without making them writable")
+		}
+
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		outside := filepath.Join(dir, "outside.txt")
+		g.Expect(os.WriteFile(outside, []byte("keep"), 0o444)).To(Succeed())
+
+		tree := filepath.Join(dir, "tree")
+		g.Expect(os.MkdirAll(filepath.Join(tree, "sub"), 0o755)).To(Succeed())
+		g.Expect(os.Symlink(outside, filepath.Join(tree, "sub", "link"))).To(Succeed())
+		g.Expect(os.Chmod(filepath.Join(tree, "sub"), 0o555)).To(Succeed())
+
+		g.Expect(targ.Rm(tree)).To(Succeed())
+		g.Expect(tree).ToNot(BeAnExistingFile())
+
+		info, err := os.Stat(outside)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o444)))
+	})
+
+	t.Run("MkdirMoveAndWriteFile", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		created := filepath.Join(dir, "a", "b")
+		g.Expect(targ.Mkdir(created)).To(Succeed())
+		g.Expect(targ.Mkdir(created)).To(Succeed())
+		g.Expect(created).To(BeADirectory())
+
+		written := filepath.Join(dir, "out", "version.txt")
+		g.Expect(targ.WriteFile(written, []byte("v1"), 0o600)).To(Succeed())
+		g.Expect(targ.WriteFile(written, []byte("v2"), 0o600)).To(Succeed())
+
+		moved := filepath.Join(created, "version.txt")
+		g.Expect(targ.Move(moved, written)).To(Succeed())
+		g.Expect(written).ToNot(BeAnExistingFile())
+
+		content, err := os.ReadFile(moved)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(content)).To(Equal("v2"))
+
+		leftovers, err := filepath.Glob(filepath.Join(dir, "out", ".tmp-*"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(leftovers).To(BeEmpty())
+	})
+
+	t.Run("CopyGlobKeepsPathsBelowThePatternBase", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		assets := filepath.Join(dir, "assets")
+		g.Expect(os.MkdirAll(filepath.Join(assets, "img", "raw"), 0o755)).To(Succeed())
+
+		for _, name := range []string{"img/logo.png", "img/raw/big.png", "style.css"} {
+			g.Expect(os.WriteFile(filepath.Join(assets, name), []byte(name), 0o600)).To(Succeed())
+		}
+
+		dist := filepath.Join(dir, "dist")
+		err := targ.CopyGlob(dist,
+			filepath.Join(assets, "**", "*.png"), "!"+filepath.Join(assets, "img", "raw"))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(filepath.Join(dist, "img", "logo.png")).To(BeAnExistingFile())
+		g.Expect(filepath.Join(dist, "img", "raw", "big.png")).ToNot(BeAnExistingFile())
+		g.Expect(filepath.Join(dist, "style.css")).ToNot(BeAnExistingFile())
+
+		err = targ.CopyGlob(dist, filepath.Join(assets, "*.js"))
+		g.Expect(err).To(MatchError(targ.ErrNoMatches))
+	})
+}
@@ -7,7 +7,9 @@ package targ_test
 import (
 	"context"
 	"errors"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -134,6 +136,31 @@ func TestProperty_Overrides(t *testing.T) {
 		g.Expect(err).To(HaveOccurred())
 	})
 
+	t.Run("WatchOptionFlagsValidateValues", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		target := targ.Targ(func() {}).Name("target")
+
+		result, err := targ.Execute(
+			[]string{"app", "--watch-on-busy", "later", "target"},
+			target, dummy(),
+		)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(result.Output).To(ContainSubstring("--watch-on-busy must be 'queue', 'drop' or 'restart'"))
+
+		result, err = targ.Execute(
+			[]string{"app", "--watch-debounce=soon", "target"},
+			target, dummy(),
+		)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(result.Output).To(ContainSubstring("invalid --watch-debounce value"))
+
+		result, err = targ.Execute([]string{"app", "--watch-max-wait"}, target, dummy())
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(result.Output).To(ContainSubstring("--watch-max-wait: watch option requires a value"))
+	})
+
 	t.Run("RetryFlagRerunsOnFailure", func(t *testing.T) {
 		t.Parallel()
 		g := NewWithT(t)
@@ -307,7 +334,7 @@ func TestProperty_Overrides(t *testing.T) {
 
 		// With Disabled, --cache flag is allowed (patterns won't match but no conflict)
 		_, err := targ.Execute(
-			[]string{"app", "--cache", "nonexistent/**", "flexible"},
+			[]string{"app", "--cache-dir", t.TempDir(), "--cache", "nonexistent/**", "flexible"},
 			target, dummy(),
 		)
 		// May error due to cache check, but not conflict error
@@ -323,7 +350,7 @@ func TestProperty_Overrides(t *testing.T) {
 		target := targ.Targ(func() {}).Name("flexible").Cache(targ.Disabled)
 
 		_, err := targ.Execute(
-			[]string{"app", "--cache=nonexistent/**", "flexible"},
+			[]string{"app", "--cache-dir", t.TempDir(), "--cache=nonexistent/**", "flexible"},
 			target, dummy(),
 		)
 		// May error due to cache check, but not conflict error
@@ -696,6 +723,132 @@ func TestProperty_Overrides(t *testing.T) {
 	})
 }
 
+func TestProperty_DryRun(t *testing.T) {
+	t.Parallel()
+
+	t.Run("PrintsOrderedPlanWithoutRunning", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var runs atomic.Int32
+
+		work := func() { runs.Add(1) }
+		generate := targ.Targ(work).Name("generate")
+		vet := targ.Targ(work).Name("vet")
+		fmtCheck := targ.Targ(work).Name("fmt-check")
+		lint := targ.Targ(work).Name("lint").Timeout(time.Minute).Deps(vet, fmtCheck, targ.DepModeParallel)
+		test := targ.Targ(work).Name("test").Retry()
+		deploy := targ.Targ("echo deploying").Name("deploy")
+		ci := targ.Targ(work).Name("ci").
+			Deps(generate).
+			Deps(lint, test, targ.DepModeParallel).
+			Deps(deploy)
+
+		result, err := targ.ExecuteWithOptions(
+			[]string{"app", "--dry-run", "--times", "2", "ci"},
+			targ.RunOptions{},
+			ci,
+		)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+		g.Expect(runs.Load()).To(BeZero())
+		g.Expect(result.Output).To(Equal(`Dry run, nothing will be executed:
+generate
+parallel:
+  lint [timeout 1m0s]
+    parallel:
+      vet
+      fmt-check
+  test [retry]
+deploy
+  $ echo deploying
+ci [times 2]
+`))
+	})
+
+	t.Run("ShellTargetShowsExpandedCommand", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		ran := false
+		apply := targ.Targ("kubectl apply -n $namespace").Name("apply")
+
+		result, err := targ.ExecuteWithOptions(
+			[]string{"app", "--dry-run", "apply", "--namespace", "prod"},
+			targ.RunOptions{ShellRunner: func(context.Context, string) error {
+				ran = true
+				return nil
+			}},
+			apply,
+		)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+		g.Expect(ran).To(BeFalse())
+		g.Expect(result.Output).To(ContainSubstring("apply\n  $ kubectl apply -n prod\n"))
+	})
+
+	t.Run("ReportsCacheStatusWithoutChangingCache", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "main.go")
+		writeFileAt(t, input, time.Now())
+
+		build := targ.Targ(func() {}).Name("build").
+			Cache(input).
+			CacheDir(filepath.Join(dir, ".cache"))
+		args := []string{"app", "--dry-run", "build"}
+
+		for range 2 {
+			result, err := targ.ExecuteWithOptions(args, targ.RunOptions{}, build)
+			g.Expect(err).NotTo(HaveOccurred(), result.Output)
+			g.Expect(result.Output).To(ContainSubstring("build [cache miss]"))
+		}
+
+		result, err := targ.ExecuteWithOptions([]string{"app", "build"}, targ.RunOptions{}, build)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+
+		result, err = targ.ExecuteWithOptions(args, targ.RunOptions{}, build)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+		g.Expect(result.Output).To(ContainSubstring("build [cache hit]"))
+	})
+
+	t.Run("SharedDepIsPlannedOnce", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		build := targ.Targ(func() {}).Name("build")
+		a := targ.Targ(func() {}).Name("a").Deps(build)
+		b := targ.Targ(func() {}).Name("b").Deps(build)
+		ci := targ.Targ().Name("ci").Deps(a, b, targ.DepModeParallel).Concurrency(1)
+
+		result, err := targ.ExecuteWithOptions(
+			[]string{"app", "--dry-run", "ci"},
+			targ.RunOptions{},
+			ci,
+		)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+		g.Expect(result.Output).To(HaveSuffix(`parallel (1 at once):
+  a
+    build
+  b
+    build (already run above)
+ci (deps only)
+`))
+	})
+
+	t.Run("ParallelRootsAreIndented", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		a := targ.Targ(func() {}).Name("a")
+		b := targ.Targ(func() {}).Name("b")
+
+		result, err := targ.Execute([]string{"app", "--dry-run", "-p", "a", "b"}, a, b)
+		g.Expect(err).NotTo(HaveOccurred(), result.Output)
+		g.Expect(result.Output).To(HaveSuffix("parallel:\n  a\n  b\n"))
+	})
+}
+
 // itoa converts int to string without importing strconv.
 func itoa(n int) string {
 	if n == 0 {
@@ -4,10 +4,16 @@
 package targ_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 	"pgregory.net/rapid"
@@ -15,6 +21,266 @@ import (
 	"github.com/toejough/targ"
 )
 
+func TestProperty_CommandBuilder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("DirEnvAndStdinReachTheCommand", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir, err := filepath.EvalSymlinks(t.TempDir())
+		g.Expect(err).ToNot(HaveOccurred())
+
+		lines, err := targ.Cmd("sh", "-c", `pwd; echo "$GREETING"; cat`).
+			Dir(dir).
+			Env("GREETING=hello").
+			Stdin(strings.NewReader("piped\n")).
+			Lines(context.Background())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(lines).To(Equal([]string{dir, "hello", "piped"}))
+	})
+
+	t.Run("OutputCapturesStdoutAndStderrSeparately", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var stderr bytes.Buffer
+
+		out, err := targ.Cmd("sh", "-c", "echo out; echo err >&2").
+			Stderr(&stderr).
+			Output(context.Background())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(out).To(Equal("out\n"))
+		g.Expect(stderr.String()).To(Equal("err\n"))
+
+		combined, err := targ.Cmd("sh", "-c", "echo out; echo err >&2").CombinedOutput(context.Background())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(combined).To(ContainSubstring("out\n"))
+		g.Expect(combined).To(ContainSubstring("err\n"))
+	})
+
+	t.Run("CombinedOutputKeepsTheOrderItWasWritten", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		script := "for i in 1 2 3 4 5 6 7 8 9 10; do echo out$i; echo err$i >&2; done; exit 1"
+
+		var want strings.Builder
+		for i := 1; i <= 10; i++ {
+			fmt.Fprintf(&want, "out%d\nerr%d\n", i, i)
+		}
+
+		combined, err := targ.Cmd("sh", "-c", script).CombinedOutput(context.Background())
+		g.Expect(combined).To(Equal(want.String()))
+
+		var cmdErr *targ.CommandError
+
+		g.Expect(errors.As(err, &cmdErr)).To(BeTrue())
+		g.Expect(cmdErr.Stderr).To(ContainElement("err10"), "the error still has the output's tail")
+
+		combined, err = targ.Output("sh", "-c", script)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(combined).To(Equal(want.String()))
+
+		combined, err = targ.OutputContext(context.Background(), "sh", "-c", script)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(combined).To(Equal(want.String()))
+	})
+
+	t.Run("FilesRedirectStdinStdoutAndStderr", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		in := filepath.Join(dir, "in.txt")
+		out := filepath.Join(dir, "out.txt")
+		errs := filepath.Join(dir, "err.txt")
+
+		g.Expect(os.WriteFile(in, []byte("input\n"), 0o600)).To(Succeed())
+
+		script := "cat; echo oops >&2"
+		g.Expect(targ.Cmd("sh", "-c", script).StdinFile(in).StdoutFile(out).StderrFile(errs).
+			Run(context.Background())).To(Succeed())
+		g.Expect(targ.Cmd("echo", "more").AppendStdout(out).Run(context.Background())).To(Succeed())
+
+		g.Expect(os.ReadFile(out)).To(Equal([]byte("input\nmore\n")))
+		g.Expect(os.ReadFile(errs)).To(Equal([]byte("oops\n")))
+
+		g.Expect(targ.Cmd("echo", "replaced").StdoutFile(out).Run(context.Background())).To(Succeed())
+		g.Expect(os.ReadFile(out)).To(Equal([]byte("replaced\n")))
+
+		err := targ.Cmd("cat").StdinFile(filepath.Join(dir, "missing")).Run(context.Background())
+		g.Expect(err).To(MatchError(os.ErrNotExist))
+	})
+
+	t.Run("VerbosePrintsTheCommandLine", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var stdout bytes.Buffer
+
+		err := targ.Cmd("echo", "hello world").Verbose().Stdout(&stdout).Run(context.Background())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(stdout.String()).To(Equal("+ echo \"hello world\"\nhello world\n"))
+	})
+
+	t.Run("FailuresAndCancellationReturnErrors", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		err := targ.Cmd("sh", "-c", "exit 3").Run(context.Background())
+		g.Expect(err).To(HaveOccurred())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err = targ.Cmd("sleep", "10").Run(ctx)
+		g.Expect(err).To(MatchError(context.DeadlineExceeded))
+		g.Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+	})
+}
+
+func TestProperty_CommandErrors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("CarryExitCodeAndStderrTail", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		err := targ.Cmd("sh", "-c", "echo one >&2; echo two >&2; exit 3").
+			Stderr(io.Discard).
+			Run(context.Background())
+
+		var cmdErr *targ.CommandError
+
+		g.Expect(errors.As(err, &cmdErr)).To(BeTrue())
+		g.Expect(cmdErr.Command).To(HavePrefix("sh -c"))
+		g.Expect(cmdErr.ExitCode).To(Equal(3))
+		g.Expect(cmdErr.Stderr).To(Equal([]string{"one", "two"}))
+		g.Expect(targ.ExitStatus(err)).To(Equal(3))
+		g.Expect(targ.CmdRan(err)).To(BeTrue())
+	})
+
+	t.Run("KeepStderrTailWhenStderrIsAFile", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		errs := filepath.Join(t.TempDir(), "err.txt")
+		script := "echo one >&2; echo two >&2; exit 3"
+
+		err := targ.Cmd("sh", "-c", script).StderrFile(errs).Run(context.Background())
+
+		var cmdErr *targ.CommandError
+
+		g.Expect(errors.As(err, &cmdErr)).To(BeTrue())
+		g.Expect(cmdErr.Stderr).To(Equal([]string{"one", "two"}))
+		g.Expect(os.ReadFile(errs)).To(Equal([]byte("one\ntwo\n")))
+
+		// Run's stderr is the process's own.
+		err = targ.Run("sh", "-c", script)
+		g.Expect(errors.As(err, &cmdErr)).To(BeTrue())
+		g.Expect(cmdErr.Stderr).To(Equal([]string{"one", "two"}))
+	})
+
+	t.Run("SignalsHaveNoExitCode", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		err := targ.Cmd("sh", "-c", "kill -TERM $$").Run(context.Background())
+
+		var cmdErr *targ.CommandError
+
+		g.Expect(errors.As(err, &cmdErr)).To(BeTrue())
+		g.Expect(cmdErr.ExitCode).To(Equal(-1))
+		g.Expect(cmdErr.Signal).To(Equal("terminated"))
+		g.Expect(targ.ExitStatus(err)).To(Equal(1))
+	})
+
+	t.Run("MissingCommandsDidNotRun", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		err := targ.Run("targ-no-such-command")
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(targ.CmdRan(err)).To(BeFalse())
+		g.Expect(targ.ExitStatus(err)).To(Equal(1))
+
+		g.Expect(targ.CmdRan(nil)).To(BeTrue())
+		g.Expect(targ.ExitStatus(nil)).To(Equal(0))
+	})
+
+	t.Run("ExitCodePropagatesToTheResult", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		target := targ.Targ(func(ctx context.Context) error {
+			return targ.Cmd("sh", "-c", "exit 7").Run(ctx)
+		}).Name("fail")
+
+		result, err := targ.Execute([]string{"app"}, target)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(result.ExitCode).To(Equal(7))
+		g.Expect(targ.ExitStatus(err)).To(Equal(7))
+	})
+}
+
+func TestProperty_CommandFuncs(t *testing.T) {
+	t.Parallel()
+
+	t.Run("BoundArgsComeFirst", func(t *testing.T) {
+		t.Parallel()
+		rapid.Check(t, func(t *rapid.T) {
+			g := NewWithT(t)
+
+			bound := rapid.StringMatching(`[a-z]{1,8}`).Draw(t, "bound")
+			extra := rapid.SliceOfN(rapid.StringMatching(`[a-z]{1,8}`), 0, 3).Draw(t, "extra")
+
+			echo := targ.OutCmd("echo", bound)
+
+			out, err := echo(context.Background(), extra...)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(out).To(Equal(strings.Join(append([]string{bound}, extra...), " ") + "\n"))
+
+			again, err := echo(context.Background())
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(again).To(Equal(bound + "\n"))
+		})
+	})
+
+	t.Run("DirAndEnvAreBound", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir, err := filepath.EvalSymlinks(t.TempDir())
+		g.Expect(err).ToNot(HaveOccurred())
+
+		cmd := targ.Cmd("sh", "-c", `echo "$GREETING $0"; pwd`).Dir(dir).Env("GREETING=hello")
+		greet := cmd.OutputFunc()
+
+		cmd.Dir("/").Env("GREETING=changed")
+
+		out, err := greet(context.Background(), "world")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(out).To(Equal("hello world\n" + dir + "\n"))
+	})
+
+	t.Run("RunFuncsPrefixOutputInParallelMode", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		echo := targ.RunCmd("echo", "from")
+
+		a := targ.Targ(func(ctx context.Context) error { return echo(ctx, "a") }).Name("a")
+		b := targ.Targ(func(ctx context.Context) error { return echo(ctx, "b") }).Name("b")
+
+		result, err := targ.Execute([]string{"app", "--parallel", "a", "b"}, a, b)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Output).To(ContainSubstring("[a] from a"))
+		g.Expect(result.Output).To(ContainSubstring("[b] from b"))
+	})
+}
+
 func TestProperty_CommandHelp(t *testing.T) {
 	t.Parallel()
 
@@ -219,6 +485,105 @@ func TestProperty_CommandHelp(t *testing.T) {
 	})
 }
 
+func TestProperty_Pipelines(t *testing.T) {
+	t.Parallel()
+
+	t.Run("OutputIsTheLastCommandsStdout", func(t *testing.T) {
+		t.Parallel()
+		rapid.Check(t, func(t *rapid.T) {
+			g := NewWithT(t)
+
+			lines := rapid.SliceOfN(rapid.StringMatching(`[a-z]{1,8}`), 1, 10).Draw(t, "lines")
+
+			var kept []string
+
+			for _, line := range lines {
+				if !strings.Contains(line, "x") {
+					kept = append(kept, line)
+				}
+			}
+
+			out, err := targ.Pipe(
+				targ.Cmd("cat").Stdin(strings.NewReader(strings.Join(lines, "\n")+"\n")),
+				targ.Cmd("grep", "-v", "x"),
+				targ.Cmd("cat"),
+			).Lines(context.Background())
+			if len(kept) == 0 {
+				g.Expect(targ.ExitStatus(err)).To(Equal(1)) // grep found nothing
+				return
+			}
+
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(out).To(Equal(kept))
+		})
+	})
+
+	t.Run("EndsRedirectToFiles", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		in := filepath.Join(dir, "in.txt")
+		out := filepath.Join(dir, "out.txt")
+
+		g.Expect(os.WriteFile(in, []byte("b\na\nb\n"), 0o600)).To(Succeed())
+
+		err := targ.Pipe(targ.Cmd("sort").StdinFile(in), targ.Cmd("uniq").StdoutFile(out)).
+			Run(context.Background())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(os.ReadFile(out)).To(Equal([]byte("a\nb\n")))
+	})
+
+	t.Run("FailureAnywhereFailsThePipeline", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		err := targ.Pipe(targ.Cmd("sh", "-c", "exit 3"), targ.Cmd("cat")).Run(context.Background())
+		g.Expect(targ.ExitStatus(err)).To(Equal(3))
+
+		err = targ.Pipe(targ.Cmd("sh", "-c", "exit 3"), targ.Cmd("sh", "-c", "cat; exit 4")).
+			Run(context.Background())
+		g.Expect(targ.ExitStatus(err)).To(Equal(4))
+
+		err = targ.Pipe(targ.Cmd("echo"), targ.Cmd("targ-no-such-command")).Run(context.Background())
+		g.Expect(targ.CmdRan(err)).To(BeFalse())
+	})
+
+	t.Run("CancellationKillsEveryCommand", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		// cat only exits once sleep, which holds the pipe open, is killed too.
+		start := time.Now()
+		err := targ.Pipe(targ.Cmd("sleep", "10"), targ.Cmd("cat")).Run(ctx)
+		g.Expect(err).To(MatchError(context.DeadlineExceeded))
+		g.Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+	})
+
+	t.Run("VerbosePrintsThePipeline", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		pipe := func(s string) *targ.Pipeline {
+			return targ.Pipe(targ.Cmd("echo", s+" "+s), targ.Cmd("tr", "a-z", "A-Z")).Verbose()
+		}
+
+		g.Expect(pipe("a").String()).To(Equal(`echo "a a" | tr a-z A-Z`))
+
+		a := targ.Targ(func(ctx context.Context) error { return pipe("a").Run(ctx) }).Name("a")
+		b := targ.Targ(func(ctx context.Context) error { return pipe("b").Run(ctx) }).Name("b")
+
+		result, err := targ.Execute([]string{"app", "--parallel", "a", "b"}, a, b)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Output).To(ContainSubstring(`[a] + echo "a a" | tr a-z A-Z`))
+		g.Expect(result.Output).To(ContainSubstring("[a] A A"))
+		g.Expect(result.Output).To(ContainSubstring("[b] B B"))
+	})
+}
+
 func TestProperty_ShellCommandErrors(t *testing.T) {
 	t.Parallel()
 